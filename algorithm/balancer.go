@@ -0,0 +1,115 @@
+package algorithm
+
+import (
+	"errors"
+	"sort"
+)
+
+/*
+ * unified balancer interface
+ * - implemented by HashRing, Consistent, ConsistentHash, Ring and XConsistent
+ * - also JumpHash, Maglev and Rendezvous
+ * - member is string, weight is relative value, default 1
+ */
+
+//inter macro define
+const (
+	DefaultBalancerWeight = 1
+)
+
+//inter error define
+var (
+	ErrMemberExists   = errors.New("member already exists")
+	ErrMemberNotFound = errors.New("no such member")
+	ErrNoMembers      = errors.New("no any members")
+	ErrInvalidWeight  = errors.New("invalid weight")
+)
+
+//balancer interface
+type Balancer interface {
+	//add member with optional weight
+	AddMember(member string, weights ...int) error
+
+	//remove member
+	RemoveMember(member string) error
+
+	//update weight of member
+	SetWeight(member string, weight int) error
+
+	//locate member for key
+	Locate(key string) (string, error)
+
+	//locate n distinct members for key, the first one is the same as Locate
+	LocateN(key string, n int) ([]string, error)
+
+	//get all members, sorted
+	Members() []string
+}
+
+//make sure all implementations satisfy balancer
+var (
+	_ Balancer = (*HashRing)(nil)
+	_ Balancer = (*Consistent)(nil)
+	_ Balancer = (*ConsistentHash)(nil)
+	_ Balancer = (*Ring)(nil)
+	_ Balancer = (*XConsistent)(nil)
+	_ Balancer = (*JumpHash)(nil)
+	_ Balancer = (*Maglev)(nil)
+	_ Balancer = (*Rendezvous)(nil)
+)
+
+//pick weight from optional weights
+func pickWeight(weights []int) (int, error) {
+	weight := DefaultBalancerWeight
+	if weights != nil && len(weights) > 0 {
+		weight = weights[0]
+	}
+	if weight <= 0 {
+		return 0, ErrInvalidWeight
+	}
+	return weight, nil
+}
+
+//check locate n parameter
+func checkLocateN(n, members int) error {
+	if n <= 0 {
+		return errors.New("invalid parameter")
+	}
+	if members <= 0 {
+		return ErrNoMembers
+	}
+	if n > members {
+		return ErrInsufficientMemberCount
+	}
+	return nil
+}
+
+//walk ring clockwise from start position, collect n distinct members
+//memberAt returns members of ring position
+func walkRing(start, ringLen, n int, memberAt func(pos int) []string) []string {
+	result := make([]string, 0, n)
+	picked := make(map[string]bool, n)
+	for i := 0; i < ringLen && len(result) < n; i++ {
+		for _, member := range memberAt((start + i) % ringLen) {
+			if picked[member] {
+				continue
+			}
+			picked[member] = true
+			result = append(result, member)
+			if len(result) >= n {
+				break
+			}
+		}
+	}
+	return result
+}
+
+//get sorted member list from map keys
+func sortedMembers[V any](members map[string]V) []string {
+	result := make([]string, 0, len(members))
+	for member := range members {
+		result = append(result, member)
+	}
+	sort.Strings(result)
+	return result
+}
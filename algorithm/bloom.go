@@ -0,0 +1,241 @@
+package algorithm
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+
+	"github.com/spaolacci/murmur3"
+)
+
+/*
+ * bloom filter
+ * - optimal bits and hash count from expected items and false positive rate
+ * - k hash values by double hashing of murmur3 128 bits
+ * - merge filters with the same size by bits or
+ */
+
+//inter macro define
+const (
+	DefaultBloomItems     = 100000
+	DefaultBloomFalseRate = 0.01
+	bloomMagic            = "TLBF"
+)
+
+//face info
+type BloomFilter struct {
+	bits  []uint64
+	m     uint64 //bits count
+	k     uint64 //hash count
+	added uint64 //added items count
+	sync.RWMutex
+}
+
+//construct
+//expectedItems -> expected items count, default DefaultBloomItems
+//falseRates -> expected false positive rate, default DefaultBloomFalseRate
+func NewBloomFilter(expectedItems uint64, falseRates ...float64) *BloomFilter {
+	falseRate := DefaultBloomFalseRate
+	if falseRates != nil && len(falseRates) > 0 &&
+		falseRates[0] > 0 && falseRates[0] < 1 {
+		falseRate = falseRates[0]
+	}
+	if expectedItems <= 0 {
+		expectedItems = DefaultBloomItems
+	}
+	m, k := OptimalBloomSize(expectedItems, falseRate)
+	return NewBloomFilterWithSize(m, k)
+}
+
+//construct with bits count and hash count
+func NewBloomFilterWithSize(m, k uint64) *BloomFilter {
+	if m <= 0 {
+		m = 1
+	}
+	if k <= 0 {
+		k = 1
+	}
+	this := &BloomFilter{
+		bits: make([]uint64, (m + 63) / 64),
+		m: m,
+		k: k,
+	}
+	return this
+}
+
+//get optimal bits count and hash count
+func OptimalBloomSize(expectedItems uint64, falseRate float64) (uint64, uint64) {
+	n := float64(expectedItems)
+	m := math.Ceil(-n * math.Log(falseRate) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / n * math.Ln2)
+	if k < 1 {
+		k = 1
+	}
+	return uint64(m), uint64(k)
+}
+
+//add data
+func (f *BloomFilter) Add(data []byte) {
+	h1, h2 := murmur3.Sum128(data)
+	f.Lock()
+	defer f.Unlock()
+	f.add(h1, h2)
+}
+
+//add string
+func (f *BloomFilter) AddString(data string) {
+	f.Add([]byte(data))
+}
+
+//test data may be added or not
+//false means definitely not added
+func (f *BloomFilter) Test(data []byte) bool {
+	h1, h2 := murmur3.Sum128(data)
+	f.RLock()
+	defer f.RUnlock()
+	return f.test(h1, h2)
+}
+
+//test string
+func (f *BloomFilter) TestString(data string) bool {
+	return f.Test([]byte(data))
+}
+
+//test data and add it, return test result before add
+//used for dedup
+func (f *BloomFilter) TestAndAdd(data []byte) bool {
+	h1, h2 := murmur3.Sum128(data)
+	f.Lock()
+	defer f.Unlock()
+	existed := f.test(h1, h2)
+	if !existed {
+		f.add(h1, h2)
+	}
+	return existed
+}
+
+//get bits count and hash count
+func (f *BloomFilter) Size() (uint64, uint64) {
+	return f.m, f.k
+}
+
+//get added items count
+func (f *BloomFilter) Added() uint64 {
+	f.RLock()
+	defer f.RUnlock()
+	return f.added
+}
+
+//estimate items count by set bits
+func (f *BloomFilter) Count() uint64 {
+	f.RLock()
+	defer f.RUnlock()
+	setBits := 0
+	for _, word := range f.bits {
+		setBits += bits.OnesCount64(word)
+	}
+	if uint64(setBits) >= f.m {
+		return f.added
+	}
+	m, k := float64(f.m), float64(f.k)
+	return uint64(math.Round(-m / k * math.Log(1 - float64(setBits) / m)))
+}
+
+//estimate current false positive rate
+func (f *BloomFilter) FalseRate() float64 {
+	n := float64(f.Count())
+	m, k := float64(f.m), float64(f.k)
+	return math.Pow(1 - math.Exp(-k * n / m), k)
+}
+
+//merge other filter with the same size
+func (f *BloomFilter) Merge(other *BloomFilter) error {
+	//check
+	if other == nil || other == f {
+		return nil
+	}
+	//copy other first, never hold both lockers
+	other.RLock()
+	m, k, added := other.m, other.k, other.added
+	bits := make([]uint64, len(other.bits))
+	copy(bits, other.bits)
+	other.RUnlock()
+
+	//merge with locker
+	f.Lock()
+	defer f.Unlock()
+	if m != f.m || k != f.k {
+		return ErrSketchMismatch
+	}
+	for i, word := range bits {
+		f.bits[i] |= word
+	}
+	f.added += added
+	return nil
+}
+
+//reset filter
+func (f *BloomFilter) Reset() {
+	f.Lock()
+	defer f.Unlock()
+	f.bits = make([]uint64, len(f.bits))
+	f.added = 0
+}
+
+//encode into binary
+func (f *BloomFilter) MarshalBinary() ([]byte, error) {
+	f.RLock()
+	defer f.RUnlock()
+	buf := newSketchBuffer(bloomMagic)
+	writeUvarint(buf, f.m)
+	writeUvarint(buf, f.k)
+	writeUvarint(buf, f.added)
+	writeUint64s(buf, f.bits)
+	return buf.Bytes(), nil
+}
+
+//decode from binary
+func (f *BloomFilter) UnmarshalBinary(data []byte) error {
+	r, err := newSketchReader(data, bloomMagic)
+	if err != nil {
+		return err
+	}
+	m, k, added := r.uvarint(), r.uvarint(), r.uvarint()
+	words := r.uint64s()
+	if err = r.finish(); err != nil {
+		return err
+	}
+	if m <= 0 || k <= 0 || uint64(len(words)) != (m - 1) / 64 + 1 {
+		return ErrInvalidSketch
+	}
+
+	//replace with locker
+	f.Lock()
+	defer f.Unlock()
+	f.m, f.k, f.added, f.bits = m, k, added, words
+	return nil
+}
+
+//////////////
+//private func
+//////////////
+
+//add hash values, should be called with locker
+func (f *BloomFilter) add(h1, h2 uint64) {
+	for i := uint64(0); i < f.k; i++ {
+		idx := (h1 + i * h2) % f.m
+		f.bits[idx >> 6] |= 1 << (idx & 63)
+	}
+	f.added++
+}
+
+//test hash values, should be called with locker
+func (f *BloomFilter) test(h1, h2 uint64) bool {
+	for i := uint64(0); i < f.k; i++ {
+		idx := (h1 + i * h2) % f.m
+		if f.bits[idx >> 6] & (1 << (idx & 63)) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -6,6 +6,7 @@ package algorithm
  */
 
 import (
+	"errors"
 	"hash/crc32"
 	"sort"
 	"strconv"
@@ -26,6 +27,7 @@ type HashRing struct {
 	ring     []int // Sorted
 	hashMap  map[int]string
 	nodes    map[string]bool
+	weights  map[string]int //node -> weight
 	sync.RWMutex
 }
 
@@ -46,6 +48,7 @@ func NewHashRing(replicas int, fn Hash) *HashRing {
 		hash:     fn,
 		hashMap:  make(map[int]string),
 		nodes: map[string]bool{},
+		weights: map[string]int{},
 	}
 	if m.hash == nil {
 		m.hash = crc32.ChecksumIEEE
@@ -184,18 +187,195 @@ func (m *HashRing) Add(nodes ...string) {
 		if ok {
 			continue
 		}
-		m.nodes[node] = true
-
-		//create batch replicas node
-		for i := 0; i < m.replicas; i++ {
-			//cal virtual hash value
-			hash := int(m.hash([]byte(strconv.Itoa(i) + node)))
-			//add into hash ring
-			m.ring = append(m.ring, hash)
-			//map the hash value and node info
-			m.hashMap[hash] = node
-		}
+		m.addNode(node, DefaultBalancerWeight)
 	}
 	//sort hash ring
 	sort.Ints(m.ring)
-}
\ No newline at end of file
+}
+
+//add member with weight, balancer api
+//virtual nodes count is replicas * weight
+func (m *HashRing) AddMember(member string, weights ...int) error {
+	//check
+	if member == "" {
+		return errors.New("invalid parameter")
+	}
+	weight, err := pickWeight(weights)
+	if err != nil {
+		return err
+	}
+
+	//add with locker
+	m.Lock()
+	defer m.Unlock()
+	if m.nodes[member] {
+		return ErrMemberExists
+	}
+	m.addNode(member, weight)
+	sort.Ints(m.ring)
+	return nil
+}
+
+//remove member, balancer api
+func (m *HashRing) RemoveMember(member string) error {
+	m.Lock()
+	defer m.Unlock()
+	if !m.nodes[member] {
+		return ErrMemberNotFound
+	}
+	m.removeNode(member)
+	return nil
+}
+
+//update weight of member, balancer api
+func (m *HashRing) SetWeight(member string, weight int) error {
+	//check
+	if weight <= 0 {
+		return ErrInvalidWeight
+	}
+
+	//reset virtual nodes with locker
+	m.Lock()
+	defer m.Unlock()
+	if !m.nodes[member] {
+		return ErrMemberNotFound
+	}
+	m.removeNode(member)
+	m.addNode(member, weight)
+	sort.Ints(m.ring)
+	return nil
+}
+
+//locate member for key, balancer api
+func (m *HashRing) Locate(key string) (string, error) {
+	m.RLock()
+	defer m.RUnlock()
+	if len(m.ring) <= 0 {
+		return "", ErrNoMembers
+	}
+	return m.hashMap[m.ring[m.search(key)]], nil
+}
+
+//locate n distinct members for key, balancer api
+func (m *HashRing) LocateN(key string, n int) ([]string, error) {
+	m.RLock()
+	defer m.RUnlock()
+	if err := checkLocateN(n, len(m.nodes)); err != nil {
+		return nil, err
+	}
+	result := walkRing(m.search(key), len(m.ring), n, func(pos int) []string {
+		return []string{m.hashMap[m.ring[pos]]}
+	})
+	return result, nil
+}
+
+//get all members, balancer api
+func (m *HashRing) Members() []string {
+	m.RLock()
+	defer m.RUnlock()
+	return sortedMembers(m.nodes)
+}
+
+//search ring position of key, should be called with locker
+func (m *HashRing) search(key string) int {
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.ring), func(i int) bool { return m.ring[i] >= hash })
+	if idx == len(m.ring) {
+		idx = 0
+	}
+	return idx
+}
+
+//add node virtual hash values, should be called with locker
+//collided virtual node kept by its first owner
+func (m *HashRing) addNode(node string, weight int) {
+	m.nodes[node] = true
+	m.weights[node] = weight
+
+	//create batch replicas node
+	for i := 0; i < m.replicas * weight; i++ {
+		//cal virtual hash value
+		hash := int(m.hash([]byte(strconv.Itoa(i) + node)))
+		if _, ok := m.hashMap[hash]; ok {
+			continue
+		}
+		//add into hash ring
+		m.ring = append(m.ring, hash)
+		//map the hash value and node info
+		m.hashMap[hash] = node
+	}
+}
+
+//remove node virtual hash values, should be called with locker
+//virtual node owned by other node after collision is kept
+func (m *HashRing) removeNode(node string) {
+	for i := 0; i < m.replicas * m.weights[node]; i++ {
+		hash := int(m.hash([]byte(strconv.Itoa(i) + node)))
+		if m.hashMap[hash] != node {
+			continue
+		}
+		idx := sort.SearchInts(m.ring, hash)
+		if idx < len(m.ring) && m.ring[idx] == hash {
+			m.ring = append(m.ring[:idx], m.ring[idx+1:]...)
+		}
+		delete(m.hashMap, hash)
+	}
+	delete(m.nodes, node)
+	delete(m.weights, node)
+}
+
+//export snapshot of members, weights and sorted virtual nodes
+func (m *HashRing) Snapshot() *RingSnapshot {
+	m.RLock()
+	defer m.RUnlock()
+	snap := &RingSnapshot{
+		Kind: SnapshotKindHashRing,
+		Replicas: m.replicas,
+		Members: make([]SnapshotMember, 0, len(m.nodes)),
+		Points: make([]SnapshotPoint, 0, len(m.ring)),
+	}
+	for _, node := range sortedMembers(m.nodes) {
+		snap.Members = append(snap.Members, SnapshotMember{Name: node, Weight: m.weights[node]})
+	}
+	for _, hash := range m.ring {
+		snap.Points = append(snap.Points, SnapshotPoint{Hash: uint64(hash), Member: m.hashMap[hash]})
+	}
+	return snap
+}
+
+//import snapshot, replace all members
+//virtual nodes are rebuilt by local hash function and should match the snapshot
+func (m *HashRing) Restore(snap *RingSnapshot) error {
+	//check
+	if snap == nil || snap.Kind != SnapshotKindHashRing || snap.Replicas <= 0 {
+		return ErrInvalidSnapshot
+	}
+	if err := snap.validate(); err != nil {
+		return err
+	}
+
+	//rebuild and compare
+	tmp := NewHashRing(snap.Replicas, m.hash)
+	for _, member := range snap.Members {
+		tmp.addNode(member.Name, member.Weight)
+	}
+	sort.Ints(tmp.ring)
+	if len(tmp.ring) != len(snap.Points) {
+		return ErrSnapshotMismatch
+	}
+	for i, point := range snap.Points {
+		if uint64(tmp.ring[i]) != point.Hash || tmp.hashMap[tmp.ring[i]] != point.Member {
+			return ErrSnapshotMismatch
+		}
+	}
+
+	//swap with locker
+	m.Lock()
+	defer m.Unlock()
+	m.replicas = tmp.replicas
+	m.ring = tmp.ring
+	m.hashMap = tmp.hashMap
+	m.nodes = tmp.nodes
+	m.weights = tmp.weights
+	return nil
+}
@@ -131,8 +131,13 @@ type Consistent struct {
 	partitionCount uint64
 	loads          map[string]float64
 	members        map[string]*Member
+	weights        map[string]int
 	partitions     map[int]*Member
 	ring           map[uint64]*Member
+
+	// replicaMu guards replicaTables, cached by replica count and reset on change.
+	replicaMu     sync.Mutex
+	replicaTables map[int]map[int][]Member
 }
 
 // New creates and returns a new Consistent object.
@@ -153,6 +158,7 @@ func NewConsistent(members []Member, config Config) *Consistent {
 	c := &Consistent{
 		config:         config,
 		members:        make(map[string]*Member),
+		weights:        make(map[string]int),
 		partitionCount: uint64(config.PartitionCount),
 		ring:           make(map[uint64]*Member),
 	}
@@ -239,10 +245,17 @@ func (c *Consistent) distributePartitions() {
 	}
 	c.partitions = partitions
 	c.loads = loads
+	c.resetReplicaTables()
 }
 
 func (c *Consistent) add(member Member) {
-	for i := 0; i < c.config.ReplicationFactor; i++ {
+	c.addWithWeight(member, DefaultBalancerWeight)
+}
+
+// addWithWeight replicates member ReplicationFactor * weight times on the ring.
+func (c *Consistent) addWithWeight(member Member, weight int) {
+	c.weights[member.String()] = weight
+	for i := 0; i < c.config.ReplicationFactor*weight; i++ {
 		key := []byte(fmt.Sprintf("%s%d", member.String(), i))
 		h := c.hasher.Sum64(key)
 		c.ring[h] = &member
@@ -288,19 +301,112 @@ func (c *Consistent) Remove(name string) {
 		return
 	}
 
-	for i := 0; i < c.config.ReplicationFactor; i++ {
+	c.remove(name)
+	if len(c.members) == 0 {
+		// consistent hash ring is empty now. Reset the partition table.
+		c.partitions = make(map[int]*Member)
+		c.resetReplicaTables()
+		return
+	}
+	c.distributePartitions()
+}
+
+func (c *Consistent) remove(name string) {
+	for i := 0; i < c.config.ReplicationFactor*c.weights[name]; i++ {
 		key := []byte(fmt.Sprintf("%s%d", name, i))
 		h := c.hasher.Sum64(key)
 		delete(c.ring, h)
 		c.delSlice(h)
 	}
 	delete(c.members, name)
-	if len(c.members) == 0 {
-		// consistent hash ring is empty now. Reset the partition table.
-		c.partitions = make(map[int]*Member)
-		return
+	delete(c.weights, name)
+}
+
+// AddMember adds member with weight, it implements Balancer.
+// Weight scales the replicas of member, partitions are still bounded by Load.
+func (c *Consistent) AddMember(member string, weights ...int) error {
+	if member == "" {
+		return errors.New("invalid parameter")
+	}
+	weight, err := pickWeight(weights)
+	if err != nil {
+		return err
 	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.members[member]; ok {
+		return ErrMemberExists
+	}
+	c.addWithWeight(NewNode(member), weight)
 	c.distributePartitions()
+	return nil
+}
+
+// RemoveMember removes member, it implements Balancer.
+func (c *Consistent) RemoveMember(member string) error {
+	c.mu.RLock()
+	_, ok := c.members[member]
+	c.mu.RUnlock()
+	if !ok {
+		return ErrMemberNotFound
+	}
+	c.Remove(member)
+	return nil
+}
+
+// SetWeight updates weight of member, it implements Balancer.
+func (c *Consistent) SetWeight(member string, weight int) error {
+	if weight <= 0 {
+		return ErrInvalidWeight
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, ok := c.members[member]
+	if !ok {
+		return ErrMemberNotFound
+	}
+	org := *m
+	c.remove(member)
+	c.addWithWeight(org, weight)
+	c.distributePartitions()
+	return nil
+}
+
+// Locate finds member for key, it implements Balancer.
+func (c *Consistent) Locate(key string) (string, error) {
+	member := c.LocateKey([]byte(key))
+	if member == nil {
+		return "", ErrNoMembers
+	}
+	return member.String(), nil
+}
+
+// LocateN finds n closest members for key, it implements Balancer.
+func (c *Consistent) LocateN(key string, n int) ([]string, error) {
+	c.mu.RLock()
+	err := checkLocateN(n, len(c.members))
+	c.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	members, err := c.GetClosestN([]byte(key), n)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(members))
+	for _, member := range members {
+		result = append(result, member.String())
+	}
+	return result, nil
+}
+
+// Members returns sorted member names, it implements Balancer.
+func (c *Consistent) Members() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return sortedMembers(c.members)
 }
 
 // LoadDistribution exposes load distribution of members.
@@ -406,4 +512,86 @@ func (c *Consistent) GetClosestN(key []byte, count int) ([]Member, error) {
 // This may be useful to find members for replication.
 func (c *Consistent) GetClosestNForPartition(partID, count int) ([]Member, error) {
 	return c.getClosestN(partID, count)
-}
\ No newline at end of file
+}
+
+// Snapshot exports members, weights, topology labels and the partition table.
+func (c *Consistent) Snapshot() *RingSnapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	snap := &RingSnapshot{
+		Kind:           SnapshotKindConsistent,
+		Replicas:       c.config.ReplicationFactor,
+		PartitionCount: int(c.partitionCount),
+		Members:        make([]SnapshotMember, 0, len(c.members)),
+	}
+	for _, name := range sortedMembers(c.members) {
+		member := SnapshotMember{Name: name, Weight: c.weights[name]}
+		if m, ok := (*c.members[name]).(TopologyMember); ok {
+			topo := m.Topology()
+			member.Zone, member.Rack, member.Host = topo.Zone, topo.Rack, topo.Host
+		}
+		snap.Members = append(snap.Members, member)
+	}
+	if len(c.members) > 0 {
+		snap.Partitions = make([]string, c.partitionCount)
+		for partID := range snap.Partitions {
+			if member, ok := c.partitions[partID]; ok {
+				snap.Partitions[partID] = (*member).String()
+			}
+		}
+	}
+	return snap
+}
+
+// Restore imports snapshot and replaces all members. The partition table of snapshot
+// is taken as is, so all nodes agree on partition owners. Existing members keep their
+// Member value, new members are created by NewTopologyNode if they have topology
+// labels, otherwise by NewNode.
+func (c *Consistent) Restore(snap *RingSnapshot) error {
+	if snap == nil || snap.Kind != SnapshotKindConsistent {
+		return ErrInvalidSnapshot
+	}
+	if err := snap.validate(); err != nil {
+		return err
+	}
+	if snap.PartitionCount != int(c.partitionCount) || snap.Replicas != c.config.ReplicationFactor {
+		return ErrSnapshotMismatch
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	org := c.members
+	c.resetReplicaTables()
+	c.members = make(map[string]*Member)
+	c.weights = make(map[string]int)
+	c.ring = make(map[uint64]*Member)
+	c.sortedSet = nil
+	for _, member := range snap.Members {
+		if m, ok := org[member.Name]; ok {
+			c.addWithWeight(*m, member.Weight)
+			continue
+		}
+		if topo := member.Topology(); topo != (Topology{}) {
+			c.addWithWeight(NewTopologyNode(member.Name, topo.Zone, topo.Rack, topo.Host), member.Weight)
+			continue
+		}
+		c.addWithWeight(NewNode(member.Name), member.Weight)
+	}
+	if len(snap.Partitions) == 0 {
+		c.partitions = make(map[int]*Member)
+		c.loads = make(map[string]float64)
+		if len(c.members) > 0 {
+			c.distributePartitions()
+		}
+		return nil
+	}
+	c.partitions = make(map[int]*Member, len(snap.Partitions))
+	c.loads = make(map[string]float64)
+	for partID, owner := range snap.Partitions {
+		c.partitions[partID] = c.members[owner]
+		c.loads[owner]++
+	}
+	return nil
+}
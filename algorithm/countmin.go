@@ -0,0 +1,278 @@
+package algorithm
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/spaolacci/murmur3"
+)
+
+/*
+ * count-min sketch
+ * - width is e / epsilon, depth is ln(1 / delta)
+ * - estimate never less than real count, over count less than epsilon * total
+ *   with probability 1 - delta
+ * - optional top k heavy hitters tracking
+ * - merge sketches with the same size by counters sum
+ */
+
+//inter macro define
+const (
+	DefaultCountMinEpsilon = 0.001
+	DefaultCountMinDelta   = 0.01
+	countMinMagic          = "TLCM"
+)
+
+//heavy hitter
+type HeavyHitter struct {
+	Key   string `json:"key"`
+	Count uint64 `json:"count"`
+}
+
+//face info
+type CountMinSketch struct {
+	width    uint64
+	depth    uint64
+	counters []uint64 //row * width + column
+	total    uint64
+	topK     int
+	heavy    map[string]uint64 //key -> estimate count
+	sync.RWMutex
+}
+
+//construct
+//epsilon -> over count rate of total, default DefaultCountMinEpsilon
+//delta -> failure probability, default DefaultCountMinDelta
+//topKs -> heavy hitters count for tracking, default 0 means no tracking
+func NewCountMinSketch(epsilon, delta float64, topKs ...int) *CountMinSketch {
+	if epsilon <= 0 || epsilon >= 1 {
+		epsilon = DefaultCountMinEpsilon
+	}
+	if delta <= 0 || delta >= 1 {
+		delta = DefaultCountMinDelta
+	}
+	width := uint64(math.Ceil(math.E / epsilon))
+	depth := uint64(math.Ceil(math.Log(1 / delta)))
+	return NewCountMinSketchWithSize(width, depth, topKs...)
+}
+
+//construct with width and depth
+func NewCountMinSketchWithSize(width, depth uint64, topKs ...int) *CountMinSketch {
+	if width <= 0 {
+		width = 1
+	}
+	if depth <= 0 {
+		depth = 1
+	}
+	this := &CountMinSketch{
+		width: width,
+		depth: depth,
+		counters: make([]uint64, width * depth),
+		heavy: map[string]uint64{},
+	}
+	if topKs != nil && len(topKs) > 0 && topKs[0] > 0 {
+		this.topK = topKs[0]
+	}
+	return this
+}
+
+//add data with count, return estimate count after add
+func (f *CountMinSketch) Add(data []byte, count uint64) uint64 {
+	h1, h2 := murmur3.Sum128(data)
+	f.Lock()
+	defer f.Unlock()
+	estimate := uint64(math.MaxUint64)
+	for i := uint64(0); i < f.depth; i++ {
+		idx := i * f.width + (h1 + i * h2) % f.width
+		f.counters[idx] += count
+		if f.counters[idx] < estimate {
+			estimate = f.counters[idx]
+		}
+	}
+	f.total += count
+	f.trackHeavy(string(data), estimate)
+	return estimate
+}
+
+//add string with count
+func (f *CountMinSketch) AddString(data string, count uint64) uint64 {
+	return f.Add([]byte(data), count)
+}
+
+//estimate count of data
+func (f *CountMinSketch) Estimate(data []byte) uint64 {
+	f.RLock()
+	defer f.RUnlock()
+	return f.estimate(data)
+}
+
+//estimate count of string
+func (f *CountMinSketch) EstimateString(data string) uint64 {
+	return f.Estimate([]byte(data))
+}
+
+//get total count
+func (f *CountMinSketch) Total() uint64 {
+	f.RLock()
+	defer f.RUnlock()
+	return f.total
+}
+
+//get width and depth
+func (f *CountMinSketch) Size() (uint64, uint64) {
+	return f.width, f.depth
+}
+
+//get heavy hitters, sorted by count desc
+func (f *CountMinSketch) TopK() []HeavyHitter {
+	f.RLock()
+	defer f.RUnlock()
+	result := make([]HeavyHitter, 0, len(f.heavy))
+	for key, count := range f.heavy {
+		result = append(result, HeavyHitter{Key: key, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+//merge other sketch with the same size
+//heavy hitters are estimated again after merge
+func (f *CountMinSketch) Merge(other *CountMinSketch) error {
+	//check
+	if other == nil || other == f {
+		return nil
+	}
+	//copy other first, never hold both lockers
+	other.RLock()
+	width, depth, total := other.width, other.depth, other.total
+	counters := make([]uint64, len(other.counters))
+	copy(counters, other.counters)
+	otherKeys := make([]string, 0, len(other.heavy))
+	for key := range other.heavy {
+		otherKeys = append(otherKeys, key)
+	}
+	other.RUnlock()
+
+	//merge with locker
+	f.Lock()
+	defer f.Unlock()
+	if width != f.width || depth != f.depth {
+		return ErrSketchMismatch
+	}
+	for i, count := range counters {
+		f.counters[i] += count
+	}
+	f.total += total
+	keys := make([]string, 0, len(f.heavy) + len(otherKeys))
+	for key := range f.heavy {
+		keys = append(keys, key)
+	}
+	keys = append(keys, otherKeys...)
+	for _, key := range keys {
+		f.trackHeavy(key, f.estimate([]byte(key)))
+	}
+	return nil
+}
+
+//reset sketch
+func (f *CountMinSketch) Reset() {
+	f.Lock()
+	defer f.Unlock()
+	f.counters = make([]uint64, len(f.counters))
+	f.total = 0
+	f.heavy = map[string]uint64{}
+}
+
+//encode into binary
+func (f *CountMinSketch) MarshalBinary() ([]byte, error) {
+	f.RLock()
+	defer f.RUnlock()
+	buf := newSketchBuffer(countMinMagic)
+	writeUvarint(buf, f.width)
+	writeUvarint(buf, f.depth)
+	writeUvarint(buf, f.total)
+	writeUvarint(buf, uint64(f.topK))
+	writeUint64s(buf, f.counters)
+	writeUvarint(buf, uint64(len(f.heavy)))
+	for _, key := range sortedMembers(f.heavy) {
+		writeUvarint(buf, uint64(len(key)))
+		buf.WriteString(key)
+		writeUvarint(buf, f.heavy[key])
+	}
+	return buf.Bytes(), nil
+}
+
+//decode from binary
+func (f *CountMinSketch) UnmarshalBinary(data []byte) error {
+	r, err := newSketchReader(data, countMinMagic)
+	if err != nil {
+		return err
+	}
+	width, depth, total, topK := r.uvarint(), r.uvarint(), r.uvarint(), r.uvarint()
+	counters := r.uint64s()
+	heavyCount := r.count()
+	heavy := make(map[string]uint64, heavyCount)
+	for i := 0; i < heavyCount; i++ {
+		key := string(r.bytes(r.count()))
+		heavy[key] = r.uvarint()
+	}
+	if err = r.finish(); err != nil {
+		return err
+	}
+	if width <= 0 || depth <= 0 || width > uint64(len(counters)) || depth > uint64(len(counters)) ||
+		uint64(len(counters)) != width * depth ||
+		topK > uint64(math.MaxInt32) || uint64(len(heavy)) > topK {
+		return ErrInvalidSketch
+	}
+
+	//replace with locker
+	f.Lock()
+	defer f.Unlock()
+	f.width, f.depth, f.total, f.topK = width, depth, total, int(topK)
+	f.counters = counters
+	f.heavy = heavy
+	return nil
+}
+
+//////////////
+//private func
+//////////////
+
+//estimate count, should be called with locker
+func (f *CountMinSketch) estimate(data []byte) uint64 {
+	h1, h2 := murmur3.Sum128(data)
+	estimate := uint64(math.MaxUint64)
+	for i := uint64(0); i < f.depth; i++ {
+		if count := f.counters[i * f.width + (h1 + i * h2) % f.width]; count < estimate {
+			estimate = count
+		}
+	}
+	return estimate
+}
+
+//track heavy hitter, replace the min one if full, should be called with locker
+func (f *CountMinSketch) trackHeavy(key string, estimate uint64) {
+	if f.topK <= 0 {
+		return
+	}
+	if _, ok := f.heavy[key]; ok || len(f.heavy) < f.topK {
+		f.heavy[key] = estimate
+		return
+	}
+	minKey, minCount := "", uint64(math.MaxUint64)
+	for k, count := range f.heavy {
+		if count < minCount || (count == minCount && k > minKey) {
+			minKey, minCount = k, count
+		}
+	}
+	if estimate > minCount {
+		delete(f.heavy, minKey)
+		f.heavy[key] = estimate
+	}
+}
@@ -0,0 +1,325 @@
+package algorithm
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"sync"
+
+	"github.com/cespare/xxhash"
+)
+
+/*
+ * cuckoo filter
+ * - base on `https://www.cs.cmu.edu/~dga/papers/cuckoo-conext2014.pdf`
+ * - 4 slots per bucket, 16 bits fingerprint, false positive rate about 0.012%
+ * - support delete, only delete data added before
+ * - one victim slot keeps the last kicked fingerprint when filter is full
+ */
+
+//inter macro define
+const (
+	DefaultCuckooCapacity = 100000
+	cuckooBucketSize      = 4
+	cuckooMaxKicks        = 500
+	cuckooMagic           = "TLCF"
+)
+
+//face info
+type CuckooFilter struct {
+	slots      []uint16 //bucket * cuckooBucketSize + slot -> fingerprint, 0 is empty
+	bucketMask uint64
+	count      uint64
+	victim     uint16 //kicked out fingerprint when full
+	victimIdx  uint64
+	rand       *rand.Rand
+	sync.RWMutex
+}
+
+//construct
+//capacity -> max items count, default DefaultCuckooCapacity
+func NewCuckooFilter(capacity uint64) *CuckooFilter {
+	if capacity <= 0 {
+		capacity = DefaultCuckooCapacity
+	}
+
+	//buckets count is power of 2
+	buckets := uint64(1)
+	for buckets * cuckooBucketSize < capacity {
+		buckets <<= 1
+	}
+	this := &CuckooFilter{
+		slots: make([]uint16, buckets * cuckooBucketSize),
+		bucketMask: buckets - 1,
+		rand: rand.New(rand.NewSource(int64(buckets))),
+	}
+	return this
+}
+
+//add data, return false if filter is full
+func (f *CuckooFilter) Add(data []byte) bool {
+	fp, i1, i2 := f.locate(data)
+	f.Lock()
+	defer f.Unlock()
+	if f.victim != 0 {
+		return false
+	}
+	if f.insert(fp, i1) || f.insert(fp, i2) {
+		f.count++
+		return true
+	}
+
+	//kick out fingerprint randomly
+	idx := i1
+	if f.rand.Intn(2) == 1 {
+		idx = i2
+	}
+	for n := 0; n < cuckooMaxKicks; n++ {
+		slot := idx * cuckooBucketSize + uint64(f.rand.Intn(cuckooBucketSize))
+		fp, f.slots[slot] = f.slots[slot], fp
+		idx = f.altIndex(idx, fp)
+		if f.insert(fp, idx) {
+			f.count++
+			return true
+		}
+	}
+
+	//keep last kicked fingerprint as victim
+	f.victim, f.victimIdx = fp, idx
+	f.count++
+	return true
+}
+
+//add string
+func (f *CuckooFilter) AddString(data string) bool {
+	return f.Add([]byte(data))
+}
+
+//test data may be added or not
+//false means definitely not added
+func (f *CuckooFilter) Test(data []byte) bool {
+	fp, i1, i2 := f.locate(data)
+	f.RLock()
+	defer f.RUnlock()
+	if f.victim == fp && (f.victimIdx == i1 || f.victimIdx == i2) {
+		return true
+	}
+	return f.find(fp, i1) >= 0 || f.find(fp, i2) >= 0
+}
+
+//test string
+func (f *CuckooFilter) TestString(data string) bool {
+	return f.Test([]byte(data))
+}
+
+//delete data, return false if not found
+func (f *CuckooFilter) Delete(data []byte) bool {
+	fp, i1, i2 := f.locate(data)
+	f.Lock()
+	defer f.Unlock()
+	if f.victim == fp && (f.victimIdx == i1 || f.victimIdx == i2) {
+		f.victim = 0
+		f.count--
+		return true
+	}
+	for _, idx := range []uint64{i1, i2} {
+		if slot := f.find(fp, idx); slot >= 0 {
+			f.slots[slot] = 0
+			f.count--
+			f.reinsertVictim()
+			return true
+		}
+	}
+	return false
+}
+
+//delete string
+func (f *CuckooFilter) DeleteString(data string) bool {
+	return f.Delete([]byte(data))
+}
+
+//get items count
+func (f *CuckooFilter) Count() uint64 {
+	f.RLock()
+	defer f.RUnlock()
+	return f.count
+}
+
+//get load factor
+func (f *CuckooFilter) LoadFactor() float64 {
+	f.RLock()
+	defer f.RUnlock()
+	return float64(f.count) / float64(len(f.slots))
+}
+
+//merge other filter with the same size
+//return ErrSketchMismatch if size different, and false if filter is full
+func (f *CuckooFilter) Merge(other *CuckooFilter) (bool, error) {
+	//check
+	if other == nil || other == f {
+		return true, nil
+	}
+	//copy other first, never hold both lockers
+	other.RLock()
+	bucketMask, victim, victimIdx := other.bucketMask, other.victim, other.victimIdx
+	slots := make([]uint16, len(other.slots))
+	copy(slots, other.slots)
+	other.RUnlock()
+
+	//copy fingerprints with locker
+	f.Lock()
+	defer f.Unlock()
+	if bucketMask != f.bucketMask {
+		return false, ErrSketchMismatch
+	}
+	for slot, fp := range slots {
+		if fp == 0 {
+			continue
+		}
+		if !f.mergeOne(fp, uint64(slot / cuckooBucketSize)) {
+			return false, nil
+		}
+	}
+	if victim != 0 && !f.mergeOne(victim, victimIdx) {
+		return false, nil
+	}
+	return true, nil
+}
+
+//reset filter
+func (f *CuckooFilter) Reset() {
+	f.Lock()
+	defer f.Unlock()
+	f.slots = make([]uint16, len(f.slots))
+	f.count = 0
+	f.victim, f.victimIdx = 0, 0
+}
+
+//encode into binary
+func (f *CuckooFilter) MarshalBinary() ([]byte, error) {
+	f.RLock()
+	defer f.RUnlock()
+	buf := newSketchBuffer(cuckooMagic)
+	writeUvarint(buf, f.bucketMask + 1)
+	writeUvarint(buf, f.count)
+	writeUvarint(buf, uint64(f.victim))
+	writeUvarint(buf, f.victimIdx)
+	tmp := make([]byte, 2)
+	for _, fp := range f.slots {
+		binary.LittleEndian.PutUint16(tmp, fp)
+		buf.Write(tmp)
+	}
+	return buf.Bytes(), nil
+}
+
+//decode from binary
+func (f *CuckooFilter) UnmarshalBinary(data []byte) error {
+	r, err := newSketchReader(data, cuckooMagic)
+	if err != nil {
+		return err
+	}
+	buckets, count := r.uvarint(), r.uvarint()
+	victim, victimIdx := r.uvarint(), r.uvarint()
+	if r.err == nil && (buckets <= 0 || buckets & (buckets - 1) != 0 ||
+		buckets > uint64(len(r.data)) || buckets * cuckooBucketSize * 2 != uint64(len(r.data)) ||
+		victim > 0xffff || victimIdx >= buckets) {
+		return ErrInvalidSketch
+	}
+	raw := r.bytes(len(r.data))
+	if err = r.finish(); err != nil {
+		return err
+	}
+	slots := make([]uint16, buckets * cuckooBucketSize)
+	for i := range slots {
+		slots[i] = binary.LittleEndian.Uint16(raw[i*2:])
+	}
+
+	//replace with locker
+	f.Lock()
+	defer f.Unlock()
+	f.slots = slots
+	f.bucketMask = buckets - 1
+	f.count = count
+	f.victim, f.victimIdx = uint16(victim), victimIdx
+	if f.rand == nil {
+		f.rand = rand.New(rand.NewSource(int64(buckets)))
+	}
+	return nil
+}
+
+//////////////
+//private func
+//////////////
+
+//get fingerprint and two bucket index of data
+func (f *CuckooFilter) locate(data []byte) (uint16, uint64, uint64) {
+	h := xxhash.Sum64(data)
+	fp := uint16(h >> 48)
+	if fp == 0 {
+		fp = 1
+	}
+	f.RLock()
+	defer f.RUnlock()
+	i1 := h & f.bucketMask
+	return fp, i1, f.altIndex(i1, fp)
+}
+
+//get alternate bucket index
+func (f *CuckooFilter) altIndex(idx uint64, fp uint16) uint64 {
+	return (idx ^ (uint64(fp) * 0x5bd1e995)) & f.bucketMask
+}
+
+//insert fingerprint into bucket, should be called with locker
+func (f *CuckooFilter) insert(fp uint16, idx uint64) bool {
+	base := idx * cuckooBucketSize
+	for i := uint64(0); i < cuckooBucketSize; i++ {
+		if f.slots[base+i] == 0 {
+			f.slots[base+i] = fp
+			return true
+		}
+	}
+	return false
+}
+
+//find fingerprint slot in bucket, -1 if not found
+func (f *CuckooFilter) find(fp uint16, idx uint64) int {
+	base := idx * cuckooBucketSize
+	for i := uint64(0); i < cuckooBucketSize; i++ {
+		if f.slots[base+i] == fp {
+			return int(base + i)
+		}
+	}
+	return -1
+}
+
+//merge one fingerprint of bucket, should be called with locker
+func (f *CuckooFilter) mergeOne(fp uint16, idx uint64) bool {
+	if f.victim != 0 {
+		return false
+	}
+	if f.insert(fp, idx) || f.insert(fp, f.altIndex(idx, fp)) {
+		f.count++
+		return true
+	}
+	for n := 0; n < cuckooMaxKicks; n++ {
+		slot := idx * cuckooBucketSize + uint64(f.rand.Intn(cuckooBucketSize))
+		fp, f.slots[slot] = f.slots[slot], fp
+		idx = f.altIndex(idx, fp)
+		if f.insert(fp, idx) {
+			f.count++
+			return true
+		}
+	}
+	f.victim, f.victimIdx = fp, idx
+	f.count++
+	return false
+}
+
+//try put victim back after delete, should be called with locker
+func (f *CuckooFilter) reinsertVictim() {
+	if f.victim == 0 {
+		return
+	}
+	if f.insert(f.victim, f.victimIdx) || f.insert(f.victim, f.altIndex(f.victimIdx, f.victim)) {
+		f.victim, f.victimIdx = 0, 0
+	}
+}
@@ -0,0 +1,483 @@
+package algorithm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+/*
+ * structural diff base on reflection
+ * - walk structs, maps, slices, arrays, pointers and interfaces
+ * - pointer and map cycles detected, pair on current path treated as equal
+ * - only exported fields compared, including promoted fields of embedded struct
+ * - struct without exported fields
+ *   (like time.Time) compared as a whole by `Equal` method or deep equal
+ * - anonymous struct field without json name is flatten, like encoding/json
+ * - nil and empty slice or map treated as equal
+ * - changes could be converted into json patch (rfc 6902)
+ */
+
+//change type
+const (
+	ChangeUpdate = "update"
+	ChangeAdd    = "add"
+	ChangeRemove = "remove"
+)
+
+//path step kind
+const (
+	PathField = iota
+	PathIndex
+	PathKey
+)
+
+//one step of change path
+type PathStep struct {
+	Kind  int
+	Name  string      //field name for PathField
+	JSON  string      //json name of field, or string of map key
+	Index int         //for PathIndex
+	Key   interface{} //for PathKey
+}
+
+//one change
+type Change struct {
+	Type string
+	Path []PathStep
+	From interface{} //nil for ChangeAdd
+	To   interface{} //nil for ChangeRemove
+}
+
+//changes list
+type Changes []Change
+
+//json patch operation
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+//inter type
+type (
+	diffVisit struct {
+		a, b uintptr
+		typ  reflect.Type
+	}
+	differ struct {
+		visited map[diffVisit]bool //pairs on current recursion path
+		changes Changes
+	}
+)
+
+//diff two values, return changes from a to b
+func Diff(a, b interface{}) Changes {
+	d := &differ{
+		visited: map[diffVisit]bool{},
+		changes: Changes{},
+	}
+	d.diff(nil, reflect.ValueOf(a), reflect.ValueOf(b))
+	return d.changes
+}
+
+//check two values structural equal or not
+func Equal(a, b interface{}) bool {
+	return len(Diff(a, b)) == 0
+}
+
+//get path string of change, like `.Players[3].Score`
+func (c Change) PathString() string {
+	if len(c.Path) == 0 {
+		return "."
+	}
+	sb := strings.Builder{}
+	for _, step := range c.Path {
+		switch step.Kind {
+		case PathField:
+			sb.WriteString("." + step.Name)
+		case PathIndex:
+			sb.WriteString("[" + strconv.Itoa(step.Index) + "]")
+		default:
+			if key, ok := step.Key.(string); ok {
+				sb.WriteString("[" + strconv.Quote(key) + "]")
+			}else{
+				sb.WriteString("[" + step.JSON + "]")
+			}
+		}
+	}
+	return sb.String()
+}
+
+//get json pointer of change, like `/players/3/score`
+func (c Change) JSONPointer() string {
+	sb := strings.Builder{}
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	for _, step := range c.Path {
+		sb.WriteString("/")
+		if step.Kind == PathIndex {
+			sb.WriteString(strconv.Itoa(step.Index))
+			continue
+		}
+		sb.WriteString(escaper.Replace(step.JSON))
+	}
+	return sb.String()
+}
+
+//format change, like `.Players[3].Score: 10 -> 12`
+func (c Change) String() string {
+	switch c.Type {
+	case ChangeAdd:
+		return fmt.Sprintf("%s: + %s", c.PathString(), diffRepr(c.To))
+	case ChangeRemove:
+		return fmt.Sprintf("%s: - %s", c.PathString(), diffRepr(c.From))
+	default:
+		return fmt.Sprintf("%s: %s -> %s", c.PathString(), diffRepr(c.From), diffRepr(c.To))
+	}
+}
+
+//format changes, one change per line
+func (cs Changes) String() string {
+	lines := make([]string, 0, len(cs))
+	for _, c := range cs {
+		lines = append(lines, c.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+//convert into json patch operations
+func (cs Changes) Patch() ([]PatchOperation, error) {
+	result := make([]PatchOperation, 0, len(cs))
+	for _, c := range cs {
+		op := PatchOperation{Path: c.JSONPointer()}
+		value := c.To
+		switch c.Type {
+		case ChangeAdd:
+			op.Op = "add"
+		case ChangeRemove:
+			op.Op = "remove"
+			value = nil
+		default:
+			op.Op = "replace"
+		}
+		if op.Op != "remove" {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			op.Value = data
+		}
+		result = append(result, op)
+	}
+	return result, nil
+}
+
+//convert into json patch document
+func (cs Changes) JSONPatch() ([]byte, error) {
+	ops, err := cs.Patch()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(ops)
+}
+
+///////////////
+//private func
+///////////////
+
+//diff two values at path
+func (d *differ) diff(path []PathStep, a, b reflect.Value) {
+	//check valid and type
+	if !a.IsValid() || !b.IsValid() {
+		if a.IsValid() != b.IsValid() || !(isNilValue(a) && isNilValue(b)) {
+			d.update(path, a, b)
+		}
+		return
+	}
+	if a.Type() != b.Type() {
+		d.update(path, a, b)
+		return
+	}
+
+	switch a.Kind() {
+	case reflect.Ptr:
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				d.update(path, a, b)
+			}
+			return
+		}
+		if d.visit(a, b) {
+			return
+		}
+		defer d.leave(a, b)
+		d.diff(path, a.Elem(), b.Elem())
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				d.update(path, a, b)
+			}
+			return
+		}
+		d.diff(path, a.Elem(), b.Elem())
+	case reflect.Struct:
+		d.diffStruct(path, a, b)
+	case reflect.Slice, reflect.Array:
+		if a.Kind() == reflect.Slice && a.Type().Elem().Kind() == reflect.Uint8 {
+			if string(a.Bytes()) != string(b.Bytes()) {
+				d.update(path, a, b)
+			}
+			return
+		}
+		d.diffList(path, a, b)
+	case reflect.Map:
+		if a.Len() > 0 && b.Len() > 0 {
+			if d.visit(a, b) {
+				return
+			}
+			defer d.leave(a, b)
+		}
+		d.diffMap(path, a, b)
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		if a.IsNil() != b.IsNil() || (a.Kind() != reflect.Func && a.Pointer() != b.Pointer()) {
+			d.update(path, a, b)
+		}
+	case reflect.Float32, reflect.Float64:
+		fa, fb := a.Float(), b.Float()
+		if fa != fb && !(math.IsNaN(fa) && math.IsNaN(fb)) {
+			d.update(path, a, b)
+		}
+	case reflect.Complex64, reflect.Complex128:
+		if a.Complex() != b.Complex() {
+			d.update(path, a, b)
+		}
+	default:
+		if !basicEqual(a, b) {
+			d.update(path, a, b)
+		}
+	}
+}
+
+//diff struct fields
+func (d *differ) diffStruct(path []PathStep, a, b reflect.Value) {
+	t := a.Type()
+	if !hasExportedField(t) {
+		if !opaqueEqual(a, b) {
+			d.update(path, a, b)
+		}
+		return
+	}
+	a, b = addressable(a), addressable(b)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fa, fb := a.Field(i), b.Field(i)
+		if !field.IsExported() {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct && a.CanAddr() && b.CanAddr() {
+				//embedded unexported struct, exported fields are promoted
+				d.diff(path, exportedField(fa), exportedField(fb))
+			}
+			continue
+		}
+		jsonName, tagged := fieldJSONName(field)
+		if field.Anonymous && !tagged && indirectKind(field.Type) == reflect.Struct {
+			//flatten embedded struct
+			d.diff(path, fa, fb)
+			continue
+		}
+		d.diff(appendStep(path, PathStep{Kind: PathField, Name: field.Name, JSON: jsonName}), fa, fb)
+	}
+}
+
+//diff slice or array elements
+func (d *differ) diffList(path []PathStep, a, b reflect.Value) {
+	common := a.Len()
+	if b.Len() < common {
+		common = b.Len()
+	}
+	for i := 0; i < common; i++ {
+		d.diff(appendStep(path, PathStep{Kind: PathIndex, Index: i}), a.Index(i), b.Index(i))
+	}
+	for i := common; i < b.Len(); i++ {
+		d.add(appendStep(path, PathStep{Kind: PathIndex, Index: i}), b.Index(i))
+	}
+	//remove from tail, keep json patch applicable
+	for i := a.Len() - 1; i >= common; i-- {
+		d.remove(appendStep(path, PathStep{Kind: PathIndex, Index: i}), a.Index(i))
+	}
+}
+
+//diff map values, keys sorted
+func (d *differ) diffMap(path []PathStep, a, b reflect.Value) {
+	keys := make(map[string]reflect.Value)
+	for _, m := range []reflect.Value{a, b} {
+		iter := m.MapRange()
+		for iter.Next() {
+			keys[Repr(iter.Key().Interface())] = iter.Key()
+		}
+	}
+	for _, name := range sortedMembers(keys) {
+		key := keys[name]
+		step := PathStep{Kind: PathKey, JSON: name, Key: key.Interface()}
+		va, vb := a.MapIndex(key), b.MapIndex(key)
+		switch {
+		case !va.IsValid():
+			d.add(appendStep(path, step), vb)
+		case !vb.IsValid():
+			d.remove(appendStep(path, step), va)
+		default:
+			d.diff(appendStep(path, step), va, vb)
+		}
+	}
+}
+
+//check and mark pair on current path, return true if in cycle
+func (d *differ) visit(a, b reflect.Value) bool {
+	v := diffVisit{a: a.Pointer(), b: b.Pointer(), typ: a.Type()}
+	if d.visited[v] {
+		return true
+	}
+	d.visited[v] = true
+	return false
+}
+
+//unmark pair when leave current path
+func (d *differ) leave(a, b reflect.Value) {
+	delete(d.visited, diffVisit{a: a.Pointer(), b: b.Pointer(), typ: a.Type()})
+}
+
+//record update change
+func (d *differ) update(path []PathStep, a, b reflect.Value) {
+	d.changes = append(d.changes, Change{
+		Type: ChangeUpdate,
+		Path: path,
+		From: valueOf(a),
+		To: valueOf(b),
+	})
+}
+
+//record add change
+func (d *differ) add(path []PathStep, b reflect.Value) {
+	d.changes = append(d.changes, Change{Type: ChangeAdd, Path: path, To: valueOf(b)})
+}
+
+//record remove change
+func (d *differ) remove(path []PathStep, a reflect.Value) {
+	d.changes = append(d.changes, Change{Type: ChangeRemove, Path: path, From: valueOf(a)})
+}
+
+//append step into new path
+func appendStep(path []PathStep, step PathStep) []PathStep {
+	result := make([]PathStep, len(path), len(path) + 1)
+	copy(result, path)
+	return append(result, step)
+}
+
+//get addressable copy of struct value
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() || !v.CanInterface() {
+		return v
+	}
+	result := reflect.New(v.Type()).Elem()
+	result.Set(v)
+	return result
+}
+
+//get readable value of embedded unexported field, should be addressable
+func exportedField(v reflect.Value) reflect.Value {
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
+
+//get interface of value, nil if invalid
+func valueOf(v reflect.Value) interface{} {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
+
+//check value is invalid or nil
+func isNilValue(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
+
+//compare basic kind values
+func basicEqual(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.String:
+		return a.String() == b.String()
+	}
+	return reflect.DeepEqual(valueOf(a), valueOf(b))
+}
+
+//compare struct without exported field, by `Equal` method or deep equal
+func opaqueEqual(a, b reflect.Value) bool {
+	if !a.CanInterface() {
+		return true
+	}
+	if method, ok := a.Type().MethodByName("Equal"); ok &&
+		method.Type.NumIn() == 2 && method.Type.In(1) == a.Type() &&
+		method.Type.NumOut() == 1 && method.Type.Out(0).Kind() == reflect.Bool {
+		return a.Method(method.Index).Call([]reflect.Value{b})[0].Bool()
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+//check struct has exported field or not
+func hasExportedField(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
+//get json name of field, and json name tagged or not
+func fieldJSONName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	name := strings.Split(tag, ",")[0]
+	if name == "" || name == "-" {
+		return field.Name, false
+	}
+	return name, true
+}
+
+//get kind of type, pointer dereferenced
+func indirectKind(t reflect.Type) reflect.Kind {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem().Kind()
+	}
+	return t.Kind()
+}
+
+//format value of change
+func diffRepr(v interface{}) string {
+	if v == nil {
+		return "nil"
+	}
+	val := reflect.ValueOf(v)
+	if val.Kind() == reflect.Ptr && val.IsNil() {
+		return "nil"
+	}
+	if val.Kind() == reflect.String {
+		return strconv.Quote(val.String())
+	}
+	return Repr(v)
+}
@@ -0,0 +1,178 @@
+package algorithm
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"sync"
+
+	"github.com/cespare/xxhash"
+)
+
+/*
+ * hyperloglog cardinality estimator
+ * - 2^precision registers, standard error about 1.04 / sqrt(2^precision)
+ * - linear counting for small cardinality
+ * - merge estimators with the same precision by register max
+ */
+
+//inter macro define
+const (
+	DefaultHLLPrecision = 14
+	MinHLLPrecision     = 4
+	MaxHLLPrecision     = 18
+	hllMagic            = "TLHL"
+)
+
+//face info
+type HyperLogLog struct {
+	precision uint8
+	registers []uint8
+	sync.RWMutex
+}
+
+//construct
+//precisions -> registers count is 2^precision, in [4, 18], default DefaultHLLPrecision
+func NewHyperLogLog(precisions ...uint8) *HyperLogLog {
+	precision := uint8(DefaultHLLPrecision)
+	if precisions != nil && len(precisions) > 0 &&
+		precisions[0] >= MinHLLPrecision && precisions[0] <= MaxHLLPrecision {
+		precision = precisions[0]
+	}
+	this := &HyperLogLog{
+		precision: precision,
+		registers: make([]uint8, 1 << precision),
+	}
+	return this
+}
+
+//add data
+func (f *HyperLogLog) Add(data []byte) {
+	h := xxhash.Sum64(data)
+	f.Lock()
+	defer f.Unlock()
+	idx := h >> (64 - f.precision)
+	rank := uint8(bits.LeadingZeros64(h << f.precision | 1 << (f.precision - 1)) + 1)
+	if rank > f.registers[idx] {
+		f.registers[idx] = rank
+	}
+}
+
+//add string
+func (f *HyperLogLog) AddString(data string) {
+	f.Add([]byte(data))
+}
+
+//estimate cardinality
+func (f *HyperLogLog) Count() uint64 {
+	f.RLock()
+	defer f.RUnlock()
+	m := float64(len(f.registers))
+	sum, zeros := 0.0, 0
+	for _, rank := range f.registers {
+		sum += 1 / float64(uint64(1) << rank)
+		if rank == 0 {
+			zeros++
+		}
+	}
+	estimate := hllAlpha(len(f.registers)) * m * m / sum
+
+	//small range correction
+	if estimate <= 2.5 * m && zeros > 0 {
+		estimate = m * math.Log(m / float64(zeros))
+	}
+	return uint64(math.Round(estimate))
+}
+
+//get precision
+func (f *HyperLogLog) Precision() uint8 {
+	return f.precision
+}
+
+//merge other estimator with the same precision
+func (f *HyperLogLog) Merge(other *HyperLogLog) error {
+	//check
+	if other == nil || other == f {
+		return nil
+	}
+	//copy other first, never hold both lockers
+	other.RLock()
+	precision := other.precision
+	registers := make([]uint8, len(other.registers))
+	copy(registers, other.registers)
+	other.RUnlock()
+
+	//merge with locker
+	f.Lock()
+	defer f.Unlock()
+	if precision != f.precision {
+		return ErrSketchMismatch
+	}
+	for i, rank := range registers {
+		if rank > f.registers[i] {
+			f.registers[i] = rank
+		}
+	}
+	return nil
+}
+
+//reset estimator
+func (f *HyperLogLog) Reset() {
+	f.Lock()
+	defer f.Unlock()
+	f.registers = make([]uint8, len(f.registers))
+}
+
+//encode into binary
+func (f *HyperLogLog) MarshalBinary() ([]byte, error) {
+	f.RLock()
+	defer f.RUnlock()
+	buf := newSketchBuffer(hllMagic)
+	buf.WriteByte(f.precision)
+	buf.Write(f.registers)
+	return buf.Bytes(), nil
+}
+
+//decode from binary
+func (f *HyperLogLog) UnmarshalBinary(data []byte) error {
+	r, err := newSketchReader(data, hllMagic)
+	if err != nil {
+		return err
+	}
+	if len(r.data) < 1 {
+		return ErrInvalidSketch
+	}
+	precision := r.data[0]
+	if precision < MinHLLPrecision || precision > MaxHLLPrecision ||
+		len(r.data) - 1 != 1 << precision {
+		return ErrInvalidSketch
+	}
+	registers := make([]uint8, 1 << precision)
+	copy(registers, r.data[1:])
+	for _, rank := range registers {
+		if rank > 64 - precision + 1 {
+			return errors.New("invalid register value")
+		}
+	}
+
+	//replace with locker
+	f.Lock()
+	defer f.Unlock()
+	f.precision = precision
+	f.registers = registers
+	return nil
+}
+
+//get alpha constant of registers count
+func hllAlpha(m int) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	default:
+		return 0.7213 / (1 + 1.079 / float64(m))
+	}
+}
@@ -0,0 +1,195 @@
+package algorithm
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+)
+
+/*
+ * jump consistent hash
+ * - base on `https://arxiv.org/abs/1406.2294`
+ * - member with weight N occupies N buckets
+ * - removed buckets are filled by the tail buckets
+ * - removing tail buckets moves only keys of removed member, about 1/n keys
+ * - removing other buckets also moves keys of the tail bucket, about 2/n keys
+ */
+
+//face info
+type JumpHash struct {
+	hasher  Hasher
+	buckets []string       //bucket -> member
+	weights map[string]int //member -> weight
+	sync.RWMutex
+}
+
+//construct
+//hashers -> key hasher, default xxhash
+func NewJumpHash(hashers ...Hasher) *JumpHash {
+	var (
+		hasher Hasher = MyHasher{}
+	)
+	if hashers != nil && len(hashers) > 0 && hashers[0] != nil {
+		hasher = hashers[0]
+	}
+	this := &JumpHash{
+		hasher: hasher,
+		buckets: []string{},
+		weights: map[string]int{},
+	}
+	return this
+}
+
+//jump hash, return bucket of key in [0, buckets)
+func Jump(key uint64, buckets int) int {
+	var (
+		b, j int64 = -1, 0
+	)
+	if buckets <= 0 {
+		return -1
+	}
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+//add member with weight, balancer api
+func (f *JumpHash) AddMember(member string, weights ...int) error {
+	//check
+	if member == "" {
+		return errors.New("invalid parameter")
+	}
+	weight, err := pickWeight(weights)
+	if err != nil {
+		return err
+	}
+
+	//add with locker
+	f.Lock()
+	defer f.Unlock()
+	if _, ok := f.weights[member]; ok {
+		return ErrMemberExists
+	}
+	f.addBuckets(member, weight)
+	return nil
+}
+
+//remove member, balancer api
+func (f *JumpHash) RemoveMember(member string) error {
+	f.Lock()
+	defer f.Unlock()
+	if _, ok := f.weights[member]; !ok {
+		return ErrMemberNotFound
+	}
+	f.removeBuckets(member, f.weights[member])
+	delete(f.weights, member)
+	return nil
+}
+
+//update weight of member, balancer api
+func (f *JumpHash) SetWeight(member string, weight int) error {
+	//check
+	if weight <= 0 {
+		return ErrInvalidWeight
+	}
+
+	//update with locker
+	f.Lock()
+	defer f.Unlock()
+	oldWeight, ok := f.weights[member]
+	if !ok {
+		return ErrMemberNotFound
+	}
+	if weight > oldWeight {
+		f.addBuckets(member, weight - oldWeight)
+	}else if weight < oldWeight {
+		f.removeBuckets(member, oldWeight - weight)
+	}
+	f.weights[member] = weight
+	return nil
+}
+
+//locate member for key, balancer api
+func (f *JumpHash) Locate(key string) (string, error) {
+	f.RLock()
+	defer f.RUnlock()
+	if len(f.buckets) <= 0 {
+		return "", ErrNoMembers
+	}
+	bucket := Jump(f.hasher.Sum64([]byte(key)), len(f.buckets))
+	return f.buckets[bucket], nil
+}
+
+//locate n distinct members for key, balancer api
+//replicas are located by salted key, then filled by next buckets
+func (f *JumpHash) LocateN(key string, n int) ([]string, error) {
+	f.RLock()
+	defer f.RUnlock()
+	if err := checkLocateN(n, len(f.weights)); err != nil {
+		return nil, err
+	}
+	first := Jump(f.hasher.Sum64([]byte(key)), len(f.buckets))
+	result := []string{f.buckets[first]}
+	picked := map[string]bool{f.buckets[first]: true}
+	for i := 1; len(result) < n && i <= n * len(f.buckets); i++ {
+		saltKey := key + "#" + strconv.Itoa(i)
+		member := f.buckets[Jump(f.hasher.Sum64([]byte(saltKey)), len(f.buckets))]
+		if !picked[member] {
+			picked[member] = true
+			result = append(result, member)
+		}
+	}
+	if len(result) < n {
+		//fill by next buckets
+		rest := walkRing(first, len(f.buckets), len(f.weights), func(pos int) []string {
+			return []string{f.buckets[pos]}
+		})
+		for _, member := range rest {
+			if len(result) >= n {
+				break
+			}
+			if !picked[member] {
+				picked[member] = true
+				result = append(result, member)
+			}
+		}
+	}
+	return result, nil
+}
+
+//get all members, balancer api
+func (f *JumpHash) Members() []string {
+	f.RLock()
+	defer f.RUnlock()
+	return sortedMembers(f.weights)
+}
+
+//////////////
+//private func
+//////////////
+
+//append buckets of member, should be called with locker
+func (f *JumpHash) addBuckets(member string, num int) {
+	for i := 0; i < num; i++ {
+		f.buckets = append(f.buckets, member)
+	}
+	f.weights[member] += num
+}
+
+//remove buckets of member from tail side, should be called with locker
+//tail bucket of member just dropped, others filled by the last bucket
+//removed bucket filled by the last bucket
+func (f *JumpHash) removeBuckets(member string, num int) {
+	for i := len(f.buckets) - 1; i >= 0 && num > 0; i-- {
+		if f.buckets[i] != member {
+			continue
+		}
+		last := len(f.buckets) - 1
+		f.buckets[i] = f.buckets[last]
+		f.buckets = f.buckets[:last]
+		num--
+	}
+}
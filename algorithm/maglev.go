@@ -0,0 +1,205 @@
+package algorithm
+
+import (
+	"errors"
+	"sync"
+)
+
+/*
+ * maglev consistent hash
+ * - base on `https://research.google/pubs/pub44824/`
+ * - lookup table rebuilt when members changed
+ * - member with weight N takes N slots each populate round
+ */
+
+//inter macro define
+const (
+	DefaultMaglevTableSize = 65537
+)
+
+//face info
+type Maglev struct {
+	hasher    Hasher
+	tableSize int
+	table     []string       //slot -> member
+	weights   map[string]int //member -> weight
+	sync.RWMutex
+}
+
+//construct
+//tableSize -> lookup table size, should be prime, default 65537
+//hashers -> key hasher, default xxhash
+func NewMaglev(tableSize int, hashers ...Hasher) *Maglev {
+	var (
+		hasher Hasher = MyHasher{}
+	)
+	if hashers != nil && len(hashers) > 0 && hashers[0] != nil {
+		hasher = hashers[0]
+	}
+	if tableSize <= 0 {
+		tableSize = DefaultMaglevTableSize
+	}
+	for !isPrime(tableSize) {
+		tableSize++
+	}
+	this := &Maglev{
+		hasher: hasher,
+		tableSize: tableSize,
+		weights: map[string]int{},
+	}
+	return this
+}
+
+//add member with weight, balancer api
+func (f *Maglev) AddMember(member string, weights ...int) error {
+	//check
+	if member == "" {
+		return errors.New("invalid parameter")
+	}
+	weight, err := pickWeight(weights)
+	if err != nil {
+		return err
+	}
+
+	//add with locker
+	f.Lock()
+	defer f.Unlock()
+	if _, ok := f.weights[member]; ok {
+		return ErrMemberExists
+	}
+	f.weights[member] = weight
+	f.populate()
+	return nil
+}
+
+//remove member, balancer api
+func (f *Maglev) RemoveMember(member string) error {
+	f.Lock()
+	defer f.Unlock()
+	if _, ok := f.weights[member]; !ok {
+		return ErrMemberNotFound
+	}
+	delete(f.weights, member)
+	f.populate()
+	return nil
+}
+
+//update weight of member, balancer api
+func (f *Maglev) SetWeight(member string, weight int) error {
+	//check
+	if weight <= 0 {
+		return ErrInvalidWeight
+	}
+
+	//update with locker
+	f.Lock()
+	defer f.Unlock()
+	if _, ok := f.weights[member]; !ok {
+		return ErrMemberNotFound
+	}
+	f.weights[member] = weight
+	f.populate()
+	return nil
+}
+
+//locate member for key, balancer api
+func (f *Maglev) Locate(key string) (string, error) {
+	f.RLock()
+	defer f.RUnlock()
+	if len(f.table) <= 0 {
+		return "", ErrNoMembers
+	}
+	return f.table[f.slotOf(key)], nil
+}
+
+//locate n distinct members for key, balancer api
+//replicas are picked from the next slots
+func (f *Maglev) LocateN(key string, n int) ([]string, error) {
+	f.RLock()
+	defer f.RUnlock()
+	if err := checkLocateN(n, len(f.weights)); err != nil {
+		return nil, err
+	}
+	result := walkRing(f.slotOf(key), len(f.table), n, func(pos int) []string {
+		return []string{f.table[pos]}
+	})
+	return result, nil
+}
+
+//get all members, balancer api
+func (f *Maglev) Members() []string {
+	f.RLock()
+	defer f.RUnlock()
+	return sortedMembers(f.weights)
+}
+
+//////////////
+//private func
+//////////////
+
+//get slot of key, should be called with locker
+func (f *Maglev) slotOf(key string) int {
+	return int(f.hasher.Sum64([]byte(key)) % uint64(f.tableSize))
+}
+
+//populate lookup table, should be called with locker
+func (f *Maglev) populate() {
+	//check
+	if len(f.weights) <= 0 {
+		f.table = nil
+		return
+	}
+
+	//gen permutation paras of sorted members
+	members := sortedMembers(f.weights)
+	size := uint64(f.tableSize)
+	offsets := make([]uint64, len(members))
+	skips := make([]uint64, len(members))
+	nexts := make([]uint64, len(members))
+	for i, member := range members {
+		offsets[i] = f.hasher.Sum64([]byte(member + "#offset")) % size
+		skips[i] = f.hasher.Sum64([]byte(member + "#skip")) % (size - 1) + 1
+	}
+
+	//fill slots round by round
+	entries := make([]int, f.tableSize)
+	for i := range entries {
+		entries[i] = -1
+	}
+	filled := 0
+	for filled < f.tableSize {
+		for i, member := range members {
+			for w := 0; w < f.weights[member] && filled < f.tableSize; w++ {
+				slot := (offsets[i] + nexts[i] * skips[i]) % size
+				for entries[slot] >= 0 {
+					nexts[i]++
+					slot = (offsets[i] + nexts[i] * skips[i]) % size
+				}
+				entries[slot] = i
+				nexts[i]++
+				filled++
+			}
+		}
+	}
+
+	//sync table
+	table := make([]string, f.tableSize)
+	for slot, idx := range entries {
+		table[slot] = members[idx]
+	}
+	f.table = table
+}
+
+//check number is prime or not
+func isPrime(num int) bool {
+	if num < 2 {
+		return false
+	}
+	for i := 2; i * i <= num; i++ {
+		if num % i == 0 {
+			return false
+		}
+	}
+	return true
+}
+
@@ -2,6 +2,7 @@ package algorithm
 
 import (
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"github.com/spaolacci/murmur3"
 	"sort"
@@ -44,6 +45,7 @@ type (
 		keys     []uint64
 		ring     map[uint64][]any
 		nodes    map[string]PlaceholderType
+		counts   map[string]int // node repr -> replicas
 		lock     sync.RWMutex
 	}
 )
@@ -68,6 +70,7 @@ func NewCustomConsistentHash(replicas int, fn Func) *ConsistentHash {
 		replicas: replicas,
 		ring:     make(map[uint64][]any),
 		nodes:    make(map[string]PlaceholderType),
+		counts:   make(map[string]int),
 	}
 }
 
@@ -87,10 +90,15 @@ func (h *ConsistentHash) AddWithReplicas(node any, replicas int) {
 		replicas = h.replicas
 	}
 
-	nodeRepr := repr(node)
 	h.lock.Lock()
 	defer h.lock.Unlock()
+	h.addReplicas(node, replicas)
+}
+
+func (h *ConsistentHash) addReplicas(node any, replicas int) {
+	nodeRepr := repr(node)
 	h.addNode(nodeRepr)
+	h.counts[nodeRepr] = replicas
 
 	for i := 0; i < replicas; i++ {
 		hash := h.hashFunc([]byte(nodeRepr + strconv.Itoa(i)))
@@ -117,6 +125,10 @@ func (h *ConsistentHash) Get(v any) (any, bool) {
 	h.lock.RLock()
 	defer h.lock.RUnlock()
 
+	return h.getNode(v)
+}
+
+func (h *ConsistentHash) getNode(v any) (any, bool) {
 	if len(h.ring) == 0 {
 		return nil, false
 	}
@@ -145,12 +157,19 @@ func (h *ConsistentHash) Remove(node any) {
 
 	h.lock.Lock()
 	defer h.lock.Unlock()
+	h.remove(nodeRepr)
+}
 
+func (h *ConsistentHash) remove(nodeRepr string) {
 	if !h.containsNode(nodeRepr) {
 		return
 	}
 
-	for i := 0; i < h.replicas; i++ {
+	replicas, ok := h.counts[nodeRepr]
+	if !ok || replicas < h.replicas {
+		replicas = h.replicas
+	}
+	for i := 0; i < replicas; i++ {
 		hash := h.hashFunc([]byte(nodeRepr + strconv.Itoa(i)))
 		index := sort.Search(len(h.keys), func(i int) bool {
 			return h.keys[i] >= hash
@@ -191,6 +210,98 @@ func (h *ConsistentHash) containsNode(nodeRepr string) bool {
 
 func (h *ConsistentHash) removeNode(nodeRepr string) {
 	delete(h.nodes, nodeRepr)
+	delete(h.counts, nodeRepr)
+}
+
+// AddMember adds member with weight, it implements Balancer.
+// Replicas of member is h.replicas * weight, not truncated.
+func (h *ConsistentHash) AddMember(member string, weights ...int) error {
+	if member == "" {
+		return errors.New("invalid parameter")
+	}
+	weight, err := pickWeight(weights)
+	if err != nil {
+		return err
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.containsNode(repr(member)) {
+		return ErrMemberExists
+	}
+	h.addReplicas(member, h.replicas*weight)
+	return nil
+}
+
+// RemoveMember removes member, it implements Balancer.
+func (h *ConsistentHash) RemoveMember(member string) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if !h.containsNode(repr(member)) {
+		return ErrMemberNotFound
+	}
+	h.remove(repr(member))
+	return nil
+}
+
+// SetWeight updates weight of member, it implements Balancer.
+func (h *ConsistentHash) SetWeight(member string, weight int) error {
+	if weight <= 0 {
+		return ErrInvalidWeight
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if !h.containsNode(repr(member)) {
+		return ErrMemberNotFound
+	}
+	h.remove(repr(member))
+	h.addReplicas(member, h.replicas*weight)
+	return nil
+}
+
+// Locate finds member for key, it implements Balancer.
+func (h *ConsistentHash) Locate(key string) (string, error) {
+	node, ok := h.Get(key)
+	if !ok {
+		return "", ErrNoMembers
+	}
+	return repr(node), nil
+}
+
+// LocateN finds n distinct members for key clockwise, it implements Balancer.
+func (h *ConsistentHash) LocateN(key string, n int) ([]string, error) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	if err := checkLocateN(n, len(h.nodes)); err != nil {
+		return nil, err
+	}
+
+	// the first member should be the same as Get
+	first, _ := h.getNode(key)
+	hash := h.hashFunc([]byte(repr(key)))
+	start := sort.Search(len(h.keys), func(i int) bool {
+		return h.keys[i] >= hash
+	}) % len(h.keys)
+	result := walkRing(start, len(h.keys), n, func(pos int) []string {
+		if pos == start {
+			return []string{repr(first)}
+		}
+		nodes := h.ring[h.keys[pos]]
+		members := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			members = append(members, repr(node))
+		}
+		return members
+	})
+	return result, nil
+}
+
+// Members returns sorted member names, it implements Balancer.
+func (h *ConsistentHash) Members() []string {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return sortedMembers(h.nodes)
 }
 
 func innerRepr(node any) string {
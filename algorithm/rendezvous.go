@@ -0,0 +1,164 @@
+package algorithm
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+)
+
+/*
+ * weighted rendezvous hash, aka highest random weight
+ * - score = -weight / ln(hash(member, key) / 2^64)
+ * - key located to member with the highest score
+ * - only keys of changed member moved
+ */
+
+//face info
+type Rendezvous struct {
+	hasher  Hasher
+	weights map[string]int //member -> weight
+	members []string       //sorted members
+	sync.RWMutex
+}
+
+//inter type
+type rendezvousScore struct {
+	member string
+	score  float64
+}
+
+//construct
+//hashers -> key hasher, default xxhash
+func NewRendezvous(hashers ...Hasher) *Rendezvous {
+	var (
+		hasher Hasher = MyHasher{}
+	)
+	if hashers != nil && len(hashers) > 0 && hashers[0] != nil {
+		hasher = hashers[0]
+	}
+	this := &Rendezvous{
+		hasher: hasher,
+		weights: map[string]int{},
+		members: []string{},
+	}
+	return this
+}
+
+//add member with weight, balancer api
+func (f *Rendezvous) AddMember(member string, weights ...int) error {
+	//check
+	if member == "" {
+		return errors.New("invalid parameter")
+	}
+	weight, err := pickWeight(weights)
+	if err != nil {
+		return err
+	}
+
+	//add with locker
+	f.Lock()
+	defer f.Unlock()
+	if _, ok := f.weights[member]; ok {
+		return ErrMemberExists
+	}
+	f.weights[member] = weight
+	f.members = sortedMembers(f.weights)
+	return nil
+}
+
+//remove member, balancer api
+func (f *Rendezvous) RemoveMember(member string) error {
+	f.Lock()
+	defer f.Unlock()
+	if _, ok := f.weights[member]; !ok {
+		return ErrMemberNotFound
+	}
+	delete(f.weights, member)
+	f.members = sortedMembers(f.weights)
+	return nil
+}
+
+//update weight of member, balancer api
+func (f *Rendezvous) SetWeight(member string, weight int) error {
+	//check
+	if weight <= 0 {
+		return ErrInvalidWeight
+	}
+
+	//update with locker
+	f.Lock()
+	defer f.Unlock()
+	if _, ok := f.weights[member]; !ok {
+		return ErrMemberNotFound
+	}
+	f.weights[member] = weight
+	return nil
+}
+
+//locate member for key, balancer api
+func (f *Rendezvous) Locate(key string) (string, error) {
+	var (
+		target string
+		maxScore = math.Inf(-1)
+	)
+	f.RLock()
+	defer f.RUnlock()
+	if len(f.members) <= 0 {
+		return "", ErrNoMembers
+	}
+	for _, member := range f.members {
+		score := f.score(member, key)
+		if score > maxScore {
+			maxScore = score
+			target = member
+		}
+	}
+	return target, nil
+}
+
+//locate n distinct members for key, balancer api
+//members sorted by score desc
+func (f *Rendezvous) LocateN(key string, n int) ([]string, error) {
+	f.RLock()
+	defer f.RUnlock()
+	if err := checkLocateN(n, len(f.members)); err != nil {
+		return nil, err
+	}
+	scores := make([]rendezvousScore, 0, len(f.members))
+	for _, member := range f.members {
+		scores = append(scores, rendezvousScore{
+			member: member,
+			score: f.score(member, key),
+		})
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].score > scores[j].score
+	})
+	result := make([]string, 0, n)
+	for _, v := range scores[:n] {
+		result = append(result, v.member)
+	}
+	return result, nil
+}
+
+//get all members, balancer api
+func (f *Rendezvous) Members() []string {
+	f.RLock()
+	defer f.RUnlock()
+	result := make([]string, len(f.members))
+	copy(result, f.members)
+	return result
+}
+
+//////////////
+//private func
+//////////////
+
+//calculate weighted score of member for key, should be called with locker
+func (f *Rendezvous) score(member, key string) float64 {
+	hash := f.hasher.Sum64([]byte(member + "#" + key))
+	//map hash into (0, 1)
+	x := (float64(hash >> 11) + 0.5) / float64(uint64(1) << 53)
+	return -float64(f.weights[member]) / math.Log(x)
+}
@@ -3,6 +3,7 @@ package algorithm
 import (
 	"errors"
 	"github.com/liangyaopei/consistent"
+	"sort"
 )
 
 /*
@@ -13,12 +14,21 @@ import (
 //face info
 type Ring struct {
 	hashRing *consistent.HashRing
+	hashFn   consistent.HashFn
 }
 
 //construct
-func NewRing() *Ring {
+//hashFns -> hash function of ring, default consistent.DefaultHashFn
+func NewRing(hashFns ...consistent.HashFn) *Ring {
+	var (
+		hashFn = consistent.DefaultHashFn
+	)
+	if len(hashFns) > 0 && hashFns[0] != nil {
+		hashFn = hashFns[0]
+	}
 	this := &Ring{
-		hashRing: consistent.New(nil, consistent.DefaultHashFn),
+		hashRing: consistent.New(nil, hashFn),
+		hashFn: hashFn,
 	}
 	return this
 }
@@ -97,4 +107,99 @@ func (f *Ring) DelNode(node string) error {
 	//call base func
 	f.hashRing.DelNode(node)
 	return nil
-}
\ No newline at end of file
+}
+
+//add member with weight, balancer api
+//base ring weight is weight * DefaultRingReplicas
+func (f *Ring) AddMember(member string, weights ...int) error {
+	//check
+	if member == "" {
+		return errors.New("invalid parameter")
+	}
+	weight, err := pickWeight(weights)
+	if err != nil {
+		return err
+	}
+	if _, ok := f.hashRing.GetNodeWeight()[member]; ok {
+		return ErrMemberExists
+	}
+
+	//call base func
+	f.hashRing.AddNodeWeight(member, weight * DefaultRingReplicas)
+	return nil
+}
+
+//remove member, balancer api
+func (f *Ring) RemoveMember(member string) error {
+	//check
+	if _, ok := f.hashRing.GetNodeWeight()[member]; !ok {
+		return ErrMemberNotFound
+	}
+
+	//call base func
+	f.hashRing.DelNode(member)
+	return nil
+}
+
+//update weight of member, balancer api
+func (f *Ring) SetWeight(member string, weight int) error {
+	//check
+	if weight <= 0 {
+		return ErrInvalidWeight
+	}
+	if _, ok := f.hashRing.GetNodeWeight()[member]; !ok {
+		return ErrMemberNotFound
+	}
+
+	//del and add again
+	f.hashRing.DelNode(member)
+	f.hashRing.AddNodeWeight(member, weight * DefaultRingReplicas)
+	return nil
+}
+
+//locate member for key, balancer api
+func (f *Ring) Locate(key string) (string, error) {
+	if len(f.hashRing.GetNodeWeight()) <= 0 {
+		return "", ErrNoMembers
+	}
+	return f.hashRing.LocateKeyStr(key), nil
+}
+
+//locate n distinct members for key, balancer api
+func (f *Ring) LocateN(key string, n int) ([]string, error) {
+	//get snapshot of base ring
+	ring := map[uint64]string{}
+	for node, hashes := range f.hashRing.GetHashRing() {
+		for _, hash := range hashes {
+			ring[hash] = node
+		}
+	}
+	if err := checkLocateN(n, len(f.hashRing.GetNodeWeight())); err != nil {
+		return nil, err
+	}
+	hashes := make([]uint64, 0, len(ring))
+	for hash := range ring {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i] < hashes[j]
+	})
+
+	//walk from key position
+	keyHash := f.hashFn([]byte(key))
+	start := sort.Search(len(hashes), func(i int) bool {
+		return hashes[i] >= keyHash
+	}) % len(hashes)
+	result := walkRing(start, len(hashes), n, func(pos int) []string {
+		return []string{ring[hashes[pos]]}
+	})
+	if len(result) < n {
+		return nil, ErrInsufficientMemberCount
+	}
+	return result, nil
+}
+
+//get all members, balancer api
+func (f *Ring) Members() []string {
+	return sortedMembers(f.hashRing.GetNodeWeight())
+}
@@ -0,0 +1,81 @@
+package algorithm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+/*
+ * probabilistic data structure common
+ * - BloomFilter, CuckooFilter, HyperLogLog and CountMinSketch
+ * - all implement encoding.BinaryMarshaler and encoding.BinaryUnmarshaler,
+ *   could be stored by util.Gob or redis directly
+ * - binary form: 4 bytes magic, 1 byte version, uvarint fields, little endian arrays
+ */
+
+//inter macro define
+const (
+	sketchVersion = 1
+)
+
+//inter error define
+var (
+	ErrSketchMismatch = errors.New("sketch parameters mismatch")
+	ErrInvalidSketch  = errors.New("invalid sketch data")
+)
+
+//create sketch binary buffer with header
+func newSketchBuffer(magic string) *bytes.Buffer {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString(magic)
+	buf.WriteByte(sketchVersion)
+	return buf
+}
+
+//check sketch binary header, return body reader
+func newSketchReader(data []byte, magic string) (*snapshotReader, error) {
+	headLen := len(magic) + 1
+	if len(data) < headLen || string(data[:len(magic)]) != magic {
+		return nil, ErrInvalidSketch
+	}
+	if data[len(magic)] != sketchVersion {
+		return nil, errors.New("unsupported sketch version")
+	}
+	return &snapshotReader{data: data[headLen:]}, nil
+}
+
+//write uint64 array
+func writeUint64s(buf *bytes.Buffer, values []uint64) {
+	tmp := make([]byte, 8)
+	writeUvarint(buf, uint64(len(values)))
+	for _, v := range values {
+		binary.LittleEndian.PutUint64(tmp, v)
+		buf.Write(tmp)
+	}
+}
+
+//read uint64 array
+func (r *snapshotReader) uint64s() []uint64 {
+	n := r.count()
+	data := r.bytes(n * 8)
+	if r.err != nil {
+		return nil
+	}
+	result := make([]uint64, n)
+	for i := range result {
+		result[i] = binary.LittleEndian.Uint64(data[i*8:])
+	}
+	return result
+}
+
+//check all data read
+func (r *snapshotReader) finish() error {
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.data) > 0 {
+		return ErrInvalidSketch
+	}
+	return nil
+}
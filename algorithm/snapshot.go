@@ -0,0 +1,486 @@
+package algorithm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"math"
+	"sort"
+)
+
+/*
+ * hash ring snapshot
+ * - export and import state of Consistent, HashRing and XConsistent
+ * - compact binary form and json form, binary form has crc32 checksum
+ * - snapshot implements encoding.BinaryMarshaler, could be published by redis.PubSub directly
+ * - diff two snapshots, report moved partitions or moved key hash ranges
+ * - hash function is not included, nodes should use the same one
+ * - topology labels of members included, version 1 snapshot without labels still readable
+ */
+
+//snapshot kind
+const (
+	SnapshotKindConsistent  = "consistent"
+	SnapshotKindHashRing    = "hashring"
+	SnapshotKindXConsistent = "xconsistent"
+)
+
+//inter macro define
+const (
+	snapshotMagic             = "TLRS"
+	snapshotVersion           = 2
+	snapshotVersionNoTopology = 1 //members without topology labels
+)
+
+//inter error define
+var (
+	ErrInvalidSnapshot  = errors.New("invalid snapshot")
+	ErrSnapshotMismatch = errors.New("snapshot mismatch")
+)
+
+//snapshot kind and binary code
+var snapshotKindCodes = map[string]byte{
+	SnapshotKindConsistent:  1,
+	SnapshotKindHashRing:    2,
+	SnapshotKindXConsistent: 3,
+}
+
+//member of snapshot
+//for XConsistent, weight is the virtual node count
+//topology labels kept for TopologyMember of Consistent
+type SnapshotMember struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+	Zone   string `json:"zone,omitempty"`
+	Rack   string `json:"rack,omitempty"`
+	Host   string `json:"host,omitempty"`
+}
+
+//virtual node of ring snapshot
+type SnapshotPoint struct {
+	Hash   uint64 `json:"hash"`
+	Member string `json:"member"`
+}
+
+//ring state snapshot
+type RingSnapshot struct {
+	Kind           string           `json:"kind"`
+	Replicas       int              `json:"replicas,omitempty"`
+	PartitionCount int              `json:"partitionCount,omitempty"`
+	Members        []SnapshotMember `json:"members"`
+	Partitions     []string         `json:"partitions,omitempty"` //partition id -> owner, Consistent only
+	Points         []SnapshotPoint  `json:"points,omitempty"`     //sorted virtual nodes, rings only
+}
+
+//partition owner changed
+type PartitionMove struct {
+	PartitionID int    `json:"partitionId"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+}
+
+//key hash range owner changed, keys with hash in [Start, End]
+type RangeMove struct {
+	Start uint64 `json:"start"`
+	End   uint64 `json:"end"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+//diff of two snapshots
+type RingDiff struct {
+	Kind       string          `json:"kind"`
+	Added      []string        `json:"added,omitempty"`
+	Removed    []string        `json:"removed,omitempty"`
+	Reweighted []string        `json:"reweighted,omitempty"`
+	Partitions []PartitionMove `json:"partitions,omitempty"`
+	Ranges     []RangeMove     `json:"ranges,omitempty"`
+}
+
+//parse snapshot, binary or json format
+func ParseRingSnapshot(data []byte) (*RingSnapshot, error) {
+	snap := &RingSnapshot{}
+	if bytes.HasPrefix(data, []byte(snapshotMagic)) {
+		if err := snap.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		return snap, nil
+	}
+	if err := snap.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+//diff two snapshots of the same kind
+//from or to could be nil, means empty ring
+func DiffRingSnapshot(from, to *RingSnapshot) (*RingDiff, error) {
+	//check
+	if from == nil && to == nil {
+		return nil, errors.New("invalid parameter")
+	}
+	if from == nil {
+		from = &RingSnapshot{Kind: to.Kind, PartitionCount: to.PartitionCount}
+	}
+	if to == nil {
+		to = &RingSnapshot{Kind: from.Kind, PartitionCount: from.PartitionCount}
+	}
+	if from.Kind != to.Kind {
+		return nil, ErrSnapshotMismatch
+	}
+
+	//diff members
+	diff := &RingDiff{Kind: from.Kind}
+	fromWeights := from.memberWeights()
+	toWeights := to.memberWeights()
+	for _, member := range sortedMembers(toWeights) {
+		weight, ok := fromWeights[member]
+		if !ok {
+			diff.Added = append(diff.Added, member)
+		}else if weight != toWeights[member] {
+			diff.Reweighted = append(diff.Reweighted, member)
+		}
+	}
+	for _, member := range sortedMembers(fromWeights) {
+		if _, ok := toWeights[member]; !ok {
+			diff.Removed = append(diff.Removed, member)
+		}
+	}
+
+	//diff partitions or ranges
+	switch from.Kind {
+	case SnapshotKindConsistent:
+		if from.PartitionCount != to.PartitionCount {
+			return nil, ErrSnapshotMismatch
+		}
+		for partID := 0; partID < from.PartitionCount; partID++ {
+			fromOwner, toOwner := from.partitionOwner(partID), to.partitionOwner(partID)
+			if fromOwner != toOwner {
+				diff.Partitions = append(diff.Partitions, PartitionMove{
+					PartitionID: partID,
+					From: fromOwner,
+					To: toOwner,
+				})
+			}
+		}
+	case SnapshotKindHashRing, SnapshotKindXConsistent:
+		diff.Ranges = diffRingPoints(from, to)
+	default:
+		return nil, ErrInvalidSnapshot
+	}
+	return diff, nil
+}
+
+//check diff is empty or not
+func (d *RingDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Reweighted) == 0 &&
+		len(d.Partitions) == 0 && len(d.Ranges) == 0
+}
+
+//decode snapshot from json, with validation
+func (s *RingSnapshot) UnmarshalJSON(data []byte) error {
+	type plain RingSnapshot
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		return err
+	}
+	return s.validate()
+}
+
+//encode snapshot into compact binary
+//format: magic, version, kind, replicas, partition count, members,
+//partitions as member index, points as hash delta and member index, crc32
+func (s *RingSnapshot) MarshalBinary() ([]byte, error) {
+	//check
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	memberIdx := make(map[string]uint64, len(s.Members))
+	for i, member := range s.Members {
+		memberIdx[member.Name] = uint64(i)
+	}
+
+	//write header and members
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString(snapshotMagic)
+	buf.WriteByte(snapshotVersion)
+	buf.WriteByte(snapshotKindCodes[s.Kind])
+	writeUvarint(buf, uint64(s.Replicas))
+	writeUvarint(buf, uint64(s.PartitionCount))
+	writeUvarint(buf, uint64(len(s.Members)))
+	for _, member := range s.Members {
+		writeUvarint(buf, uint64(len(member.Name)))
+		buf.WriteString(member.Name)
+		writeUvarint(buf, uint64(member.Weight))
+		for _, label := range []string{member.Zone, member.Rack, member.Host} {
+			writeUvarint(buf, uint64(len(label)))
+			buf.WriteString(label)
+		}
+	}
+
+	//write partitions and points
+	writeUvarint(buf, uint64(len(s.Partitions)))
+	for _, owner := range s.Partitions {
+		writeUvarint(buf, memberIdx[owner])
+	}
+	writeUvarint(buf, uint64(len(s.Points)))
+	prev := uint64(0)
+	for _, point := range s.Points {
+		writeUvarint(buf, point.Hash - prev)
+		writeUvarint(buf, memberIdx[point.Member])
+		prev = point.Hash
+	}
+
+	//append checksum
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, crc32.ChecksumIEEE(buf.Bytes()))
+	buf.Write(sum)
+	return buf.Bytes(), nil
+}
+
+//decode snapshot from compact binary
+func (s *RingSnapshot) UnmarshalBinary(data []byte) error {
+	//check header and checksum
+	headLen := len(snapshotMagic) + 2
+	if len(data) < headLen + 4 || string(data[:len(snapshotMagic)]) != snapshotMagic {
+		return ErrInvalidSnapshot
+	}
+	body := data[:len(data)-4]
+	if binary.BigEndian.Uint32(data[len(data)-4:]) != crc32.ChecksumIEEE(body) {
+		return errors.New("snapshot checksum mismatch")
+	}
+	version := body[len(snapshotMagic)]
+	if version != snapshotVersion && version != snapshotVersionNoTopology {
+		return errors.New("unsupported snapshot version")
+	}
+	snap := RingSnapshot{}
+	for kind, code := range snapshotKindCodes {
+		if code == body[len(snapshotMagic)+1] {
+			snap.Kind = kind
+		}
+	}
+
+	//read members
+	r := &snapshotReader{data: body[headLen:]}
+	snap.Replicas = int(r.uvarint())
+	snap.PartitionCount = int(r.uvarint())
+	memberCount := r.count()
+	snap.Members = make([]SnapshotMember, 0, memberCount)
+	for i := 0; i < memberCount; i++ {
+		name := r.bytes(r.count())
+		member := SnapshotMember{
+			Name: string(name),
+			Weight: int(r.uvarint()),
+		}
+		if version != snapshotVersionNoTopology {
+			member.Zone = string(r.bytes(r.count()))
+			member.Rack = string(r.bytes(r.count()))
+			member.Host = string(r.bytes(r.count()))
+		}
+		snap.Members = append(snap.Members, member)
+	}
+
+	//read partitions and points
+	memberAt := func() string {
+		idx := r.uvarint()
+		if idx >= uint64(len(snap.Members)) {
+			r.err = ErrInvalidSnapshot
+			return ""
+		}
+		return snap.Members[idx].Name
+	}
+	if partCount := r.count(); partCount > 0 {
+		snap.Partitions = make([]string, partCount)
+		for i := range snap.Partitions {
+			snap.Partitions[i] = memberAt()
+		}
+	}
+	if pointCount := r.count(); pointCount > 0 {
+		snap.Points = make([]SnapshotPoint, pointCount)
+		prev := uint64(0)
+		for i := range snap.Points {
+			prev += r.uvarint()
+			snap.Points[i] = SnapshotPoint{Hash: prev, Member: memberAt()}
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.data) > 0 {
+		return ErrInvalidSnapshot
+	}
+	if err := snap.validate(); err != nil {
+		return err
+	}
+	*s = snap
+	return nil
+}
+
+///////////////
+//private func
+///////////////
+
+//validate snapshot
+func (s *RingSnapshot) validate() error {
+	if _, ok := snapshotKindCodes[s.Kind]; !ok {
+		return ErrInvalidSnapshot
+	}
+	weights := make(map[string]int, len(s.Members))
+	for _, member := range s.Members {
+		if member.Name == "" || member.Weight <= 0 {
+			return ErrInvalidSnapshot
+		}
+		if _, ok := weights[member.Name]; ok {
+			return ErrInvalidSnapshot
+		}
+		weights[member.Name] = member.Weight
+	}
+	if len(s.Partitions) > 0 && len(s.Partitions) != s.PartitionCount {
+		return ErrInvalidSnapshot
+	}
+	for _, owner := range s.Partitions {
+		if _, ok := weights[owner]; !ok {
+			return ErrInvalidSnapshot
+		}
+	}
+	for i, point := range s.Points {
+		if _, ok := weights[point.Member]; !ok {
+			return ErrInvalidSnapshot
+		}
+		if point.Hash > math.MaxUint32 || (i > 0 && point.Hash < s.Points[i-1].Hash) {
+			return ErrInvalidSnapshot
+		}
+	}
+	return nil
+}
+
+//get topology labels of member
+func (m SnapshotMember) Topology() Topology {
+	return Topology{Zone: m.Zone, Rack: m.Rack, Host: m.Host}
+}
+
+//get member weights map
+func (s *RingSnapshot) memberWeights() map[string]int {
+	result := make(map[string]int, len(s.Members))
+	for _, member := range s.Members {
+		result[member.Name] = member.Weight
+	}
+	return result
+}
+
+//get partition owner, empty if no owner
+func (s *RingSnapshot) partitionOwner(partID int) string {
+	if partID < 0 || partID >= len(s.Partitions) {
+		return ""
+	}
+	return s.Partitions[partID]
+}
+
+//get owner of key hash on ring points
+//XConsistent keeps its original wrap rule, see XConsistent.getPosition
+func (s *RingSnapshot) pointOwner(hash uint64) string {
+	size := len(s.Points)
+	if size <= 0 {
+		return ""
+	}
+	idx := sort.Search(size, func(i int) bool { return s.Points[i].Hash >= hash })
+	if s.Kind == SnapshotKindXConsistent {
+		if idx == size - 1 {
+			idx = 0
+		}else if idx == size {
+			idx = size - 1
+		}
+	}else if idx == size {
+		idx = 0
+	}
+	return s.Points[idx].Member
+}
+
+//diff key hash ranges between two rings
+//owner is constant between adjacent points of both rings
+func diffRingPoints(from, to *RingSnapshot) []RangeMove {
+	//collect sorted boundaries of both rings
+	bounds := make([]uint64, 0, len(from.Points) + len(to.Points))
+	for _, point := range from.Points {
+		bounds = append(bounds, point.Hash)
+	}
+	for _, point := range to.Points {
+		bounds = append(bounds, point.Hash)
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+
+	//check every range, merge adjacent moves
+	result := make([]RangeMove, 0)
+	check := func(start, end uint64) {
+		fromOwner, toOwner := from.pointOwner(end), to.pointOwner(end)
+		if fromOwner == toOwner {
+			return
+		}
+		if last := len(result) - 1; last >= 0 && result[last].End + 1 == start &&
+			result[last].From == fromOwner && result[last].To == toOwner {
+			result[last].End = end
+			return
+		}
+		result = append(result, RangeMove{Start: start, End: end, From: fromOwner, To: toOwner})
+	}
+	start := uint64(0)
+	for i, bound := range bounds {
+		if i > 0 && bound == bounds[i-1] {
+			continue
+		}
+		check(start, bound)
+		start = bound + 1
+	}
+	if len(bounds) == 0 || bounds[len(bounds)-1] < math.MaxUint32 {
+		check(start, math.MaxUint32)
+	}
+	return result
+}
+
+//write uvarint into buffer
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	tmp := make([]byte, binary.MaxVarintLen64)
+	buf.Write(tmp[:binary.PutUvarint(tmp, v)])
+}
+
+//binary snapshot reader, keep first error
+type snapshotReader struct {
+	data []byte
+	err  error
+}
+
+//read uvarint
+func (r *snapshotReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = ErrInvalidSnapshot
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+//read count, never more than left bytes
+func (r *snapshotReader) count() int {
+	v := r.uvarint()
+	if v > uint64(len(r.data)) {
+		r.err = ErrInvalidSnapshot
+		return 0
+	}
+	return int(v)
+}
+
+//read bytes
+func (r *snapshotReader) bytes(n int) []byte {
+	if r.err != nil || n > len(r.data) {
+		r.err = ErrInvalidSnapshot
+		return nil
+	}
+	v := r.data[:n]
+	r.data = r.data[n:]
+	return v
+}
@@ -0,0 +1,316 @@
+package algorithm
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+)
+
+/*
+ * failure domain aware replica placement for Consistent
+ * - member carry zone, rack and host labels by TopologyMember
+ * - member without labels is a failure domain by itself
+ * - replicas spread across zones first, then racks, then hosts
+ * - replica count of member is bounded by Load, like partition owners
+ * - rebalance plan lists partition moves before member joins or leaves
+ */
+
+//inter macro define
+const (
+	topologyLevelZone = iota
+	topologyLevelRack
+	topologyLevelHost
+	topologyLevelAny
+)
+
+//topology labels
+type Topology struct {
+	Zone string `json:"zone"`
+	Rack string `json:"rack"`
+	Host string `json:"host"`
+}
+
+//member with topology labels
+type TopologyMember interface {
+	Member
+	Topology() Topology
+}
+
+//simple topology member
+type TopologyNode struct {
+	Name string
+	Topo Topology
+}
+
+//partition moves of membership change
+type RebalancePlan struct {
+	Moves []PartitionMove    `json:"moves"`
+	Loads map[string]float64 `json:"loads"` //partition count of members after change
+}
+
+//construct
+func NewTopologyNode(name, zone, rack, host string) TopologyNode {
+	return TopologyNode{
+		Name: name,
+		Topo: Topology{Zone: zone, Rack: rack, Host: host},
+	}
+}
+
+//get name of node
+func (n TopologyNode) String() string {
+	return n.Name
+}
+
+//get topology of node
+func (n TopologyNode) Topology() Topology {
+	return n.Topo
+}
+
+//get replica members for key, the first one is partition owner
+func (c *Consistent) GetReplicas(key []byte, count int) ([]Member, error) {
+	return c.GetReplicasForPartition(c.FindPartitionID(key), count)
+}
+
+//get replica members for partition, the first one is partition owner
+//replicas spread across failure domains, replica count of member bounded by Load
+func (c *Consistent) GetReplicasForPartition(partID, count int) ([]Member, error) {
+	table, err := c.ReplicaTable(count)
+	if err != nil {
+		return nil, err
+	}
+	members, ok := table[partID]
+	if !ok {
+		return nil, errors.New("invalid partition id")
+	}
+	result := make([]Member, len(members))
+	copy(result, members)
+	return result, nil
+}
+
+//get replica table of all partitions, partition id -> replica members
+//table is cached until membership changed, don't modify it
+func (c *Consistent) ReplicaTable(count int) (map[int][]Member, error) {
+	//check
+	if count <= 0 {
+		return nil, errors.New("invalid parameter")
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.members) <= 0 {
+		return nil, ErrNoMembers
+	}
+	if count > len(c.members) {
+		return nil, ErrInsufficientMemberCount
+	}
+
+	//get cached or build
+	c.replicaMu.Lock()
+	defer c.replicaMu.Unlock()
+	if table, ok := c.replicaTables[count]; ok {
+		return table, nil
+	}
+	table := c.buildReplicaTable(count)
+	if c.replicaTables == nil {
+		c.replicaTables = map[int]map[int][]Member{}
+	}
+	c.replicaTables[count] = table
+	return table, nil
+}
+
+//plan partition moves if member joins
+func (c *Consistent) PlanAdd(member Member, weights ...int) (*RebalancePlan, error) {
+	//check
+	if member == nil || member.String() == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	weight, err := pickWeight(weights)
+	if err != nil {
+		return nil, err
+	}
+
+	//simulate on cloned ring
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if _, ok := c.members[member.String()]; ok {
+		return nil, ErrMemberExists
+	}
+	tmp := c.clone()
+	tmp.addWithWeight(member, weight)
+	tmp.distributePartitions()
+	return c.planTo(tmp), nil
+}
+
+//plan partition moves if member leaves
+func (c *Consistent) PlanRemove(name string) (*RebalancePlan, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if _, ok := c.members[name]; !ok {
+		return nil, ErrMemberNotFound
+	}
+	tmp := c.clone()
+	tmp.remove(name)
+	if len(tmp.members) > 0 {
+		tmp.distributePartitions()
+	}
+	return c.planTo(tmp), nil
+}
+
+///////////////
+//private func
+///////////////
+
+//reset cached replica tables, should be called with locker
+func (c *Consistent) resetReplicaTables() {
+	c.replicaMu.Lock()
+	c.replicaTables = nil
+	c.replicaMu.Unlock()
+}
+
+//clone members and ring without partitions, should be called with locker
+func (c *Consistent) clone() *Consistent {
+	tmp := &Consistent{
+		config:         c.config,
+		hasher:         c.hasher,
+		partitionCount: c.partitionCount,
+		members:        make(map[string]*Member),
+		weights:        make(map[string]int),
+		partitions:     make(map[int]*Member),
+		loads:          make(map[string]float64),
+		ring:           make(map[uint64]*Member),
+	}
+	for _, name := range sortedMembers(c.members) {
+		tmp.addWithWeight(*c.members[name], c.weights[name])
+	}
+	return tmp
+}
+
+//get plan from current partitions to target, should be called with locker
+func (c *Consistent) planTo(target *Consistent) *RebalancePlan {
+	plan := &RebalancePlan{
+		Moves: make([]PartitionMove, 0),
+		Loads: make(map[string]float64, len(target.loads)),
+	}
+	for partID := 0; partID < int(c.partitionCount); partID++ {
+		from, to := memberName(c.partitions[partID]), memberName(target.partitions[partID])
+		if from != to {
+			plan.Moves = append(plan.Moves, PartitionMove{PartitionID: partID, From: from, To: to})
+		}
+	}
+	for name, load := range target.loads {
+		plan.Loads[name] = load
+	}
+	return plan
+}
+
+//build replica table, should be called with locker
+func (c *Consistent) buildReplicaTable(count int) map[int][]Member {
+	//replica count bound of member
+	avgLoad := math.Ceil(float64(c.partitionCount) * float64(count) /
+		float64(len(c.members)) * c.config.Load)
+	loads := make(map[string]float64, len(c.members))
+	table := make(map[int][]Member, c.partitionCount)
+	bs := make([]byte, 8)
+	for partID := 0; partID < int(c.partitionCount); partID++ {
+		owner, ok := c.partitions[partID]
+		if !ok {
+			continue
+		}
+		replicas := []Member{*owner}
+		loads[(*owner).String()]++
+
+		//walk ring from partition position, same as owner distribution
+		binary.LittleEndian.PutUint64(bs, uint64(partID))
+		key := c.hasher.Sum64(bs)
+		idx := sort.Search(len(c.sortedSet), func(i int) bool {
+			return c.sortedSet[i] >= key
+		})
+		candidates := c.ringMembersFrom(idx)
+
+		//pick by failure domain level, ignore load bound at last
+		picked := map[string]bool{(*owner).String(): true}
+		for _, bounded := range []bool{true, false} {
+			for level := topologyLevelZone; level <= topologyLevelAny && len(replicas) < count; level++ {
+				for _, candidate := range candidates {
+					if len(replicas) >= count {
+						break
+					}
+					name := candidate.String()
+					if picked[name] || (bounded && loads[name]+1 > avgLoad) ||
+						!domainSpread(level, candidate, replicas) {
+						continue
+					}
+					picked[name] = true
+					loads[name]++
+					replicas = append(replicas, candidate)
+				}
+			}
+		}
+		table[partID] = replicas
+	}
+	return table
+}
+
+//get distinct members walking ring from index, should be called with locker
+func (c *Consistent) ringMembersFrom(idx int) []Member {
+	result := make([]Member, 0, len(c.members))
+	seen := make(map[string]bool, len(c.members))
+	for i := 0; i < len(c.sortedSet) && len(result) < len(c.members); i++ {
+		member := *c.ring[c.sortedSet[(idx+i)%len(c.sortedSet)]]
+		if seen[member.String()] {
+			continue
+		}
+		seen[member.String()] = true
+		result = append(result, member)
+	}
+	return result
+}
+
+//check candidate in new failure domain of level or not
+func domainSpread(level int, candidate Member, picked []Member) bool {
+	if level >= topologyLevelAny {
+		return true
+	}
+	zone, rack, host := domainKeys(candidate)
+	for _, member := range picked {
+		pickedZone, pickedRack, pickedHost := domainKeys(member)
+		if pickedHost == host || (level <= topologyLevelRack && pickedRack == rack) ||
+			(level <= topologyLevelZone && pickedZone == zone) {
+			return false
+		}
+	}
+	return true
+}
+
+//get zone, rack and host domain key of member
+//empty label means member itself is the domain
+func domainKeys(member Member) (string, string, string) {
+	var (
+		topo Topology
+	)
+	if m, ok := member.(TopologyMember); ok {
+		topo = m.Topology()
+	}
+	name := member.String()
+	zone := topo.Zone
+	if zone == "" {
+		zone = "\x00" + name
+	}
+	rack := zone + "/" + topo.Rack
+	if topo.Rack == "" {
+		rack = "\x00" + name
+	}
+	host := rack + "/" + topo.Host
+	if topo.Host == "" {
+		host = "\x00" + name
+	}
+	return zone, rack, host
+}
+
+//get name of member, empty if nil
+func memberName(member *Member) string {
+	if member == nil {
+		return ""
+	}
+	return (*member).String()
+}
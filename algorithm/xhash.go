@@ -18,6 +18,7 @@ type XConsistent struct {
 	hashSortedNodes  []uint32
 	circle           map[uint32]string
 	nodes            map[string]bool
+	nodeCounts       map[string]int //node -> virtual node count
 	orgNodes         []string
 	virtualNodeCount int
 	sync.RWMutex
@@ -29,6 +30,7 @@ func NewXConsistent() *XConsistent {
 		hashSortedNodes: []uint32{},
 		circle: map[uint32]string{},
 		nodes: map[string]bool{},
+		nodeCounts: map[string]int{},
 		orgNodes:[]string{},
 	}
 	return this
@@ -58,28 +60,105 @@ func (c *XConsistent) Add(node string, virtualNodeCounts ...int) error {
 	if c.nodes == nil {
 		c.nodes = map[string]bool{}
 	}
+	if c.nodeCounts == nil {
+		c.nodeCounts = map[string]int{}
+	}
 
 	if _, ok := c.nodes[node]; ok {
 		return errors.New("node already existed")
 	}
-	c.nodes[node] = true
-	c.orgNodes = append(c.orgNodes, node)
+	c.addNode(node, virtualNodeCount)
+	return nil
+}
 
-	//增加虚拟结点
-	for i := 0; i < virtualNodeCount; i++ {
-		virtualKey := c.hashKey(node + strconv.Itoa(i))
-		c.circle[virtualKey] = node
-		c.hashSortedNodes = append(c.hashSortedNodes, virtualKey)
+//remove node
+func (c *XConsistent) Remove(node string) error {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.nodes[node]; !ok {
+		return ErrMemberNotFound
 	}
+	c.removeNode(node)
+	return nil
+}
 
-	//虚拟结点排序
-	sort.Slice(c.hashSortedNodes, func(i, j int) bool {
-		return c.hashSortedNodes[i] < c.hashSortedNodes[j]
-	})
+//add member with weight, balancer api
+//virtual node count is DefaultVirtualNodeCount * weight
+func (c *XConsistent) AddMember(member string, weights ...int) error {
+	//check
+	if member == "" {
+		return errors.New("invalid parameter")
+	}
+	weight, err := pickWeight(weights)
+	if err != nil {
+		return err
+	}
+
+	//add with locker
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.nodes[member]; ok {
+		return ErrMemberExists
+	}
+	c.addNode(member, DefaultVirtualNodeCount * weight)
+	return nil
+}
+
+//remove member, balancer api
+func (c *XConsistent) RemoveMember(member string) error {
+	return c.Remove(member)
+}
+
+//update weight of member, balancer api
+func (c *XConsistent) SetWeight(member string, weight int) error {
+	//check
+	if weight <= 0 {
+		return ErrInvalidWeight
+	}
 
+	//reset virtual nodes with locker
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.nodes[member]; !ok {
+		return ErrMemberNotFound
+	}
+	c.removeNode(member)
+	c.addNode(member, DefaultVirtualNodeCount * weight)
 	return nil
 }
 
+//locate member for key, balancer api
+func (c *XConsistent) Locate(key string) (string, error) {
+	c.RLock()
+	defer c.RUnlock()
+	if len(c.hashSortedNodes) <= 0 {
+		return "", ErrNoMembers
+	}
+	i := c.getPosition(c.hashKey(key))
+	return c.circle[c.hashSortedNodes[i]], nil
+}
+
+//locate n distinct members for key, balancer api
+func (c *XConsistent) LocateN(key string, n int) ([]string, error) {
+	c.RLock()
+	defer c.RUnlock()
+	if err := checkLocateN(n, len(c.nodes)); err != nil {
+		return nil, err
+	}
+	start := c.getPosition(c.hashKey(key))
+	result := walkRing(start, len(c.hashSortedNodes), n, func(pos int) []string {
+		return []string{c.circle[c.hashSortedNodes[pos]]}
+	})
+	return result, nil
+}
+
+//get all members, balancer api
+func (c *XConsistent) Members() []string {
+	c.RLock()
+	defer c.RUnlock()
+	return sortedMembers(c.nodes)
+}
+
 //get key node
 func (c *XConsistent) GetNode(key string) string {
 	c.RLock()
@@ -93,13 +172,66 @@ func (c *XConsistent) GetNode(key string) string {
 
 //get orgin nodes
 func (c *XConsistent) GetOrgNodes() []string {
-	return c.orgNodes
+	c.RLock()
+	defer c.RUnlock()
+	result := make([]string, len(c.orgNodes))
+	copy(result, c.orgNodes)
+	return result
 }
 
 //////////////
 //private func
 //////////////
 
+//add node and virtual nodes, should be called with locker
+//collided virtual node kept by its first owner
+func (c *XConsistent) addNode(node string, virtualNodeCount int) {
+	c.nodes[node] = true
+	c.nodeCounts[node] = virtualNodeCount
+	c.orgNodes = append(c.orgNodes, node)
+
+	//增加虚拟结点
+	for i := 0; i < virtualNodeCount; i++ {
+		virtualKey := c.hashKey(node + strconv.Itoa(i))
+		if _, ok := c.circle[virtualKey]; ok {
+			continue
+		}
+		c.circle[virtualKey] = node
+		c.hashSortedNodes = append(c.hashSortedNodes, virtualKey)
+	}
+
+	//虚拟结点排序
+	sort.Slice(c.hashSortedNodes, func(i, j int) bool {
+		return c.hashSortedNodes[i] < c.hashSortedNodes[j]
+	})
+}
+
+//remove node and virtual nodes, should be called with locker
+//virtual node owned by other node after collision is kept
+func (c *XConsistent) removeNode(node string) {
+	for i := 0; i < c.nodeCounts[node]; i++ {
+		virtualKey := c.hashKey(node + strconv.Itoa(i))
+		if c.circle[virtualKey] != node {
+			continue
+		}
+		idx := sort.Search(len(c.hashSortedNodes), func(j int) bool {
+			return c.hashSortedNodes[j] >= virtualKey
+		})
+		if idx < len(c.hashSortedNodes) && c.hashSortedNodes[idx] == virtualKey {
+			c.hashSortedNodes = append(c.hashSortedNodes[:idx], c.hashSortedNodes[idx+1:]...)
+		}
+		delete(c.circle, virtualKey)
+	}
+	for i, v := range c.orgNodes {
+		if v == node {
+			c.orgNodes = append(c.orgNodes[:i], c.orgNodes[i+1:]...)
+			break
+		}
+	}
+	delete(c.nodes, node)
+	delete(c.nodeCounts, node)
+}
+
 func (c *XConsistent) hashKey(key string) uint32 {
 	return crc32.ChecksumIEEE([]byte(key))
 }
@@ -116,4 +248,59 @@ func (c *XConsistent) getPosition(hash uint32) int {
 	} else {
 		return len(c.hashSortedNodes) - 1
 	}
-}
\ No newline at end of file
+}
+
+//export snapshot of members, virtual node counts and sorted virtual nodes
+func (c *XConsistent) Snapshot() *RingSnapshot {
+	c.RLock()
+	defer c.RUnlock()
+	snap := &RingSnapshot{
+		Kind: SnapshotKindXConsistent,
+		Members: make([]SnapshotMember, 0, len(c.orgNodes)),
+		Points: make([]SnapshotPoint, 0, len(c.hashSortedNodes)),
+	}
+	for _, node := range c.orgNodes {
+		snap.Members = append(snap.Members, SnapshotMember{Name: node, Weight: c.nodeCounts[node]})
+	}
+	for _, hash := range c.hashSortedNodes {
+		snap.Points = append(snap.Points, SnapshotPoint{Hash: uint64(hash), Member: c.circle[hash]})
+	}
+	return snap
+}
+
+//import snapshot, replace all nodes
+//member weight of snapshot is virtual node count
+func (c *XConsistent) Restore(snap *RingSnapshot) error {
+	//check
+	if snap == nil || snap.Kind != SnapshotKindXConsistent {
+		return ErrInvalidSnapshot
+	}
+	if err := snap.validate(); err != nil {
+		return err
+	}
+
+	//rebuild and compare
+	tmp := NewXConsistent()
+	for _, member := range snap.Members {
+		tmp.addNode(member.Name, member.Weight)
+	}
+	if len(tmp.hashSortedNodes) != len(snap.Points) {
+		return ErrSnapshotMismatch
+	}
+	for i, point := range snap.Points {
+		hash := tmp.hashSortedNodes[i]
+		if uint64(hash) != point.Hash || tmp.circle[hash] != point.Member {
+			return ErrSnapshotMismatch
+		}
+	}
+
+	//swap with locker
+	c.Lock()
+	defer c.Unlock()
+	c.hashSortedNodes = tmp.hashSortedNodes
+	c.circle = tmp.circle
+	c.nodes = tmp.nodes
+	c.nodeCounts = tmp.nodeCounts
+	c.orgNodes = tmp.orgNodes
+	return nil
+}
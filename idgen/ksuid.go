@@ -0,0 +1,134 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"time"
+)
+
+/*
+ * ksuid, k-sortable unique identifier
+ * - base on `https://github.com/segmentio/ksuid`
+ * - 32 bits seconds since ksuid epoch and 128 bits random, 27 chars base62
+ */
+
+//inter macro define
+const (
+	KSUIDLen   = 27
+	KSUIDEpoch = int64(1400000000)
+	base62ABC  = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+//inter error define
+var (
+	ErrInvalidKSUID = errors.New("invalid ksuid")
+)
+
+//max ksuid value, 2^160 - 1
+var maxKSUIDValue = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 160), big.NewInt(1))
+
+//ksuid value
+type KSUID [20]byte
+
+//generate ksuid of now
+func NewKSUID() KSUID {
+	id, _ := NewKSUIDAt(time.Now())
+	return id
+}
+
+//generate ksuid of assigned time
+func NewKSUIDAt(t time.Time) (KSUID, error) {
+	var (
+		id KSUID
+	)
+	ts := t.Unix() - KSUIDEpoch
+	if ts < 0 || ts > int64(^uint32(0)) {
+		return id, ErrTimeOverflow
+	}
+	binary.BigEndian.PutUint32(id[:4], uint32(ts))
+	if _, err := rand.Read(id[4:]); err != nil {
+		return KSUID{}, err
+	}
+	return id, nil
+}
+
+//parse ksuid string
+func ParseKSUID(s string) (KSUID, error) {
+	var (
+		id KSUID
+	)
+	//check
+	if len(s) != KSUIDLen {
+		return id, ErrInvalidKSUID
+	}
+
+	//decode base62
+	value := new(big.Int)
+	base := big.NewInt(62)
+	for i := 0; i < len(s); i++ {
+		digit := indexBase62(s[i])
+		if digit < 0 {
+			return id, ErrInvalidKSUID
+		}
+		value.Mul(value, base)
+		value.Add(value, big.NewInt(int64(digit)))
+	}
+	if value.Cmp(maxKSUIDValue) > 0 {
+		return id, ErrInvalidKSUID
+	}
+	value.FillBytes(id[:])
+	return id, nil
+}
+
+//get time of ksuid
+func (k KSUID) Time() time.Time {
+	return time.Unix(int64(binary.BigEndian.Uint32(k[:4])) + KSUIDEpoch, 0)
+}
+
+//get random payload
+func (k KSUID) Payload() []byte {
+	return append([]byte{}, k[4:]...)
+}
+
+//encode into 27 chars string
+func (k KSUID) String() string {
+	buf := make([]byte, KSUIDLen)
+	value := new(big.Int).SetBytes(k[:])
+	base := big.NewInt(62)
+	mod := new(big.Int)
+	for i := KSUIDLen - 1; i >= 0; i-- {
+		value.DivMod(value, base, mod)
+		buf[i] = base62ABC[mod.Int64()]
+	}
+	return string(buf)
+}
+
+//encode into text, used by json
+func (k KSUID) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+//decode from text, used by json
+func (k *KSUID) UnmarshalText(data []byte) error {
+	id, err := ParseKSUID(string(data))
+	if err != nil {
+		return err
+	}
+	*k = id
+	return nil
+}
+
+//get index of base62 char, -1 if invalid
+func indexBase62(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'A' && c <= 'Z':
+		return int(c - 'A') + 10
+	case c >= 'a' && c <= 'z':
+		return int(c - 'a') + 36
+	}
+	return -1
+}
@@ -0,0 +1,206 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/andyzhou/tinylib/redis"
+	"log"
+	mrand "math/rand"
+	"sync"
+	"time"
+)
+
+/*
+ * node id lease base on redis
+ * - node id key set with random token by `SET NX PX`, never shared by two nodes
+ * - lease renewed in background every ttl/3, lost if renew failed until ttl
+ * - renew and release only when token matched, run by lua script
+ */
+
+//inter macro define
+const (
+	DefaultLeaseTTL       = 30 * time.Second
+	DefaultLeaseKeyPrefix = "idgen:node:"
+	leaseRenewScript      = "tinylib_idgen_lease_renew"
+	leaseReleaseScript    = "tinylib_idgen_lease_release"
+)
+
+//lua scripts
+//KEYS[1] -> node key, ARGV[1] -> token, ARGV[2] -> ttl in milliseconds
+const (
+	leaseRenewLua = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`
+	leaseReleaseLua = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`
+)
+
+//face info
+type NodeLease struct {
+	conn      *redis.Connection
+	key       string
+	token     string
+	nodeId    int64
+	ttl       time.Duration
+	expireAt  time.Time //lease valid before it
+	closeChan chan struct{}
+	closeOnce sync.Once
+	sync.RWMutex
+}
+
+//lease one free node id in [0, maxNodeId]
+//ttls -> lease ttl, at least one second, default DefaultLeaseTTL
+func LeaseNodeId(
+	conn *redis.Connection,
+	maxNodeId int64,
+	ttls ...time.Duration) (*NodeLease, error) {
+	return LeaseNodeIdWithPrefix(conn, maxNodeId, DefaultLeaseKeyPrefix, ttls...)
+}
+
+//lease one free node id with key prefix
+func LeaseNodeIdWithPrefix(
+	conn *redis.Connection,
+	maxNodeId int64,
+	prefix string,
+	ttls ...time.Duration) (*NodeLease, error) {
+	var (
+		ttl = DefaultLeaseTTL
+	)
+	//check
+	if conn == nil || conn.GetClient() == nil || maxNodeId < 0 || prefix == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	if ttls != nil && len(ttls) > 0 && ttls[0] >= time.Second {
+		ttl = ttls[0]
+	}
+	for name, script := range map[string]string{
+		leaseRenewScript: leaseRenewLua,
+		leaseReleaseScript: leaseReleaseLua,
+	} {
+		if conn.HasScript(name) {
+			continue
+		}
+		if err := conn.AddScript(name, script); err != nil {
+			return nil, err
+		}
+	}
+	token, err := genLeaseToken()
+	if err != nil {
+		return nil, err
+	}
+
+	//try node ids from random start
+	total := maxNodeId + 1
+	start := mrand.Int63n(total)
+	for i := int64(0); i < total; i++ {
+		nodeId := (start + i) % total
+		key := fmt.Sprintf("%s%d", prefix, nodeId)
+		ctx, cancel := conn.CreateContext()
+		begin := time.Now()
+		ok, subErr := conn.GetClient().SetNX(ctx, key, token, ttl).Result()
+		cancel()
+		if subErr != nil {
+			return nil, subErr
+		}
+		if !ok {
+			continue
+		}
+		this := &NodeLease{
+			conn: conn,
+			key: key,
+			token: token,
+			nodeId: nodeId,
+			ttl: ttl,
+			expireAt: begin.Add(ttl),
+			closeChan: make(chan struct{}),
+		}
+		go this.runRenewProcess()
+		return this, nil
+	}
+	return nil, errors.New("no free node id")
+}
+
+//get node id
+func (f *NodeLease) NodeId() int64 {
+	return f.nodeId
+}
+
+//check lease still valid or not
+func (f *NodeLease) Valid() bool {
+	f.RLock()
+	defer f.RUnlock()
+	return time.Now().Before(f.expireAt)
+}
+
+//release lease, node id could be leased by others
+func (f *NodeLease) Release() error {
+	f.closeOnce.Do(func() {
+		close(f.closeChan)
+	})
+	f.Lock()
+	f.expireAt = time.Time{}
+	f.Unlock()
+	_, err := f.conn.RunScript(leaseReleaseScript, []string{f.key}, f.token)
+	return err
+}
+
+//////////////
+//private func
+//////////////
+
+//renew lease in background
+func (f *NodeLease) runRenewProcess() {
+	ticker := time.NewTicker(f.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <- f.closeChan:
+			return
+		case <- ticker.C:
+			if err := f.renew(); err != nil {
+				log.Printf("renew node id %v lease failed, err:%v\n", f.nodeId, err)
+			}
+		}
+	}
+}
+
+//renew lease once
+func (f *NodeLease) renew() error {
+	begin := time.Now()
+	reply, err := f.conn.RunScript(leaseRenewScript, []string{f.key}, f.token, f.ttl.Milliseconds())
+	if err != nil {
+		return err
+	}
+	if v, ok := reply.(int64); !ok || v != 1 {
+		//taken by others or expired, never valid again
+		f.Lock()
+		f.expireAt = time.Time{}
+		f.Unlock()
+		f.closeOnce.Do(func() {
+			close(f.closeChan)
+		})
+		return ErrLeaseLost
+	}
+	f.Lock()
+	f.expireAt = begin.Add(f.ttl)
+	f.Unlock()
+	return nil
+}
+
+//generate random lease token
+func genLeaseToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
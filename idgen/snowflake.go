@@ -0,0 +1,178 @@
+package idgen
+
+import (
+	"errors"
+	"fmt"
+	"github.com/andyzhou/tinylib/util"
+	"sync"
+	"time"
+)
+
+/*
+ * snowflake id generator
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - id layout: sign bit, milliseconds since epoch, node id, sequence
+ * - node id assigned or leased from redis, see NodeLease
+ * - current time from util.Time if assigned, honour the control duration
+ * - small clock rollback keeps last timestamp, large rollback returns error
+ */
+
+//inter macro define
+const (
+	DefaultNodeBits     = 10
+	DefaultSequenceBits = 12
+	DefaultMaxRollback  = 5 * time.Second
+	minTimestampBits    = 31
+)
+
+//default epoch, 2020-01-01 UTC
+var DefaultEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+//inter error define
+var (
+	ErrClockRollback = errors.New("clock moved backwards")
+	ErrLeaseLost     = errors.New("node id lease lost")
+	ErrTimeOverflow  = errors.New("timestamp overflow")
+)
+
+//snowflake config
+type SnowflakeConf struct {
+	Epoch        time.Time     //default DefaultEpoch
+	NodeBits     uint8         //default DefaultNodeBits
+	SequenceBits uint8         //default DefaultSequenceBits
+	NodeId       int64         //ignored if Lease assigned
+	Lease        *NodeLease    //node id lease, optional
+	MaxRollback  time.Duration //max clock rollback tolerated, default DefaultMaxRollback
+	Timer        *util.Time    //time source, optional
+}
+
+//face info
+type Snowflake struct {
+	conf      SnowflakeConf
+	nodeId    int64
+	maxNode   int64
+	maxSeq    int64
+	maxTime   int64
+	timeShift uint8
+	lastTime  int64 //milliseconds since epoch
+	sequence  int64
+	sync.Mutex
+}
+
+//construct
+func NewSnowflake(conf *SnowflakeConf) (*Snowflake, error) {
+	//check and fill default
+	if conf == nil {
+		return nil, errors.New("invalid parameter")
+	}
+	c := *conf
+	if c.Epoch.IsZero() {
+		c.Epoch = DefaultEpoch
+	}
+	if c.NodeBits == 0 {
+		c.NodeBits = DefaultNodeBits
+	}
+	if c.SequenceBits == 0 {
+		c.SequenceBits = DefaultSequenceBits
+	}
+	if c.MaxRollback <= 0 {
+		c.MaxRollback = DefaultMaxRollback
+	}
+	if 63 - int(c.NodeBits) - int(c.SequenceBits) < minTimestampBits {
+		return nil, fmt.Errorf("node and sequence bits too large, timestamp needs at least %v bits",
+			minTimestampBits)
+	}
+	nodeId := c.NodeId
+	if c.Lease != nil {
+		nodeId = c.Lease.NodeId()
+	}
+	maxNode := int64(1) << c.NodeBits - 1
+	if nodeId < 0 || nodeId > maxNode {
+		return nil, fmt.Errorf("node id should be in [0, %v]", maxNode)
+	}
+
+	//self init
+	this := &Snowflake{
+		conf: c,
+		nodeId: nodeId,
+		maxNode: maxNode,
+		maxSeq: int64(1) << c.SequenceBits - 1,
+		maxTime: int64(1) << (63 - c.NodeBits - c.SequenceBits) - 1,
+		timeShift: c.NodeBits + c.SequenceBits,
+	}
+	return this, nil
+}
+
+//get node id
+func (f *Snowflake) NodeId() int64 {
+	return f.nodeId
+}
+
+//generate next id
+func (f *Snowflake) NextId() (int64, error) {
+	//check lease
+	if f.conf.Lease != nil && !f.conf.Lease.Valid() {
+		return 0, ErrLeaseLost
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	now := f.now()
+	if now < f.lastTime {
+		//clock rollback
+		if time.Duration(f.lastTime - now) * time.Millisecond > f.conf.MaxRollback {
+			return 0, ErrClockRollback
+		}
+		now = f.lastTime
+	}
+	if now == f.lastTime {
+		f.sequence = (f.sequence + 1) & f.maxSeq
+		if f.sequence == 0 {
+			//sequence exhausted, wait for next millisecond
+			now = f.waitAfter(f.lastTime)
+		}
+	}else{
+		f.sequence = 0
+	}
+	if now > f.maxTime {
+		return 0, ErrTimeOverflow
+	}
+	f.lastTime = now
+	return now << f.timeShift | f.nodeId << f.conf.SequenceBits | f.sequence, nil
+}
+
+//parse id into time, node id and sequence
+func (f *Snowflake) Parse(id int64) (time.Time, int64, int64) {
+	ms := id >> f.timeShift
+	nodeId := id >> f.conf.SequenceBits & f.maxNode
+	sequence := id & f.maxSeq
+	return f.conf.Epoch.Add(time.Duration(ms) * time.Millisecond), nodeId, sequence
+}
+
+//////////////
+//private func
+//////////////
+
+//get milliseconds since epoch
+func (f *Snowflake) now() int64 {
+	var (
+		now time.Time
+	)
+	if f.conf.Timer != nil {
+		now = f.conf.Timer.Now()
+	}else{
+		now = time.Now()
+	}
+	return now.Sub(f.conf.Epoch).Milliseconds()
+}
+
+//wait until time after assigned milliseconds
+func (f *Snowflake) waitAfter(last int64) int64 {
+	now := f.now()
+	for now <= last {
+		time.Sleep(time.Duration(last - now + 1) * time.Millisecond)
+		now = f.now()
+	}
+	return now
+}
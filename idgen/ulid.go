@@ -0,0 +1,195 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"errors"
+	"github.com/andyzhou/tinylib/util"
+	"sync"
+	"time"
+)
+
+/*
+ * ulid, universally unique lexicographically sortable identifier
+ * - base on `https://github.com/ulid/spec`
+ * - 48 bits milliseconds timestamp and 80 bits random, 26 chars crockford base32
+ * - generator is monotonic, random part increased within the same millisecond
+ */
+
+//inter macro define
+const (
+	ULIDLen      = 26
+	crockfordABC = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	maxULIDTime  = int64(1) << 48 - 1
+)
+
+//inter error define
+var (
+	ErrInvalidULID = errors.New("invalid ulid")
+)
+
+//crockford base32 decode table
+var crockfordDec = func() [256]byte {
+	var table [256]byte
+	for i := range table {
+		table[i] = 0xff
+	}
+	for i := 0; i < len(crockfordABC); i++ {
+		c := crockfordABC[i]
+		table[c] = byte(i)
+		if c >= 'A' && c <= 'Z' {
+			table[c+'a'-'A'] = byte(i)
+		}
+	}
+	return table
+}()
+
+//ulid value
+type ULID [16]byte
+
+//ulid generator
+type ULIDGenerator struct {
+	timer *util.Time
+	last  ULID
+	sync.Mutex
+}
+
+//default generator
+var defaultULIDGenerator = NewULIDGenerator()
+
+//construct
+func NewULIDGenerator() *ULIDGenerator {
+	return &ULIDGenerator{}
+}
+
+//generate ulid by default generator
+func NewULID() ULID {
+	id, _ := defaultULIDGenerator.New()
+	return id
+}
+
+//parse ulid string
+func ParseULID(s string) (ULID, error) {
+	var (
+		id ULID
+	)
+	//check
+	if len(s) != ULIDLen || crockfordDec[s[0]] > 7 {
+		return id, ErrInvalidULID
+	}
+	for i := 0; i < ULIDLen; i++ {
+		if crockfordDec[s[i]] == 0xff {
+			return id, ErrInvalidULID
+		}
+	}
+
+	//decode 5 bits per char, from tail
+	bitsBuf, bitsLen, pos := uint(0), uint(0), 15
+	for i := ULIDLen - 1; i >= 0; i-- {
+		bitsBuf |= uint(crockfordDec[s[i]]) << bitsLen
+		bitsLen += 5
+		for bitsLen >= 8 && pos >= 0 {
+			id[pos] = byte(bitsBuf)
+			bitsBuf >>= 8
+			bitsLen -= 8
+			pos--
+		}
+	}
+	return id, nil
+}
+
+//set time, used for shifted clock
+func (f *ULIDGenerator) SetTime(t *util.Time) {
+	f.Lock()
+	defer f.Unlock()
+	f.timer = t
+}
+
+//generate monotonic ulid
+func (f *ULIDGenerator) New() (ULID, error) {
+	var (
+		id ULID
+	)
+	f.Lock()
+	defer f.Unlock()
+	now := time.Now()
+	if f.timer != nil {
+		now = f.timer.Now()
+	}
+	ms := now.UnixMilli()
+	if ms < 0 || ms > maxULIDTime {
+		return id, ErrTimeOverflow
+	}
+
+	//same or older millisecond, increase random part of last one
+	if ms <= f.last.Time().UnixMilli() && f.last != (ULID{}) {
+		id = f.last
+		for i := 15; i >= 6; i-- {
+			id[i]++
+			if id[i] != 0 {
+				f.last = id
+				return id, nil
+			}
+		}
+		//random part overflow, use new random with next millisecond
+		ms = f.last.Time().UnixMilli() + 1
+	}
+	id.setTime(ms)
+	if _, err := rand.Read(id[6:]); err != nil {
+		return ULID{}, err
+	}
+	f.last = id
+	return id, nil
+}
+
+//get time of ulid
+func (u ULID) Time() time.Time {
+	ms := int64(u[0]) << 40 | int64(u[1]) << 32 | int64(u[2]) << 24 |
+		int64(u[3]) << 16 | int64(u[4]) << 8 | int64(u[5])
+	return time.UnixMilli(ms)
+}
+
+//get random part
+func (u ULID) Entropy() []byte {
+	return append([]byte{}, u[6:]...)
+}
+
+//encode into 26 chars string
+func (u ULID) String() string {
+	buf := make([]byte, ULIDLen)
+	bitsBuf, bitsLen, pos := uint(0), uint(0), ULIDLen - 1
+	for i := 15; i >= 0; i-- {
+		bitsBuf |= uint(u[i]) << bitsLen
+		bitsLen += 8
+		for bitsLen >= 5 {
+			buf[pos] = crockfordABC[bitsBuf & 0x1f]
+			bitsBuf >>= 5
+			bitsLen -= 5
+			pos--
+		}
+	}
+	buf[pos] = crockfordABC[bitsBuf & 0x1f]
+	return string(buf)
+}
+
+//encode into text, used by json
+func (u ULID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+//decode from text, used by json
+func (u *ULID) UnmarshalText(data []byte) error {
+	id, err := ParseULID(string(data))
+	if err != nil {
+		return err
+	}
+	*u = id
+	return nil
+}
+
+//set timestamp part
+func (u *ULID) setTime(ms int64) {
+	for i := 5; i >= 0; i-- {
+		u[i] = byte(ms)
+		ms >>= 8
+	}
+}
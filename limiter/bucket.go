@@ -0,0 +1,97 @@
+package limiter
+
+import (
+	"github.com/andyzhou/tinylib/util"
+	"math"
+	"time"
+)
+
+/*
+ * token bucket limiter
+ * - bucket holds at most `Burst` tokens, refill `Rate` tokens per `Period`
+ * - each request takes one token
+ */
+
+//inter type
+type bucketState struct {
+	tokens float64
+	last   time.Time
+}
+
+//face info
+type TokenBucket struct {
+	limit Limit
+	store *store[bucketState]
+}
+
+//construct
+func NewTokenBucket(limit Limit) (*TokenBucket, error) {
+	limit, err := limit.normalize()
+	if err != nil {
+		return nil, err
+	}
+	this := &TokenBucket{
+		limit: limit,
+	}
+	this.store = newStore(this.fullAfter(0), func(s *bucketState, now time.Time) bool {
+		return now.Sub(s.last) >= this.fullAfter(s.tokens)
+	})
+	return this, nil
+}
+
+//set time, used for shifted clock
+func (f *TokenBucket) SetTime(t *util.Time) {
+	f.store.setTime(t)
+}
+
+//get keys count in memory
+func (f *TokenBucket) Keys() int {
+	return f.store.size()
+}
+
+//take one request of key
+func (f *TokenBucket) Allow(key string) bool {
+	result, err := f.AllowN(key, 1)
+	return err == nil && result.Allowed
+}
+
+//take n requests of key
+func (f *TokenBucket) AllowN(key string, n int) (*Result, error) {
+	//check
+	if n <= 0 || n > f.limit.Burst {
+		return nil, ErrExceedsBurst
+	}
+
+	//take tokens
+	result := &Result{Limit: f.limit.Burst}
+	f.store.with(key, func(s *bucketState, now time.Time) {
+		if s.last.IsZero() {
+			s.tokens = float64(f.limit.Burst)
+		}else if now.After(s.last) {
+			refill := float64(now.Sub(s.last)) / float64(f.limit.Period) * float64(f.limit.Rate)
+			s.tokens = math.Min(float64(f.limit.Burst), s.tokens + refill)
+		}
+		if now.After(s.last) {
+			s.last = now
+		}
+		if s.tokens >= float64(n) {
+			s.tokens -= float64(n)
+			result.Allowed = true
+		}else{
+			result.RetryAfter = f.refillTime(float64(n) - s.tokens)
+		}
+		result.Remaining = int(s.tokens)
+		result.ResetAfter = f.fullAfter(s.tokens)
+	})
+	return result, nil
+}
+
+//get refill time of tokens
+func (f *TokenBucket) refillTime(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / float64(f.limit.Rate) * float64(f.limit.Period)))
+}
+
+//get time before bucket is full
+func (f *TokenBucket) fullAfter(tokens float64) time.Duration {
+	return f.refillTime(float64(f.limit.Burst) - tokens)
+}
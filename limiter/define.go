@@ -0,0 +1,107 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+/*
+ * rate limiter define
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - limit is `Rate` requests per `Period`, allow `Burst` requests at once
+ * - all limiters are keyed, like client ip, user id or remote host
+ */
+
+//inter macro define
+const (
+	DefaultPeriod   = time.Second
+	DefaultSweepGap = time.Minute
+)
+
+//inter error define
+var (
+	ErrExceedsBurst = errors.New("cost exceeds burst")
+	ErrInvalidLimit = errors.New("invalid limit")
+)
+
+//limit config
+type Limit struct {
+	Rate   int           //requests count per period
+	Period time.Duration //default DefaultPeriod
+	Burst  int           //max requests at once, default Rate, not used by sliding window
+}
+
+//limit result
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int           //requests could be allowed now
+	RetryAfter time.Duration //wait time before next allowed request, 0 if allowed
+	ResetAfter time.Duration //wait time before limit fully recovered
+}
+
+//limiter interface
+type Limiter interface {
+	//take one request of key
+	Allow(key string) bool
+
+	//take n requests of key
+	AllowN(key string, n int) (*Result, error)
+}
+
+//per second limit
+func PerSecond(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Second}
+}
+
+//per minute limit
+func PerMinute(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Minute}
+}
+
+//per hour limit
+func PerHour(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Hour}
+}
+
+//wait until one request of key allowed or ctx done
+//used for outbound calls, like util.HttpClient
+func Wait(ctx context.Context, l Limiter, key string) error {
+	for {
+		result, err := l.AllowN(key, 1)
+		if err != nil {
+			return err
+		}
+		if result.Allowed {
+			return nil
+		}
+		timer := time.NewTimer(result.RetryAfter)
+		select {
+		case <- ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <- timer.C:
+		}
+	}
+}
+
+//check and fill default value of limit
+func (l Limit) normalize() (Limit, error) {
+	if l.Rate <= 0 || l.Period < 0 || l.Burst < 0 {
+		return l, ErrInvalidLimit
+	}
+	if l.Period == 0 {
+		l.Period = DefaultPeriod
+	}
+	if l.Burst == 0 {
+		l.Burst = l.Rate
+	}
+	return l, nil
+}
+
+//get interval between two requests
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Rate)
+}
@@ -0,0 +1,87 @@
+package limiter
+
+import (
+	"github.com/andyzhou/tinylib/util"
+	"time"
+)
+
+/*
+ * generic cell rate algorithm limiter
+ * - keep theoretical arrival time(tat) only, memory is O(1) per key
+ * - one request per `Period / Rate`, allow `Burst` requests at once
+ * - same algorithm as RedisLimiter
+ */
+
+//inter type
+type gcraState struct {
+	tat time.Time
+}
+
+//face info
+type GCRA struct {
+	limit Limit
+	store *store[gcraState]
+}
+
+//construct
+func NewGCRA(limit Limit) (*GCRA, error) {
+	limit, err := limit.normalize()
+	if err != nil {
+		return nil, err
+	}
+	this := &GCRA{
+		limit: limit,
+	}
+	this.store = newStore(limit.interval() * time.Duration(limit.Burst), func(s *gcraState, now time.Time) bool {
+		return !now.Before(s.tat)
+	})
+	return this, nil
+}
+
+//set time, used for shifted clock
+func (f *GCRA) SetTime(t *util.Time) {
+	f.store.setTime(t)
+}
+
+//get keys count in memory
+func (f *GCRA) Keys() int {
+	return f.store.size()
+}
+
+//take one request of key
+func (f *GCRA) Allow(key string) bool {
+	result, err := f.AllowN(key, 1)
+	return err == nil && result.Allowed
+}
+
+//take n requests of key
+func (f *GCRA) AllowN(key string, n int) (*Result, error) {
+	//check
+	if n <= 0 || n > f.limit.Burst {
+		return nil, ErrExceedsBurst
+	}
+
+	//check arrival time
+	interval := f.limit.interval()
+	burstOffset := interval * time.Duration(f.limit.Burst)
+	result := &Result{Limit: f.limit.Burst}
+	f.store.with(key, func(s *gcraState, now time.Time) {
+		tat := s.tat
+		if tat.Before(now) {
+			tat = now
+		}
+		newTat := tat.Add(interval * time.Duration(n))
+		allowAt := newTat.Add(-burstOffset)
+		if now.Before(allowAt) {
+			result.RetryAfter = allowAt.Sub(now)
+			result.ResetAfter = tat.Sub(now)
+			result.Remaining = int((burstOffset - tat.Sub(now)) / interval)
+			return
+		}
+		s.tat = newTat
+		result.Allowed = true
+		result.ResetAfter = newTat.Sub(now)
+		result.Remaining = int((burstOffset - newTat.Sub(now)) / interval)
+	})
+	return result, nil
+}
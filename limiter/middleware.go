@@ -0,0 +1,75 @@
+package limiter
+
+import (
+	"github.com/andyzhou/tinylib/web"
+	"github.com/gin-gonic/gin"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+/*
+ * gin middleware of limiter
+ * - keyed by client ip from web.Base.GetClientIp by default
+ * - rejected request aborted with 429 and `Retry-After` header
+ * - limiter error will not block request
+ */
+
+//inter macro define
+const (
+	HeaderLimit      = "X-RateLimit-Limit"
+	HeaderRemaining  = "X-RateLimit-Remaining"
+	HeaderRetryAfter = "Retry-After"
+)
+
+//key func of request
+type KeyFunc func(c *gin.Context) string
+
+//get client ip key, port removed
+func KeyByClientIp(c *gin.Context) string {
+	base := web.Base{}
+	ip := base.GetClientIp(c)
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		return host
+	}
+	return ip
+}
+
+//get client ip and route path key
+func KeyByClientIpAndPath(c *gin.Context) string {
+	path := c.FullPath()
+	if path == "" {
+		path = c.Request.URL.Path
+	}
+	return KeyByClientIp(c) + "|" + path
+}
+
+//create gin middleware
+//keyFuncs -> request key func, default KeyByClientIp
+func GinMiddleware(l Limiter, keyFuncs ...KeyFunc) gin.HandlerFunc {
+	var (
+		keyFunc KeyFunc = KeyByClientIp
+	)
+	if keyFuncs != nil && len(keyFuncs) > 0 && keyFuncs[0] != nil {
+		keyFunc = keyFuncs[0]
+	}
+	return func(c *gin.Context) {
+		result, err := l.AllowN(keyFunc(c), 1)
+		if err != nil {
+			log.Printf("limiter check failed, err:%v\n", err)
+			c.Next()
+			return
+		}
+		c.Header(HeaderLimit, strconv.Itoa(result.Limit))
+		c.Header(HeaderRemaining, strconv.Itoa(result.Remaining))
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+			c.Header(HeaderRetryAfter, strconv.Itoa(retryAfter))
+			c.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		}
+		c.Next()
+	}
+}
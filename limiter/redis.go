@@ -0,0 +1,136 @@
+package limiter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/andyzhou/tinylib/redis"
+	"time"
+)
+
+/*
+ * distributed gcra limiter base on redis
+ * - check and update in one lua script, atomic between all nodes
+ * - current time from redis server, no clock skew between nodes
+ * - key expired after limit fully recovered
+ */
+
+//inter macro define
+const (
+	RedisLimiterScript   = "tinylib_gcra_limiter"
+	DefaultRedisKeyPrefix = "limiter:"
+)
+
+//gcra lua script
+//KEYS[1] -> limit key
+//ARGV -> burst, interval in microseconds, cost
+//return -> allowed, remaining, retry after and reset after in microseconds
+const gcraScript = `
+redis.replicate_commands()
+local burst = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local now = redis.call('TIME')
+now = tonumber(now[1]) * 1000000 + tonumber(now[2])
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if not tat or tat < now then
+	tat = now
+end
+local burst_offset = burst * interval
+local new_tat = tat + cost * interval
+local allow_at = new_tat - burst_offset
+if now < allow_at then
+	return {0, math.floor((burst_offset - (tat - now)) / interval), allow_at - now, tat - now}
+end
+redis.call('SET', KEYS[1], new_tat, 'PX', math.ceil((new_tat - now) / 1000) + 1)
+return {1, math.floor((burst_offset - (new_tat - now)) / interval), 0, new_tat - now}
+`
+
+//face info
+type RedisLimiter struct {
+	conn   *redis.Connection
+	limit  Limit
+	prefix string
+}
+
+//construct
+//prefixes -> redis key prefix, default DefaultRedisKeyPrefix
+func NewRedisLimiter(
+	conn *redis.Connection,
+	limit Limit,
+	prefixes ...string) (*RedisLimiter, error) {
+	var (
+		prefix = DefaultRedisKeyPrefix
+	)
+	//check
+	if conn == nil {
+		return nil, errors.New("invalid parameter")
+	}
+	limit, err := limit.normalize()
+	if err != nil {
+		return nil, err
+	}
+	if limit.interval() < time.Microsecond {
+		return nil, ErrInvalidLimit
+	}
+	if prefixes != nil && len(prefixes) > 0 && prefixes[0] != "" {
+		prefix = prefixes[0]
+	}
+
+	//add script once for connection
+	if !conn.HasScript(RedisLimiterScript) {
+		if err = conn.AddScript(RedisLimiterScript, gcraScript); err != nil {
+			return nil, err
+		}
+	}
+	this := &RedisLimiter{
+		conn: conn,
+		limit: limit,
+		prefix: prefix,
+	}
+	return this, nil
+}
+
+//take one request of key
+//return false if redis failed
+func (f *RedisLimiter) Allow(key string) bool {
+	result, err := f.AllowN(key, 1)
+	return err == nil && result.Allowed
+}
+
+//take n requests of key
+func (f *RedisLimiter) AllowN(key string, n int) (*Result, error) {
+	//check
+	if n <= 0 || n > f.limit.Burst {
+		return nil, ErrExceedsBurst
+	}
+
+	//run script
+	reply, err := f.conn.RunScript(
+		RedisLimiterScript,
+		[]string{f.prefix + key},
+		f.limit.Burst,
+		f.limit.interval().Microseconds(),
+		n,
+	)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 4 {
+		return nil, fmt.Errorf("invalid limiter script reply:%v", reply)
+	}
+	ints := make([]int64, len(values))
+	for i, v := range values {
+		if ints[i], ok = v.(int64); !ok {
+			return nil, fmt.Errorf("invalid limiter script reply:%v", reply)
+		}
+	}
+	result := &Result{
+		Allowed: ints[0] == 1,
+		Limit: f.limit.Burst,
+		Remaining: int(ints[1]),
+		RetryAfter: time.Duration(ints[2]) * time.Microsecond,
+		ResetAfter: time.Duration(ints[3]) * time.Microsecond,
+	}
+	return result, nil
+}
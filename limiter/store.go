@@ -0,0 +1,87 @@
+package limiter
+
+import (
+	"github.com/andyzhou/tinylib/util"
+	"sync"
+	"time"
+)
+
+/*
+ * keyed state store of local limiters
+ * - idle state removed by sweep, at most once per sweep gap
+ * - current time from util.Time if assigned, honour the control duration
+ */
+
+//face info
+type store[S any] struct {
+	states    map[string]*S
+	sweepGap  time.Duration
+	idleFunc  func(s *S, now time.Time) bool //state is idle or not
+	lastSweep time.Time
+	timer     *util.Time
+	sync.Mutex
+}
+
+//construct
+//sweepGap -> min gap of sweep, at least DefaultSweepGap
+func newStore[S any](sweepGap time.Duration, idleFunc func(s *S, now time.Time) bool) *store[S] {
+	if sweepGap < DefaultSweepGap {
+		sweepGap = DefaultSweepGap
+	}
+	this := &store[S]{
+		states: map[string]*S{},
+		sweepGap: sweepGap,
+		idleFunc: idleFunc,
+		lastSweep: time.Now(),
+	}
+	return this
+}
+
+//set time, used for shifted clock
+func (f *store[S]) setTime(t *util.Time) {
+	f.Lock()
+	defer f.Unlock()
+	f.timer = t
+}
+
+//get keys count
+func (f *store[S]) size() int {
+	f.Lock()
+	defer f.Unlock()
+	return len(f.states)
+}
+
+//run with state of key, create state if not exists
+func (f *store[S]) with(key string, cb func(s *S, now time.Time)) {
+	f.Lock()
+	defer f.Unlock()
+	now := f.now()
+	f.sweep(now)
+	state, ok := f.states[key]
+	if !ok {
+		state = new(S)
+		f.states[key] = state
+	}
+	cb(state, now)
+}
+
+//get current time, should be called with locker
+func (f *store[S]) now() time.Time {
+	if f.timer != nil {
+		return f.timer.Now()
+	}
+	return time.Now()
+}
+
+//remove idle states, should be called with locker
+func (f *store[S]) sweep(now time.Time) {
+	if now.Sub(f.lastSweep) < f.sweepGap && !now.Before(f.lastSweep) {
+		return
+	}
+	f.lastSweep = now
+	for key, state := range f.states {
+		if f.idleFunc(state, now) {
+			delete(f.states, key)
+		}
+	}
+}
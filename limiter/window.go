@@ -0,0 +1,89 @@
+package limiter
+
+import (
+	"github.com/andyzhou/tinylib/util"
+	"time"
+)
+
+/*
+ * sliding window log limiter
+ * - keep request time of last `Period`, allow at most `Rate` requests in it
+ * - exact but memory is O(Rate) per key
+ */
+
+//inter type
+type windowState struct {
+	logs []time.Time //sorted request time
+}
+
+//face info
+type SlidingWindow struct {
+	limit Limit
+	store *store[windowState]
+}
+
+//construct
+func NewSlidingWindow(limit Limit) (*SlidingWindow, error) {
+	limit, err := limit.normalize()
+	if err != nil {
+		return nil, err
+	}
+	this := &SlidingWindow{
+		limit: limit,
+	}
+	this.store = newStore(limit.Period, func(s *windowState, now time.Time) bool {
+		return len(s.logs) == 0 || now.Sub(s.logs[len(s.logs)-1]) >= limit.Period
+	})
+	return this, nil
+}
+
+//set time, used for shifted clock
+func (f *SlidingWindow) SetTime(t *util.Time) {
+	f.store.setTime(t)
+}
+
+//get keys count in memory
+func (f *SlidingWindow) Keys() int {
+	return f.store.size()
+}
+
+//take one request of key
+func (f *SlidingWindow) Allow(key string) bool {
+	result, err := f.AllowN(key, 1)
+	return err == nil && result.Allowed
+}
+
+//take n requests of key
+func (f *SlidingWindow) AllowN(key string, n int) (*Result, error) {
+	//check
+	if n <= 0 || n > f.limit.Rate {
+		return nil, ErrExceedsBurst
+	}
+
+	//check window logs
+	result := &Result{Limit: f.limit.Rate}
+	f.store.with(key, func(s *windowState, now time.Time) {
+		//remove expired logs
+		expired := 0
+		for expired < len(s.logs) && now.Sub(s.logs[expired]) >= f.limit.Period {
+			expired++
+		}
+		s.logs = s.logs[expired:]
+
+		if len(s.logs) + n <= f.limit.Rate {
+			for i := 0; i < n; i++ {
+				s.logs = append(s.logs, now)
+			}
+			result.Allowed = true
+		}else{
+			//wait for enough logs expired
+			oldest := s.logs[len(s.logs) + n - f.limit.Rate - 1]
+			result.RetryAfter = oldest.Add(f.limit.Period).Sub(now)
+		}
+		result.Remaining = f.limit.Rate - len(s.logs)
+		if len(s.logs) > 0 {
+			result.ResetAfter = s.logs[len(s.logs)-1].Add(f.limit.Period).Sub(now)
+		}
+	})
+	return result, nil
+}
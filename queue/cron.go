@@ -0,0 +1,301 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"github.com/andyzhou/tinylib/util"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+ * cron scheduler worker
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - driven by ticker, check due jobs every tick
+ * - named job with cron expression, see ParseCron
+ * - current time from util.Time if assigned, honour the control duration
+ */
+
+//inter type
+type cronJob struct {
+	name     string
+	schedule CronSchedule
+	cb       func(name string) error
+	overlap  int
+	next     time.Time
+	checkAt  time.Time //last check time, used for clock rollback
+	running  bool
+	pending  int //queued runs
+}
+
+//face info
+type Cron struct {
+	jobs    map[string]*cronJob //name -> job
+	ticker  *Ticker
+	timer   *util.Time
+	closed  bool
+	stat    *statCounter
+	sv      *supervisor
+	running int32
+	sync.RWMutex
+}
+
+//construct
+//tickRates -> check rate seconds value, default DefaultCronTickRate
+func NewCron(tickRates ...float64) *Cron {
+	tickRate := float64(DefaultCronTickRate)
+	if tickRates != nil && len(tickRates) > 0 && tickRates[0] > 0 {
+		tickRate = tickRates[0]
+	}
+	this := &Cron{
+		jobs: map[string]*cronJob{},
+		ticker: NewTicker(tickRate),
+		stat: newStatCounter(),
+		sv: newSupervisor("cron"),
+	}
+	this.ticker.SetCheckerCallback(this.checkJobs)
+	return this
+}
+
+//quit
+func (f *Cron) Quit() {
+	f.Lock()
+	f.closed = true
+	f.Unlock()
+	f.ticker.Quit()
+}
+
+//shutdown gracefully
+//stop scheduling and wait for running jobs done until ctx done
+func (f *Cron) Shutdown(ctx context.Context) *ShutdownReport {
+	before := f.GetStats()
+	f.Lock()
+	f.closed = true
+	f.Unlock()
+	report := f.ticker.Shutdown(ctx)
+
+	//wait for running jobs
+	err := waitUntil(ctx, func() bool {
+		return atomic.LoadInt32(&f.running) <= 0
+	})
+	report.TimedOut = report.TimedOut || err != nil
+	report.Abandoned = int64(atomic.LoadInt32(&f.running)) + f.pendingRuns()
+	after := f.GetStats()
+	report.Processed = after.Processed + after.Failed - before.Processed - before.Failed
+	return report
+}
+
+//set time, used for shifted clock
+func (f *Cron) SetTime(t *util.Time) {
+	f.Lock()
+	defer f.Unlock()
+	f.timer = t
+	f.resetJobs()
+}
+
+//add named job
+//overlaps -> CronOverlapSkip or CronOverlapQueue, default skip
+func (f *Cron) AddJob(
+	name, expr string,
+	cb func(name string) error,
+	overlaps ...int) error {
+	var (
+		overlap = CronOverlapSkip
+	)
+	//check
+	if name == "" || expr == "" || cb == nil {
+		return errors.New("invalid parameter")
+	}
+	if overlaps != nil && len(overlaps) > 0 {
+		overlap = overlaps[0]
+	}
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		return err
+	}
+
+	//add job with locker
+	f.Lock()
+	defer f.Unlock()
+	if f.closed {
+		return errors.New("cron has closed")
+	}
+	if _, ok := f.jobs[name]; ok {
+		return errors.New("job name has exists")
+	}
+	now := f.now()
+	f.jobs[name] = &cronJob{
+		name: name,
+		schedule: schedule,
+		cb: cb,
+		overlap: overlap,
+		next: schedule.Next(now),
+		checkAt: now,
+	}
+	return nil
+}
+
+//remove job by name
+//running job will not be interrupted
+func (f *Cron) RemoveJob(name string) error {
+	f.Lock()
+	defer f.Unlock()
+	job, ok := f.jobs[name]
+	if !ok || job == nil {
+		return errors.New("no such job")
+	}
+	f.stat.addDropped(int64(job.pending))
+	job.pending = 0
+	delete(f.jobs, name)
+	return nil
+}
+
+//get all job names, sorted
+func (f *Cron) GetJobNames() []string {
+	f.RLock()
+	defer f.RUnlock()
+	result := make([]string, 0, len(f.jobs))
+	for name := range f.jobs {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+//get next run times of job
+func (f *Cron) NextRunTimes(name string, num int) ([]time.Time, error) {
+	//check
+	if num <= 0 {
+		return nil, errors.New("invalid parameter")
+	}
+	f.RLock()
+	defer f.RUnlock()
+	job, ok := f.jobs[name]
+	if !ok || job == nil {
+		return nil, errors.New("no such job")
+	}
+
+	//calculate from next run time
+	result := make([]time.Time, 0, num)
+	next := job.next
+	for i := 0; i < num && !next.IsZero(); i++ {
+		result = append(result, next)
+		next = job.schedule.Next(next)
+	}
+	return result, nil
+}
+
+//check ticker process is alive or not
+func (f *Cron) Healthy() bool {
+	return f.ticker.Healthy()
+}
+
+//set supervisor for job panic and process restart
+func (f *Cron) SetSupervisor(conf *SupervisorConf) {
+	f.sv.setConf(conf)
+	f.ticker.SetSupervisor(conf)
+}
+
+//get runtime stats snapshot
+//depth is queued runs count
+func (f *Cron) GetStats() *Stats {
+	return f.stat.snapshot(f.pendingRuns())
+}
+
+///////////////
+//private func
+///////////////
+
+//get current time
+func (f *Cron) now() time.Time {
+	if f.timer != nil {
+		return f.timer.Now()
+	}
+	return time.Now().UTC()
+}
+
+//reset next run time of all jobs, should be called with locker
+func (f *Cron) resetJobs() {
+	now := f.now()
+	for _, job := range f.jobs {
+		job.next = job.schedule.Next(now)
+		job.checkAt = now
+	}
+}
+
+//get queued runs count
+func (f *Cron) pendingRuns() int64 {
+	f.RLock()
+	defer f.RUnlock()
+	total := int64(0)
+	for _, job := range f.jobs {
+		total += int64(job.pending)
+	}
+	return total
+}
+
+//check and fire due jobs, called by ticker
+func (f *Cron) checkJobs(inputs ...interface{}) error {
+	f.Lock()
+	defer f.Unlock()
+	if f.closed {
+		return nil
+	}
+	now := f.now()
+	for _, job := range f.jobs {
+		if now.Before(job.checkAt) {
+			//clock rollback, calculate again
+			job.next = job.schedule.Next(now)
+		}
+		job.checkAt = now
+		if job.next.IsZero() || now.Before(job.next) {
+			continue
+		}
+		job.next = job.schedule.Next(now)
+		f.fireJob(job)
+	}
+	return nil
+}
+
+//fire job, should be called with locker
+func (f *Cron) fireJob(job *cronJob) {
+	if job.running {
+		if job.overlap == CronOverlapQueue {
+			job.pending++
+			f.stat.addEnqueued(int64(job.pending))
+		}else{
+			f.stat.addDropped(1)
+		}
+		return
+	}
+	job.running = true
+	f.stat.addEnqueued(0)
+	atomic.AddInt32(&f.running, 1)
+	go f.runJob(job)
+}
+
+//run job until no queued runs
+func (f *Cron) runJob(job *cronJob) {
+	defer atomic.AddInt32(&f.running, -1)
+	for {
+		begin := time.Now()
+		err := f.sv.safeCall(job.name, func() error {
+			return job.cb(job.name)
+		})
+		f.stat.addResult(err, time.Since(begin))
+
+		//check queued runs
+		f.Lock()
+		if job.pending > 0 && !f.closed {
+			job.pending--
+			f.Unlock()
+			continue
+		}
+		job.running = false
+		f.Unlock()
+		return
+	}
+}
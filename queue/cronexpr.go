@@ -0,0 +1,325 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * cron expression parser
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - support 5 fields: minute hour day-of-month month day-of-week
+ * - support 6 fields: second minute hour day-of-month month day-of-week
+ * - support descriptors: @yearly, @monthly, @weekly, @daily, @hourly, @every <duration>
+ * - time zone assigned by `TZ=` or `CRON_TZ=` prefix, default UTC
+ */
+
+//inter macro define
+const (
+	cronMaxSearchYears = 5
+	cronStarBit        = uint64(1) << 63
+)
+
+//cron schedule
+type CronSchedule interface {
+	//get next activation time after assigned time
+	//return zero time if no activation found
+	Next(t time.Time) time.Time
+}
+
+//inter type
+type (
+	cronBounds struct {
+		min, max uint
+		names    map[string]uint
+	}
+
+	//field bits schedule
+	specSchedule struct {
+		second, minute, hour, dom, month, dow uint64
+		location *time.Location
+	}
+
+	//fixed interval schedule
+	everySchedule struct {
+		delay time.Duration
+	}
+)
+
+//field bounds
+var (
+	cronSeconds = cronBounds{0, 59, nil}
+	cronMinutes = cronBounds{0, 59, nil}
+	cronHours   = cronBounds{0, 23, nil}
+	cronDom     = cronBounds{1, 31, nil}
+	cronMonths  = cronBounds{1, 12, map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronDow = cronBounds{0, 7, map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 0 1 1 *",
+		"@annually": "0 0 0 1 1 *",
+		"@monthly":  "0 0 0 1 * *",
+		"@weekly":   "0 0 0 * * 0",
+		"@daily":    "0 0 0 * * *",
+		"@midnight": "0 0 0 * * *",
+		"@hourly":   "0 0 * * * *",
+	}
+)
+
+//parse cron expression
+func ParseCron(expr string) (CronSchedule, error) {
+	var (
+		err error
+	)
+	//check
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, errors.New("empty cron expression")
+	}
+
+	//pick time zone
+	location := time.UTC
+	if strings.HasPrefix(expr, "TZ=") || strings.HasPrefix(expr, "CRON_TZ=") {
+		idx := strings.Index(expr, " ")
+		if idx < 0 {
+			return nil, fmt.Errorf("invalid cron expression %q", expr)
+		}
+		zone := expr[strings.Index(expr, "=")+1 : idx]
+		location, err = time.LoadLocation(zone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone %q, err:%v", zone, err)
+		}
+		expr = strings.TrimSpace(expr[idx:])
+	}
+
+	//check descriptor
+	if strings.HasPrefix(expr, "@") {
+		if strings.HasPrefix(expr, "@every ") {
+			delay, subErr := time.ParseDuration(strings.TrimSpace(expr[len("@every "):]))
+			if subErr != nil || delay < time.Second {
+				return nil, fmt.Errorf("invalid cron interval %q", expr)
+			}
+			return &everySchedule{delay: delay.Truncate(time.Second)}, nil
+		}
+		spec, ok := cronDescriptors[strings.ToLower(expr)]
+		if !ok {
+			return nil, fmt.Errorf("unknown cron descriptor %q", expr)
+		}
+		expr = spec
+	}
+
+	//split fields, 5 fields without second
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron expression %q should has 5 or 6 fields", expr)
+	}
+
+	//parse fields
+	schedule := &specSchedule{location: location}
+	targets := []*uint64{
+		&schedule.second, &schedule.minute, &schedule.hour,
+		&schedule.dom, &schedule.month, &schedule.dow,
+	}
+	bounds := []cronBounds{
+		cronSeconds, cronMinutes, cronHours, cronDom, cronMonths, cronDow,
+	}
+	for i, field := range fields {
+		*targets[i], err = parseCronField(field, bounds[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	//7 means sunday
+	if schedule.dow&(1<<7) > 0 {
+		schedule.dow = (schedule.dow | 1) &^ (1 << 7)
+	}
+	return schedule, nil
+}
+
+//get next activation time
+func (s *everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.delay - time.Duration(t.Nanosecond()))
+}
+
+//get next activation time
+func (s *specSchedule) Next(t time.Time) time.Time {
+	//convert into schedule location
+	orgLocation := t.Location()
+	t = t.In(s.location)
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	yearLimit := t.Year() + cronMaxSearchYears
+
+	//find from month to second
+	added := false
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+	for (1<<uint(t.Month()))&s.month == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, s.location)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+	for !s.dayMatched(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.location)
+		}
+		t = t.AddDate(0, 0, 1)
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+	for (1<<uint(t.Hour()))&s.hour == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, s.location)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+	for (1<<uint(t.Minute()))&s.minute == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+	for (1<<uint(t.Second()))&s.second == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+	return t.In(orgLocation)
+}
+
+//check day matched or not
+//if both day-of-month and day-of-week restricted, any one matched is ok
+func (s *specSchedule) dayMatched(t time.Time) bool {
+	domMatched := (1<<uint(t.Day()))&s.dom > 0
+	dowMatched := (1<<uint(t.Weekday()))&s.dow > 0
+	if s.dom&cronStarBit > 0 || s.dow&cronStarBit > 0 {
+		return domMatched && dowMatched
+	}
+	return domMatched || dowMatched
+}
+
+//parse one field, like `*/5`, `1-10/2`, `mon,wed,fri`
+func parseCronField(field string, b cronBounds) (uint64, error) {
+	var (
+		bits uint64
+	)
+	for _, part := range strings.Split(field, ",") {
+		partBits, err := parseCronRange(part, b)
+		if err != nil {
+			return 0, err
+		}
+		bits |= partBits
+	}
+	return bits, nil
+}
+
+//parse one range of field
+func parseCronRange(expr string, b cronBounds) (uint64, error) {
+	var (
+		start, end, step uint
+		extra uint64
+		err error
+	)
+	rangeAndStep := strings.Split(expr, "/")
+	lowAndHigh := strings.Split(rangeAndStep[0], "-")
+	singleDigit := len(lowAndHigh) == 1
+	if len(rangeAndStep) > 2 || len(lowAndHigh) > 2 {
+		return 0, fmt.Errorf("invalid cron field %q", expr)
+	}
+
+	//parse range
+	if lowAndHigh[0] == "*" || lowAndHigh[0] == "?" {
+		if !singleDigit {
+			return 0, fmt.Errorf("invalid cron field %q", expr)
+		}
+		start, end = b.min, b.max
+		extra = cronStarBit
+	}else{
+		if start, err = parseCronValue(lowAndHigh[0], b); err != nil {
+			return 0, err
+		}
+		end = start
+		if !singleDigit {
+			if end, err = parseCronValue(lowAndHigh[1], b); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	//parse step
+	step = 1
+	if len(rangeAndStep) == 2 {
+		stepVal, subErr := strconv.ParseUint(rangeAndStep[1], 10, 32)
+		if subErr != nil || stepVal <= 0 {
+			return 0, fmt.Errorf("invalid cron step %q", expr)
+		}
+		step = uint(stepVal)
+		if singleDigit {
+			//`n/step` means from n to max
+			end = b.max
+		}
+		if step > 1 {
+			extra = 0
+		}
+	}
+
+	//check
+	if start < b.min || end > b.max || start > end {
+		return 0, fmt.Errorf("cron field %q out of range [%d, %d]", expr, b.min, b.max)
+	}
+
+	//gen bits
+	bits := uint64(0)
+	for i := start; i <= end; i += step {
+		bits |= 1 << i
+	}
+	return bits | extra, nil
+}
+
+//parse single value or name
+func parseCronValue(expr string, b cronBounds) (uint, error) {
+	if b.names != nil {
+		if val, ok := b.names[strings.ToLower(expr)]; ok {
+			return val, nil
+		}
+	}
+	val, err := strconv.ParseUint(expr, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cron value %q", expr)
+	}
+	return uint(val), nil
+}
@@ -1,6 +1,9 @@
 package queue
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 //inter macro define
 const (
@@ -9,4 +12,32 @@ const (
 	DefaultGcRate             = 30
 	DefaultTenThousandPercent = 10000
 	DefaultTickTimer          = time.Second
+	DefaultListSegmentSize    = 16 * 1024 * 1024 //16MB
+	DefaultMinRestartBackoff  = 100 * time.Millisecond
+	DefaultMaxRestartBackoff  = 10 * time.Second
+	DefaultBatchSize          = 100
+	DefaultBatchMaxWait       = 100 * time.Millisecond
+	DefaultCronTickRate       = 0.2 //xx seconds
+)
+
+//queue overflow policy
+const (
+	OverflowBlock      = iota //block until has space or ctx done
+	OverflowFailFast          //return error at once
+	OverflowDropOldest        //drop oldest data in queue
+	OverflowDropNewest        //drop current input data
+)
+
+//cron job overlap policy
+const (
+	CronOverlapSkip  = iota //skip this run if previous run still going
+	CronOverlapQueue        //queue this run until previous run done
+)
+
+//inter error define
+var (
+	ErrQueueFull    = errors.New("inter queue size up to limit")
+	ErrQueueClosed  = errors.New("request chan is closed")
+	ErrQueueDropped = errors.New("data dropped by overflow policy")
+	ErrCallbackPanic = errors.New("callback panic")
 )
@@ -0,0 +1,485 @@
+package queue
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"log"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+ * delay and priority queue worker
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - item delivered not before assigned time
+ * - due items delivered by priority, higher first
+ * - with zero delay, works as priority queue
+ */
+
+//inter type
+type (
+	delayItem struct {
+		id        int64
+		data      interface{}
+		priority  int
+		deliverAt time.Time
+		seq       int64 //keep fifo for same priority
+		index     int   //index in heap
+		ready     bool  //in ready heap or not
+	}
+
+	//pending items, sorted by deliver time
+	delayHeap []*delayItem
+
+	//due items, sorted by priority
+	readyHeap []*delayItem
+)
+
+//face info
+type DelayQueue struct {
+	pending   delayHeap
+	ready     readyHeap
+	itemMap   map[int64]*delayItem //id -> item
+	priLens   map[int]int          //priority -> length
+	seq       int64
+	autoId    int64
+	wakeChan  chan struct{}
+	closeChan chan bool
+	closed    bool
+	cbForReq  func(id int64, data interface{}) error
+	cbForQuit func()
+	stat      *statCounter
+	sv        *supervisor
+	doneChan  chan struct{} //closed when main process quit
+	quitOnce  sync.Once
+	inFlight  int32
+	sync.RWMutex
+}
+
+//construct
+func NewDelayQueue() *DelayQueue {
+	this := &DelayQueue{
+		pending: delayHeap{},
+		ready: readyHeap{},
+		itemMap: map[int64]*delayItem{},
+		priLens: map[int]int{},
+		wakeChan: make(chan struct{}, 1),
+		closeChan: make(chan bool, 1),
+		stat: newStatCounter(),
+		sv: newSupervisor("delayQueue"),
+		doneChan: make(chan struct{}),
+	}
+
+	//spawn main process
+	this.sv.markAlive(true)
+	go this.runMainProcess()
+	return this
+}
+
+//quit
+func (f *DelayQueue) Quit() {
+	f.quitOnce.Do(func() {
+		f.Lock()
+		f.closed = true
+		f.Unlock()
+		select {
+		case f.closeChan <- true:
+		default: //ignore block
+		}
+	})
+}
+
+//shutdown gracefully
+//stop intake, deliver due items until ctx done
+//items not due yet are abandoned
+func (f *DelayQueue) Shutdown(ctx context.Context) *ShutdownReport {
+	//stop intake
+	f.Lock()
+	f.closed = true
+	f.Unlock()
+	before := f.GetStats()
+	report := &ShutdownReport{}
+
+	//wait for due items delivered
+	err := waitUntil(ctx, func() bool {
+		f.RLock()
+		defer f.RUnlock()
+		dueItems := len(f.ready)
+		if len(f.pending) > 0 && !f.pending[0].deliverAt.After(time.Now()) {
+			dueItems++
+		}
+		return dueItems <= 0 && atomic.LoadInt32(&f.inFlight) <= 0
+	})
+	report.TimedOut = err != nil
+	report.Abandoned = int64(f.Len())
+
+	//quit main process
+	f.Quit()
+	select {
+	case <- f.doneChan:
+	case <- ctx.Done():
+		report.TimedOut = true
+	}
+	after := f.GetStats()
+	report.Processed = after.Processed + after.Failed - before.Processed - before.Failed
+	return report
+}
+
+//set callback for process quit
+func (f *DelayQueue) SetQuitCallback(cb func()) bool {
+	if cb == nil {
+		return false
+	}
+	f.cbForQuit = cb
+	return true
+}
+
+//set callback for due data opt, STEP-1
+func (f *DelayQueue) SetCallback(
+	cb func(id int64, data interface{}) error) bool {
+	if cb == nil {
+		return false
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.cbForReq = cb
+	return true
+}
+
+//push data, STEP-2
+//if id <= 0, will gen new id
+//priorities -> higher value delivered first, default 0
+func (f *DelayQueue) Push(
+	id int64,
+	data interface{},
+	delay time.Duration,
+	priorities ...int) (int64, error) {
+	var (
+		priority int
+	)
+	//check
+	if data == nil {
+		return 0, errors.New("invalid parameter")
+	}
+	if priorities != nil && len(priorities) > 0 {
+		priority = priorities[0]
+	}
+
+	//data opt with locker
+	f.Lock()
+	defer f.Unlock()
+	if f.closed {
+		return 0, errors.New("delay queue has closed")
+	}
+	if id <= 0 {
+		f.autoId++
+		for f.itemMap[f.autoId] != nil {
+			f.autoId++
+		}
+		id = f.autoId
+	}
+	if _, ok := f.itemMap[id]; ok {
+		return 0, errors.New("item id has exists")
+	}
+
+	//init new item
+	f.seq++
+	item := &delayItem{
+		id: id,
+		data: data,
+		priority: priority,
+		deliverAt: time.Now().Add(delay),
+		seq: f.seq,
+	}
+	f.itemMap[id] = item
+	f.priLens[priority]++
+	f.schedule(item)
+	f.stat.addEnqueued(int64(len(f.itemMap)))
+	return id, nil
+}
+
+//reschedule item with new delay
+func (f *DelayQueue) Reschedule(id int64, delay time.Duration) error {
+	//data opt with locker
+	f.Lock()
+	defer f.Unlock()
+	item, ok := f.itemMap[id]
+	if !ok || item == nil {
+		return errors.New("no such item")
+	}
+
+	//remove from current heap and schedule again
+	f.detach(item)
+	item.deliverAt = time.Now().Add(delay)
+	f.schedule(item)
+	return nil
+}
+
+//cancel item by id
+func (f *DelayQueue) Cancel(id int64) error {
+	//data opt with locker
+	f.Lock()
+	defer f.Unlock()
+	item, ok := f.itemMap[id]
+	if !ok || item == nil {
+		return errors.New("no such item")
+	}
+	f.detach(item)
+	f.removeItemInfo(item)
+	f.stat.addDropped(1)
+	return nil
+}
+
+//check item exists or not
+func (f *DelayQueue) Exists(id int64) bool {
+	f.Lock()
+	defer f.Unlock()
+	_, ok := f.itemMap[id]
+	return ok
+}
+
+//get total length
+func (f *DelayQueue) Len() int {
+	f.Lock()
+	defer f.Unlock()
+	return len(f.itemMap)
+}
+
+//check main process is alive or not
+func (f *DelayQueue) Healthy() bool {
+	return f.sv.healthy()
+}
+
+//set supervisor for callback panic and process restart
+func (f *DelayQueue) SetSupervisor(conf *SupervisorConf) {
+	f.sv.setConf(conf)
+}
+
+//get runtime stats snapshot
+func (f *DelayQueue) GetStats() *Stats {
+	return f.stat.snapshot(int64(f.Len()))
+}
+
+//get length of assigned priority
+func (f *DelayQueue) LenOfPriority(priority int) int {
+	f.Lock()
+	defer f.Unlock()
+	return f.priLens[priority]
+}
+
+//get length of all priority levels
+func (f *DelayQueue) LenOfPriorities() map[int]int {
+	f.Lock()
+	defer f.Unlock()
+	result := make(map[int]int, len(f.priLens))
+	for k, v := range f.priLens {
+		result[k] = v
+	}
+	return result
+}
+
+///////////////
+//private func
+///////////////
+
+//check closed or not
+func (f *DelayQueue) isClosed() bool {
+	f.RLock()
+	defer f.RUnlock()
+	return f.closed
+}
+
+//put item into heap, should be called with locker
+func (f *DelayQueue) schedule(item *delayItem) {
+	if !item.deliverAt.After(time.Now()) {
+		item.ready = true
+		heap.Push(&f.ready, item)
+	}else{
+		item.ready = false
+		heap.Push(&f.pending, item)
+	}
+
+	//wake up main process
+	select {
+	case f.wakeChan <- struct{}{}:
+	default: //ignore block
+	}
+}
+
+//remove item from heap, should be called with locker
+func (f *DelayQueue) detach(item *delayItem) {
+	if item.index < 0 {
+		return
+	}
+	if item.ready {
+		heap.Remove(&f.ready, item.index)
+	}else{
+		heap.Remove(&f.pending, item.index)
+	}
+}
+
+//remove item info, should be called with locker
+func (f *DelayQueue) removeItemInfo(item *delayItem) {
+	delete(f.itemMap, item.id)
+	f.priLens[item.priority]--
+	if f.priLens[item.priority] <= 0 {
+		delete(f.priLens, item.priority)
+	}
+}
+
+//pick one due item, return next wait duration if no due item
+//picked item counted as in-flight
+func (f *DelayQueue) pickDueItem() (*delayItem, time.Duration) {
+	//data opt with locker
+	f.Lock()
+	defer f.Unlock()
+
+	//move due items into ready heap
+	now := time.Now()
+	for len(f.pending) > 0 && !f.pending[0].deliverAt.After(now) {
+		item := heap.Pop(&f.pending).(*delayItem)
+		item.ready = true
+		heap.Push(&f.ready, item)
+	}
+
+	//pick highest priority item
+	if len(f.ready) > 0 {
+		item := heap.Pop(&f.ready).(*delayItem)
+		f.removeItemInfo(item)
+		atomic.AddInt32(&f.inFlight, 1)
+		return item, 0
+	}
+	if len(f.pending) > 0 {
+		return nil, f.pending[0].deliverAt.Sub(now)
+	}
+	return nil, DefaultTickTimer
+}
+
+//run main process
+func (f *DelayQueue) runMainProcess() {
+	var (
+		item *delayItem
+		wait time.Duration
+		m any = nil
+	)
+	timer := time.NewTimer(DefaultTickTimer)
+
+	//defer
+	defer func() {
+		f.sv.markAlive(false)
+		timer.Stop()
+		if err := recover(); err != m {
+			log.Printf("delayQueue.runMainProcess panic, err:%v, trace:%v\n",
+				err, string(debug.Stack()))
+			if !f.isClosed() && f.sv.restart(f.runMainProcess) {
+				return
+			}
+		}
+		defer close(f.doneChan)
+
+		//call cb for quit
+		if f.cbForQuit != nil {
+			f.cbForQuit()
+		}
+	}()
+
+	//loop
+	f.sv.markAlive(true)
+	for {
+		//deliver all due items
+		for {
+			item, wait = f.pickDueItem()
+			if item == nil {
+				break
+			}
+			f.RLock()
+			cb := f.cbForReq
+			f.RUnlock()
+			if cb != nil {
+				begin := time.Now()
+				err := f.sv.safeCall(item.data, func() error {
+					return cb(item.id, item.data)
+				})
+				f.stat.addResult(err, time.Since(begin))
+			}
+			atomic.AddInt32(&f.inFlight, -1)
+		}
+
+		//wait for next due item
+		if !timer.Stop() {
+			select {
+			case <- timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+		select {
+		case <- timer.C:
+		case <- f.wakeChan:
+		case <- f.closeChan:
+			return
+		}
+	}
+}
+
+//////////////////
+//api for heaps
+//////////////////
+
+func (h delayHeap) Len() int { return len(h) }
+func (h delayHeap) Less(i, j int) bool {
+	if h[i].deliverAt.Equal(h[j].deliverAt) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].deliverAt.Before(h[j].deliverAt)
+}
+func (h delayHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *delayHeap) Push(x any) {
+	item := x.(*delayItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+func (h *delayHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*h = old[0 : n-1]
+	return item
+}
+
+func (h readyHeap) Len() int { return len(h) }
+func (h readyHeap) Less(i, j int) bool {
+	if h[i].priority == h[j].priority {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].priority > h[j].priority
+}
+func (h readyHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *readyHeap) Push(x any) {
+	item := x.(*delayItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+func (h *readyHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*h = old[0 : n-1]
+	return item
+}
@@ -0,0 +1,221 @@
+package queue
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+/*
+ * prometheus text format stats exporter
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - register queue, list, ticker, delay queue and worker by name
+ * - can be served as http handler
+ */
+
+//inter macro define
+const (
+	DefaultStatsNamespace = "tinylib"
+	statsContentType      = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+//stats source, like Queue, List, Ticker and DelayQueue
+type StatsSource interface {
+	GetStats() *Stats
+}
+
+//worker stats source, like Worker and TypedWorker
+type WorkerStatsSource interface {
+	GetStats() *WorkerStats
+}
+
+//inter type
+type (
+	statsMetric struct {
+		name  string
+		help  string
+		kind  string
+		value func(s *Stats) int64
+	}
+	labeledStats struct {
+		labels string
+		stats  *Stats
+	}
+)
+
+var statsMetrics = []statsMetric{
+	{"enqueued_total", "Accepted data count.", "counter", func(s *Stats) int64 { return s.Enqueued }},
+	{"processed_total", "Callback succeed count.", "counter", func(s *Stats) int64 { return s.Processed }},
+	{"failed_total", "Callback failed count.", "counter", func(s *Stats) int64 { return s.Failed }},
+	{"dropped_total", "Dropped data count.", "counter", func(s *Stats) int64 { return s.Dropped }},
+	{"rejected_total", "Rejected data count.", "counter", func(s *Stats) int64 { return s.Rejected }},
+	{"depth", "Current waiting data count.", "gauge", func(s *Stats) int64 { return s.Depth }},
+	{"high_water", "Max waiting data count.", "gauge", func(s *Stats) int64 { return s.HighWater }},
+}
+
+//face info
+type StatsExporter struct {
+	namespace string
+	sources   map[string]StatsSource //name -> source
+	workers   map[string]WorkerStatsSource //name -> worker
+	sync.RWMutex
+}
+
+//construct
+func NewStatsExporter(namespaces ...string) *StatsExporter {
+	namespace := DefaultStatsNamespace
+	if namespaces != nil && len(namespaces) > 0 && namespaces[0] != "" {
+		namespace = namespaces[0]
+	}
+	this := &StatsExporter{
+		namespace: namespace,
+		sources: map[string]StatsSource{},
+		workers: map[string]WorkerStatsSource{},
+	}
+	return this
+}
+
+//register stats source
+func (f *StatsExporter) Register(name string, src StatsSource) error {
+	//check
+	if name == "" || src == nil {
+		return errors.New("invalid parameter")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.sources[name] = src
+	return nil
+}
+
+//register worker, all son workers will be exported
+func (f *StatsExporter) RegisterWorker(name string, w WorkerStatsSource) error {
+	//check
+	if name == "" || w == nil {
+		return errors.New("invalid parameter")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.workers[name] = w
+	return nil
+}
+
+//unregister source or worker
+func (f *StatsExporter) Unregister(name string) {
+	f.Lock()
+	defer f.Unlock()
+	delete(f.sources, name)
+	delete(f.workers, name)
+}
+
+//export all stats with prometheus text format
+func (f *StatsExporter) Export(w io.Writer) error {
+	var (
+		labelStats []labeledStats
+	)
+	//collect stats snapshot
+	f.RLock()
+	for name, src := range f.sources {
+		labelStats = append(labelStats, labeledStats{
+			labels: fmt.Sprintf("name=%q", name),
+			stats: src.GetStats(),
+		})
+	}
+	workerStats := map[string]*WorkerStats{}
+	for name, v := range f.workers {
+		ws := v.GetStats()
+		workerStats[name] = ws
+		for _, sw := range ws.SonWorkers {
+			labels := fmt.Sprintf("name=%q,worker=\"%d\"", name, sw.WorkerId)
+			if sw.Queue != nil {
+				labelStats = append(labelStats, labeledStats{labels: labels + `,kind="queue"`, stats: sw.Queue})
+			}
+			if sw.Ticker != nil {
+				labelStats = append(labelStats, labeledStats{labels: labels + `,kind="ticker"`, stats: sw.Ticker})
+			}
+		}
+	}
+	f.RUnlock()
+	sort.Slice(labelStats, func(i, j int) bool {
+		return labelStats[i].labels < labelStats[j].labels
+	})
+
+	//write metrics
+	bw := bufio.NewWriter(w)
+	for _, metric := range statsMetrics {
+		fullName := f.namespace + "_queue_" + metric.name
+		fmt.Fprintf(bw, "# HELP %s %s\n", fullName, metric.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", fullName, metric.kind)
+		for _, ls := range labelStats {
+			fmt.Fprintf(bw, "%s{%s} %d\n", fullName, ls.labels, metric.value(ls.stats))
+		}
+	}
+	f.writeLatency(bw, labelStats)
+	f.writeWorkers(bw, workerStats)
+	return bw.Flush()
+}
+
+//serve as http handler
+func (f *StatsExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", statsContentType)
+	f.Export(w)
+}
+
+///////////////
+//private func
+///////////////
+
+//write callback latency histogram
+func (f *StatsExporter) writeLatency(w io.Writer, labelStats []labeledStats) {
+	fullName := f.namespace + "_queue_callback_seconds"
+	fmt.Fprintf(w, "# HELP %s Callback latency in seconds.\n", fullName)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", fullName)
+	for _, ls := range labelStats {
+		latency := ls.stats.Latency
+		if latency == nil {
+			continue
+		}
+		cumulative := int64(0)
+		for i, bound := range latency.Buckets {
+			cumulative += latency.Counts[i]
+			fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", fullName, ls.labels,
+				strconv.FormatFloat(bound, 'f', -1, 64), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", fullName, ls.labels, latency.Count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", fullName, ls.labels,
+			strconv.FormatFloat(latency.Sum, 'f', -1, 64))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", fullName, ls.labels, latency.Count)
+	}
+}
+
+//write worker info
+func (f *StatsExporter) writeWorkers(w io.Writer, workerStats map[string]*WorkerStats) {
+	names := make([]string, 0, len(workerStats))
+	for name := range workerStats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	//son workers count
+	fullName := f.namespace + "_worker_son_workers"
+	fmt.Fprintf(w, "# HELP %s Running son worker count.\n", fullName)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", fullName)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s{name=%q} %d\n", fullName, name, workerStats[name].Workers)
+	}
+
+	//bind objs of each son worker
+	fullName = f.namespace + "_worker_bind_objs"
+	fmt.Fprintf(w, "# HELP %s Bound object count of son worker.\n", fullName)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", fullName)
+	for _, name := range names {
+		for _, sw := range workerStats[name].SonWorkers {
+			fmt.Fprintf(w, "%s{name=%q,worker=\"%d\"} %d\n", fullName, name, sw.WorkerId, sw.BindObjs)
+		}
+	}
+}
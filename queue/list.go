@@ -2,7 +2,9 @@ package queue
 
 import (
 	"container/list"
+	"context"
 	"errors"
+	"fmt"
 	"log"
 	"math/rand"
 	"runtime"
@@ -18,25 +20,95 @@ import (
  * @mail <diudiu8848@163.com>
  */
 
+//disk list config
+type ListConf struct {
+	DataPath    string //segment files path
+	SegmentSize int64  //max bytes of one segment file
+	SyncWrite   bool   //fsync after each write or not
+}
+
+//non-generic list, element value is interface{}
+type List = TypedList[interface{}]
+
 //face info
-type List struct {
+//T -> element value type
+type TypedList[T any] struct {
 	l             *list.List
-	cbForConsumer func(interface{}) error
+	wal           *listWal                 //only for disk list
+	eleIds        map[*list.Element]uint64 //element -> wal item id
+	cbForConsumer func(T) error
+	cbForBatch    func([]T) error
+	batchSize     int
+	batchWait     time.Duration
 	enumCount     int64
 	closed        bool
+	closing       bool           //intake stopped
+	consuming     int32          //in-flight consume count
+	consumeWg     sync.WaitGroup //in-flight consume
+	wakeChan      chan struct{}  //wake up consume process
+	stat          *statCounter
+	sv            *supervisor
 	sync.RWMutex
 }
 
 //construct
 func NewList() *List {
-	this := &List{
+	return NewTypedList[interface{}]()
+}
+
+//construct typed list
+func NewTypedList[T any]() *TypedList[T] {
+	this := &TypedList[T]{
 		l: list.New(),
+		stat: newStatCounter(),
+		sv: newSupervisor("list"),
+		wakeChan: make(chan struct{}, 1),
 	}
 	return this
 }
 
+//construct disk list
+//un consumed elements will be replayed from segment files
+//custom value type should be registered by util.Gob.Register
+func NewDiskList(conf *ListConf) (*List, error) {
+	return NewTypedDiskList[interface{}](conf)
+}
+
+//construct typed disk list
+//replayed element value should be type of T
+func NewTypedDiskList[T any](conf *ListConf) (*TypedList[T], error) {
+	//init and replay wal
+	wal, items, err := newListWal(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	//self init
+	this := &TypedList[T]{
+		l: list.New(),
+		wal: wal,
+		eleIds: map[*list.Element]uint64{},
+		stat: newStatCounter(),
+		sv: newSupervisor("list"),
+		wakeChan: make(chan struct{}, 1),
+	}
+
+	//sync replayed items
+	for _, item := range items {
+		if _, ok := item.val.(T); !ok {
+			wal.close()
+			return nil, fmt.Errorf("replayed value %T is not %T", item.val, *new(T))
+		}
+		ele := this.l.PushBack(item.val)
+		this.eleIds[ele] = item.id
+	}
+	this.enumCount = int64(len(items))
+	return this, nil
+}
+
 //quit
-func (f *List) Quit(forces ...bool) {
+//should not be called inside consumer callback
+func (f *TypedList[T]) Quit(forces ...bool) {
 	var (
 		//listLen int
 		//data *list.Element
@@ -50,6 +122,10 @@ func (f *List) Quit(forces ...bool) {
 	f.Lock()
 	f.closed = true
 	f.Unlock()
+	f.wakeUp()
+
+	//wait for in-flight consume
+	f.consumeWg.Wait()
 
 	//force clear
 	if force {
@@ -57,8 +133,14 @@ func (f *List) Quit(forces ...bool) {
 		f.Lock()
 		defer f.Unlock()
 		//gc opt and reset list
+		f.stat.addDropped(int64(f.l.Len()))
 		f.l.Init()
+		f.resetEleIds()
 		atomic.StoreInt64(&f.enumCount, 0)
+		if f.wal != nil {
+			//keep segment files for replay
+			f.wal.close()
+		}
 		runtime.GC()
 		return
 	}
@@ -67,30 +149,107 @@ func (f *List) Quit(forces ...bool) {
 	f.processLeftList()
 }
 
+//shutdown gracefully
+//stop intake, consume left elements until ctx done
+//left elements of disk list are kept in segment files
+func (f *TypedList[T]) Shutdown(ctx context.Context) *ShutdownReport {
+	var (
+		err error
+	)
+	//stop intake
+	f.Lock()
+	f.closing = true
+	hasConsumer := f.cbForConsumer != nil || f.cbForBatch != nil
+	f.Unlock()
+	f.wakeUp()
+	before := f.GetStats()
+	report := &ShutdownReport{}
+
+	//wait for left elements consumed
+	if hasConsumer {
+		err = waitUntil(ctx, func() bool {
+			return f.Len() <= 0 && atomic.LoadInt32(&f.consuming) <= 0
+		})
+	}else if f.Len() > 0 {
+		err = errors.New("no consumer")
+	}
+	if err != nil {
+		//abandon left elements
+		report.Abandoned = f.Len()
+		report.TimedOut = ctx.Err() != nil
+		f.Quit(true)
+	}else{
+		f.Quit()
+	}
+	after := f.GetStats()
+	report.Processed = after.Processed + after.Failed - before.Processed - before.Failed
+	return report
+}
+
 //set consumer
 //real duration = rate * time.second
-func (f *List) SetConsumer(cb func(interface{}) error, rates ...float64) {
+func (f *TypedList[T]) SetConsumer(cb func(T) error, rates ...float64) {
 	//check
-	if cb == nil || f.cbForConsumer != nil {
+	if cb == nil || f.cbForConsumer != nil || f.cbForBatch != nil {
 		return
 	}
 
 	//set and run consume process
 	f.cbForConsumer = cb
+	f.sv.markAlive(true)
 	go f.runConsumeProcess(rates...)
 }
 
+//set batch consumer
+//consumer receives up to batchSize elements, flushed when batch full or max wait expired
+//if consumer failed, the whole batch of disk list will be retried
+func (f *TypedList[T]) SetBatchConsumer(
+	cb func([]T) error,
+	batchSize int,
+	maxWaits ...time.Duration) {
+	var (
+		maxWait time.Duration
+	)
+	//check
+	if cb == nil || f.cbForConsumer != nil || f.cbForBatch != nil {
+		return
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if maxWaits != nil && len(maxWaits) > 0 {
+		maxWait = maxWaits[0]
+	}
+	if maxWait <= 0 {
+		maxWait = DefaultBatchMaxWait
+	}
+
+	//set and run batch consume process
+	f.batchSize = batchSize
+	f.batchWait = maxWait
+	f.cbForBatch = cb
+	f.sv.markAlive(true)
+	go f.runBatchConsumeProcess()
+}
+
 //clear
-func (f *List) Clear() {
+func (f *TypedList[T]) Clear() {
 	f.Lock()
 	defer f.Unlock()
+	f.stat.addDropped(int64(f.l.Len()))
 	f.l.Init()
+	f.resetEleIds()
 	atomic.StoreInt64(&f.enumCount, 0)
+	if f.wal != nil {
+		if err := f.wal.reset(); err != nil {
+			log.Printf("list.Clear reset wal failed, err:%v\n", err)
+		}
+	}
 	runtime.GC()
 }
 
 //check closed or not
-func (f *List) Closed() bool {
+func (f *TypedList[T]) Closed() bool {
 	//data opt with locker
 	f.Lock()
 	defer f.Unlock()
@@ -98,20 +257,35 @@ func (f *List) Closed() bool {
 }
 
 //get length
-func (f *List) Len() int64 {
+func (f *TypedList[T]) Len() int64 {
 	//data opt with locker
 	f.Lock()
 	defer f.Unlock()
 	return f.enumCount
 }
 
+//check consume process is alive or not
+func (f *TypedList[T]) Healthy() bool {
+	return f.sv.healthy()
+}
+
+//set supervisor for consumer panic and process restart
+func (f *TypedList[T]) SetSupervisor(conf *SupervisorConf) {
+	f.sv.setConf(conf)
+}
+
+//get runtime stats snapshot
+func (f *TypedList[T]) GetStats() *Stats {
+	return f.stat.snapshot(f.Len())
+}
+
 //get element value
-func (f *List) GetVal(e *list.Element) interface{} {
-	return e.Value
+func (f *TypedList[T]) GetVal(e *list.Element) T {
+	return f.valOf(e)
 }
 
 //pop head
-func (f *List) Pop() *list.Element {
+func (f *TypedList[T]) Pop() *list.Element {
 	//data opt with locker
 	f.Lock()
 	defer f.Unlock()
@@ -125,6 +299,7 @@ func (f *List) Pop() *list.Element {
 	ele := f.l.Front()
 	defer func() {
 		f.l.Remove(ele)
+		f.ackEle(ele)
 		atomic.AddInt64(&f.enumCount, -1)
 		if f.enumCount <= 0 {
 			atomic.StoreInt64(&f.enumCount, 0)
@@ -146,7 +321,7 @@ func (f *List) Pop() *list.Element {
 }
 
 //pop tail
-func (f *List) Tail() *list.Element {
+func (f *TypedList[T]) Tail() *list.Element {
 	//data opt with locker
 	f.Lock()
 	defer f.Unlock()
@@ -160,6 +335,7 @@ func (f *List) Tail() *list.Element {
 	ele := f.l.Back()
 	defer func() {
 		f.l.Remove(ele)
+		f.ackEle(ele)
 		atomic.AddInt64(&f.enumCount, -1)
 		if f.enumCount <= 0 {
 			atomic.StoreInt64(&f.enumCount, 0)
@@ -180,40 +356,66 @@ func (f *List) Tail() *list.Element {
 }
 
 //join head
-func (f *List) Join(val interface{}) error {
-	if val == nil {
+func (f *TypedList[T]) Join(val T) error {
+	if any(val) == nil {
 		return errors.New("invalid parameter")
 	}
 
 	//data opt with locker
 	f.Lock()
 	defer f.Unlock()
-	if f.closed {
+	if f.closed || f.closing {
 		return errors.New("list has closed")
 	}
 
+	//write into wal first
+	id, err := f.addToWal(walOpJoin, val)
+	if err != nil {
+		return err
+	}
+
 	//push data to front
-	f.l.PushFront(val)
+	ele := f.l.PushFront(val)
+	if f.wal != nil {
+		f.eleIds[ele] = id
+	}
 	atomic.AddInt64(&f.enumCount, 1)
+	f.stat.addEnqueued(f.enumCount)
+	if f.cbForBatch != nil {
+		f.wakeUp()
+	}
 	return nil
 }
 
 //push back
-func (f *List) Push(val interface{}) error {
-	if val == nil {
+func (f *TypedList[T]) Push(val T) error {
+	if any(val) == nil {
 		return errors.New("invalid parameter")
 	}
 
 	//data opt with locker
 	f.Lock()
 	defer f.Unlock()
-	if f.closed {
+	if f.closed || f.closing {
 		return errors.New("list has closed")
 	}
 
+	//write into wal first
+	id, err := f.addToWal(walOpPush, val)
+	if err != nil {
+		return err
+	}
+
 	//push data back
-	f.l.PushBack(val)
+	ele := f.l.PushBack(val)
+	if f.wal != nil {
+		f.eleIds[ele] = id
+	}
 	atomic.AddInt64(&f.enumCount, 1)
+	f.stat.addEnqueued(f.enumCount)
+	if f.cbForBatch != nil {
+		f.wakeUp()
+	}
 	return nil
 }
 
@@ -221,8 +423,181 @@ func (f *List) Push(val interface{}) error {
 //private func
 ///////////////
 
+//get typed value of element
+func (f *TypedList[T]) valOf(e *list.Element) T {
+	val, _ := e.Value.(T)
+	return val
+}
+
+//wake up consume process
+func (f *TypedList[T]) wakeUp() {
+	select {
+	case f.wakeChan <- struct{}{}:
+	default: //ignore block
+	}
+}
+
+//check closing or not
+func (f *TypedList[T]) isClosing() bool {
+	f.RLock()
+	defer f.RUnlock()
+	return f.closing
+}
+
+//add element into wal, return item id
+func (f *TypedList[T]) addToWal(op int, val interface{}) (uint64, error) {
+	if f.wal == nil {
+		return 0, nil
+	}
+	id := f.wal.genId()
+	err := f.wal.add(op, id, val)
+	return id, err
+}
+
+//ack element in wal, should be called with locker
+func (f *TypedList[T]) ackEle(ele *list.Element) {
+	if f.wal == nil || ele == nil {
+		return
+	}
+	id, ok := f.eleIds[ele]
+	if !ok {
+		return
+	}
+	delete(f.eleIds, ele)
+	if err := f.wal.ack(id); err != nil {
+		log.Printf("list.ackEle failed, id:%v, err:%v\n", id, err)
+	}
+}
+
+//reset element ids, should be called with locker
+func (f *TypedList[T]) resetEleIds() {
+	if f.wal != nil {
+		f.eleIds = map[*list.Element]uint64{}
+	}
+}
+
+//pop head for consume
+//disk list element will be acked after consumed
+func (f *TypedList[T]) popForConsume() (*list.Element, uint64) {
+	//data opt with locker
+	f.Lock()
+	defer f.Unlock()
+
+	//check
+	if f.enumCount <= 0 || f.closed {
+		return nil, 0
+	}
+
+	//pop data opt
+	ele := f.l.Front()
+	f.l.Remove(ele)
+	atomic.AddInt64(&f.enumCount, -1)
+	if f.enumCount <= 0 {
+		atomic.StoreInt64(&f.enumCount, 0)
+	}
+	id := f.eleIds[ele]
+	delete(f.eleIds, ele)
+	f.consumeWg.Add(1)
+	atomic.AddInt32(&f.consuming, 1)
+	return ele, id
+}
+
+//call consumer with panic recover
+func (f *TypedList[T]) callConsumer(val T) error {
+	begin := time.Now()
+	err := f.sv.safeCall(val, func() error {
+		return f.cbForConsumer(val)
+	})
+	f.stat.addResult(err, time.Since(begin))
+	return err
+}
+
+//consume one element
+//panic element has been sent to dead letter, so ack it
+func (f *TypedList[T]) consume(ele *list.Element, id uint64) {
+	defer func() {
+		atomic.AddInt32(&f.consuming, -1)
+		f.consumeWg.Done()
+	}()
+	err := f.callConsumer(f.valOf(ele))
+	if f.wal == nil {
+		return
+	}
+
+	//data opt with locker
+	f.Lock()
+	defer f.Unlock()
+	if err == nil || errors.Is(err, ErrCallbackPanic) {
+		if subErr := f.wal.ack(id); subErr != nil {
+			log.Printf("list.consume ack failed, id:%v, err:%v\n", id, subErr)
+		}
+		return
+	}
+	if f.closed {
+		//keep in wal for replay
+		return
+	}
+
+	//consume failed, push back to front for retry
+	newEle := f.l.PushFront(ele.Value)
+	f.eleIds[newEle] = id
+	atomic.AddInt64(&f.enumCount, 1)
+}
+
+//call batch consumer with panic recover
+func (f *TypedList[T]) callBatchConsumer(vals []T) error {
+	begin := time.Now()
+	err := f.sv.safeCall(vals, func() error {
+		return f.cbForBatch(vals)
+	})
+	f.stat.addBatchResult(err, len(vals), time.Since(begin))
+	return err
+}
+
+//consume batch elements
+//panic elements have been sent to dead letter, so ack them
+func (f *TypedList[T]) consumeBatch(eles []*list.Element, ids []uint64) {
+	defer func() {
+		for range eles {
+			atomic.AddInt32(&f.consuming, -1)
+			f.consumeWg.Done()
+		}
+	}()
+	vals := make([]T, len(eles))
+	for i, ele := range eles {
+		vals[i] = f.valOf(ele)
+	}
+	err := f.callBatchConsumer(vals)
+	if f.wal == nil {
+		return
+	}
+
+	//data opt with locker
+	f.Lock()
+	defer f.Unlock()
+	if err == nil || errors.Is(err, ErrCallbackPanic) {
+		for _, id := range ids {
+			if subErr := f.wal.ack(id); subErr != nil {
+				log.Printf("list.consumeBatch ack failed, id:%v, err:%v\n", id, subErr)
+			}
+		}
+		return
+	}
+	if f.closed {
+		//keep in wal for replay
+		return
+	}
+
+	//consume failed, push back to front for retry with origin order
+	for i := len(eles) - 1; i >= 0; i-- {
+		newEle := f.l.PushFront(eles[i].Value)
+		f.eleIds[newEle] = ids[i]
+	}
+	atomic.AddInt64(&f.enumCount, int64(len(eles)))
+}
+
 //process left list
-func (f *List) processLeftList()  {
+func (f *TypedList[T]) processLeftList()  {
 	var (
 		listLen int
 		data *list.Element
@@ -231,16 +606,36 @@ func (f *List) processLeftList()  {
 	//process left list elements with locker
 	f.Lock()
 	defer f.Unlock()
+	for f.cbForBatch != nil && f.l.Len() > 0 {
+		//pop batch elements
+		eles := make([]*list.Element, 0, f.batchSize)
+		vals := make([]T, 0, f.batchSize)
+		for e := f.l.Front(); e != nil && len(eles) < f.batchSize; e = e.Next() {
+			eles = append(eles, e)
+			vals = append(vals, f.valOf(e))
+		}
+		err := f.callBatchConsumer(vals)
+		for _, e := range eles {
+			f.l.Remove(e)
+			if err == nil || errors.Is(err, ErrCallbackPanic) {
+				f.ackEle(e)
+			}
+		}
+		atomic.AddInt64(&f.enumCount, -int64(len(eles)))
+	}
 	for {
 		listLen = f.l.Len()
-		if listLen <= 0 {
+		if listLen <= 0 || f.cbForConsumer == nil {
 			break
 		}
 		//pop front element
 		data = f.l.Front()
 		if data != nil && data.Value != nil {
-			f.cbForConsumer(data.Value)
+			err := f.callConsumer(f.valOf(data))
 			f.l.Remove(data)
+			if err == nil || errors.Is(err, ErrCallbackPanic) {
+				f.ackEle(data)
+			}
 			atomic.AddInt64(&f.enumCount, -1)
 			if f.enumCount <= 0 {
 				atomic.StoreInt64(&f.enumCount, 0)
@@ -250,12 +645,16 @@ func (f *List) processLeftList()  {
 
 	//gc opt and reset list
 	f.l.Init()
+	f.resetEleIds()
 	atomic.StoreInt64(&f.enumCount, 0)
+	if f.wal != nil {
+		f.wal.close()
+	}
 	runtime.GC()
 }
 
 //run consume process
-func (f *List) runConsumeProcess(rates ...float64) {
+func (f *TypedList[T]) runConsumeProcess(rates ...float64) {
 	var (
 		rate float64
 		m any = nil
@@ -274,9 +673,17 @@ func (f *List) runConsumeProcess(rates ...float64) {
 
 	//defer panic
 	defer func() {
+		f.sv.markAlive(false)
 		if err := recover(); err != m {
 			log.Printf("list.runConsumeProcess panic, err:%v, trace:%v\n",
 				err, string(debug.Stack()))
+			restartFunc := func() {
+				f.sv.markAlive(true)
+				f.runConsumeProcess(rates...)
+			}
+			if !f.Closed() && f.sv.restart(restartFunc) {
+				return
+			}
 		}
 		//process left elements
 		f.processLeftList()
@@ -288,16 +695,93 @@ func (f *List) runConsumeProcess(rates ...float64) {
 	//loop
 	for {
 		//check
-		if f.closed {
+		if f.Closed() {
 			return
 		}
 
 		//pop front element and consume
-		ele := f.Pop()
-		if ele != nil && ele.Value != nil {
-			f.cbForConsumer(ele.Value)
+		ele, id := f.popForConsume()
+		if ele != nil {
+			f.consume(ele, id)
+			if f.isClosing() {
+				//drain without wait
+				continue
+			}
+		}
+		select {
+		case <- time.After(rateDuration):
+		case <- f.wakeChan:
 		}
-		time.Sleep(rateDuration)
 	}
 }
 
+//run batch consume process
+func (f *TypedList[T]) runBatchConsumeProcess() {
+	var (
+		eles []*list.Element
+		ids []uint64
+		firstAt time.Time
+		m any = nil
+	)
+
+	//defer panic
+	defer func() {
+		f.sv.markAlive(false)
+		if err := recover(); err != m {
+			log.Printf("list.runBatchConsumeProcess panic, err:%v, trace:%v\n",
+				err, string(debug.Stack()))
+			restartFunc := func() {
+				f.sv.markAlive(true)
+				f.runBatchConsumeProcess()
+			}
+			if !f.Closed() && f.sv.restart(restartFunc) {
+				return
+			}
+		}
+		//process left elements
+		f.processLeftList()
+	}()
+
+	//loop
+	for {
+		//check
+		if f.Closed() {
+			if len(eles) > 0 {
+				f.consumeBatch(eles, ids)
+			}
+			return
+		}
+
+		//pop elements until batch full
+		ele, id := f.popForConsume()
+		if ele != nil {
+			if len(eles) <= 0 {
+				firstAt = time.Now()
+			}
+			eles = append(eles, ele)
+			ids = append(ids, id)
+			if len(eles) < f.batchSize {
+				continue
+			}
+		}
+
+		//flush when batch full or max wait expired
+		waited := time.Since(firstAt)
+		if len(eles) > 0 && (len(eles) >= f.batchSize ||
+			waited >= f.batchWait || f.isClosing()) {
+			f.consumeBatch(eles, ids)
+			eles, ids = nil, nil
+			continue
+		}
+
+		//wait for more elements
+		wait := f.batchWait
+		if len(eles) > 0 {
+			wait = f.batchWait - waited
+		}
+		select {
+		case <- time.After(wait):
+		case <- f.wakeChan:
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package queue
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/andyzhou/tinylib/util"
@@ -8,6 +9,7 @@ import (
 	"math/rand"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,26 +21,61 @@ import (
 
 //inter type
 type (
-	interReq struct {
-		req interface{} //origin input request
-		resp chan interface{}
+	interReq[T, R any] struct {
+		ctx context.Context
+		req T //origin input request
+		resp chan interResp[R]
 		needResp bool
 	}
+	interResp[R any] struct {
+		data R
+		err error
+	}
 )
 
+//send option
+type SendOption struct {
+	Policy   int  //overflow policy, default block
+	NeedResp bool //wait for callback response or not
+}
+
+//non-generic queue, data and response are interface{}
+type Queue = TypedQueue[interface{}, interface{}]
+
 //face info
-type Queue struct {
+//T -> input data type, R -> callback response type
+type TypedQueue[T, R any] struct {
 	queueSize int
-	reqChan   chan interReq
+	reqChan   chan interReq[T, R]
 	closeChan chan bool
-	cbForReq  func(data interface{}) (interface{}, error)
+	cbForReq  func(data T) (R, error)
 	cbForQuit func()
+
+	//batch mode
+	cbForBatch func(data []T) ([]R, error)
+	batchSize  int
+	batchWait  time.Duration
+	batch      []interReq[T, R]
+	batchTimer *time.Timer
+
+	stat      *statCounter
+	sv        *supervisor
+	doneChan  chan struct{} //closed when main process quit
+	quitOnce  sync.Once
+	closing   int32 //intake stopped
+	abandon   int32 //abandon left data
+	inFlight  int32
 	util.Util
 	sync.RWMutex
 }
 
 //construct
 func NewQueue(queueSizes ...int) *Queue {
+	return NewTypedQueue[interface{}, interface{}](queueSizes...)
+}
+
+//construct typed queue
+func NewTypedQueue[T, R any](queueSizes ...int) *TypedQueue[T, R] {
 	var (
 		queueSize int
 	)
@@ -53,105 +90,172 @@ func NewQueue(queueSizes ...int) *Queue {
 	}
 
 	//self init
-	this := &Queue{
+	this := &TypedQueue[T, R]{
 		queueSize: queueSize,
-		reqChan: make(chan interReq, queueSize),
+		reqChan: make(chan interReq[T, R], queueSize),
 		closeChan: make(chan bool, 1),
+		stat: newStatCounter(),
+		sv: newSupervisor("queue"),
+		doneChan: make(chan struct{}),
 	}
 
 	//spawn main process
+	this.sv.markAlive(true)
 	go this.runMainProcess()
 	return this
 }
 
 //quit
-func (f *Queue) Quit() {
-	//close closeChan first
-	if f.closeChan != nil {
-		select {
-		case f.closeChan <- true:
-		default: //ignore block
-		}
-		close(f.closeChan)
-	}
+func (f *TypedQueue[T, R]) Quit() {
+	f.quitOnce.Do(f.quit)
+}
 
-	//wait awhile to let goroutine quit
-	time.Sleep(10 * time.Millisecond)
+//shutdown gracefully
+//stop intake, drain left data until ctx done
+func (f *TypedQueue[T, R]) Shutdown(ctx context.Context) *ShutdownReport {
+	//stop intake
+	atomic.StoreInt32(&f.closing, 1)
+	before := f.GetStats()
+	report := &ShutdownReport{}
+
+	//wait for left data processed
+	err := waitUntil(ctx, func() bool {
+		return len(f.reqChan) <= 0 && atomic.LoadInt32(&f.inFlight) <= 0
+	})
+	if err != nil {
+		//abandon left data
+		atomic.StoreInt32(&f.abandon, 1)
+		report.Abandoned = int64(len(f.reqChan))
+		report.TimedOut = true
+	}
 
-	//close reqChan
-	if f.reqChan != nil {
-		close(f.reqChan)
+	//quit main process
+	f.Quit()
+	select {
+	case <- f.doneChan:
+	case <- ctx.Done():
+		report.TimedOut = true
 	}
+	after := f.GetStats()
+	report.Processed = after.Processed + after.Failed - before.Processed - before.Failed
+	return report
 }
 
 //check queue is closed
-func (f *Queue) QueueClosed() bool {
+func (f *TypedQueue[T, R]) QueueClosed() bool {
 	closed, _ := f.IsChanClosed(f.reqChan)
 	return closed
 }
 
 //get run queue size
-func (f *Queue) GetQueueSize() int {
+func (f *TypedQueue[T, R]) GetQueueSize() int {
 	return len(f.reqChan)
 }
 
+//check main process is alive or not
+func (f *TypedQueue[T, R]) Healthy() bool {
+	return f.sv.healthy()
+}
+
+//set supervisor for callback panic and process restart
+func (f *TypedQueue[T, R]) SetSupervisor(conf *SupervisorConf) {
+	f.sv.setConf(conf)
+}
+
+//get runtime stats snapshot
+func (f *TypedQueue[T, R]) GetStats() *Stats {
+	return f.stat.snapshot(int64(len(f.reqChan)))
+}
+
 //send data, STEP-2
-func (f *Queue) SendData(
-	data interface{},
-	needResponses...bool) (interface{}, error) {
+//fail fast if queue is full
+func (f *TypedQueue[T, R]) SendData(
+	data T,
+	needResponses...bool) (R, error) {
 	var (
-		resp interface{}
 		needResponse bool
 	)
+	//detect
+	if needResponses != nil && len(needResponses) > 0 {
+		needResponse = needResponses[0]
+	}
+
+	//setup option
+	opt := &SendOption{
+		Policy: OverflowFailFast,
+		NeedResp: needResponse,
+	}
+	return f.SendDataCtx(context.Background(), data, opt)
+}
+
+//send data with context, STEP-2
+//if ctx canceled or timeout, return ctx error
+//if queue is full, process by option policy
+func (f *TypedQueue[T, R]) SendDataCtx(
+	ctx context.Context,
+	data T,
+	opts ...*SendOption) (R, error) {
+	var (
+		opt *SendOption
+		resp interResp[R]
+		zero R
+	)
 	//check
-	if data == nil || data == "" {
-		return nil, errors.New("invalid parameter")
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if any(data) == nil || any(data) == "" {
+		return zero, errors.New("invalid parameter")
 	}
 	if f.reqChan == nil {
-		return nil, errors.New("inter chan is nil")
+		return zero, errors.New("inter chan is nil")
+	}
+	if opts != nil && len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt == nil {
+		opt = &SendOption{}
 	}
 
 	//check queue chan is active
+	if atomic.LoadInt32(&f.closing) > 0 {
+		return zero, ErrQueueClosed
+	}
 	chanIsClosed, _ := f.IsChanClosed(f.reqChan)
 	if chanIsClosed {
-		return nil, errors.New("request chan is closed")
-	}
-
-	//check active queue size
-	queueSize := f.GetQueueSize()
-	if queueSize >= f.queueSize {
-		return nil, fmt.Errorf("inter queue size %v up to limit %v", queueSize, f.queueSize)
-	}
-
-	//detect
-	if needResponses != nil && len(needResponses) > 0 {
-		needResponse = needResponses[0]
+		return zero, ErrQueueClosed
 	}
 
 	//setup inter request
-	req := interReq{
+	req := interReq[T, R]{
+		ctx: ctx,
 		req: data,
-		needResp: needResponse,
+		needResp: opt.NeedResp,
 	}
-	if needResponse {
-		req.resp = make(chan interface{}, 1)
+	if opt.NeedResp {
+		req.resp = make(chan interResp[R], 1)
 	}
 
-	//send to chan with async mode
-	//select {
-	//case f.reqChan <- req:
-	//}
-	f.reqChan <- req
-	if needResponse {
-		//wait for response
-		resp, _ = <- req.resp
+	//send to chan by policy
+	err := f.sendReq(ctx, req, opt.Policy)
+	if err != nil {
+		return zero, err
+	}
+	if !opt.NeedResp {
+		return zero, nil
 	}
 
-	return resp, nil
+	//wait for response
+	select {
+	case resp = <- req.resp:
+		return resp.data, resp.err
+	case <- ctx.Done():
+		return zero, ctx.Err()
+	}
 }
 
 //set callback for process quit
-func (f *Queue) SetQuitCallback(cb func()) bool {
+func (f *TypedQueue[T, R]) SetQuitCallback(cb func()) bool {
 	if cb == nil {
 		return false
 	}
@@ -160,8 +264,8 @@ func (f *Queue) SetQuitCallback(cb func()) bool {
 }
 
 //set callback for data opt, STEP-1
-func (f *Queue) SetCallback(
-	cb func(data interface{}) (interface{}, error)) bool {
+func (f *TypedQueue[T, R]) SetCallback(
+	cb func(data T) (R, error)) bool {
 	if cb == nil {
 		return false
 	}
@@ -169,41 +273,266 @@ func (f *Queue) SetCallback(
 	return true
 }
 
+//set batch callback for data opt, STEP-1
+//callback receives up to batchSize data, flushed when batch full or max wait expired
+//results should be the same order of input data, err for the whole batch
+func (f *TypedQueue[T, R]) SetBatchCallback(
+	cb func(data []T) ([]R, error),
+	batchSize int,
+	maxWaits ...time.Duration) bool {
+	var (
+		maxWait time.Duration
+	)
+	if cb == nil {
+		return false
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if maxWaits != nil && len(maxWaits) > 0 {
+		maxWait = maxWaits[0]
+	}
+	if maxWait <= 0 {
+		maxWait = DefaultBatchMaxWait
+	}
+	f.batchSize = batchSize
+	f.batchWait = maxWait
+	f.cbForBatch = cb
+	return true
+}
+
 ///////////////
 //private func
 ///////////////
 
-//process left data in chan
-func (f *Queue) processChanLeftData() {
+//send request to chan by overflow policy
+func (f *TypedQueue[T, R]) sendReq(
+	ctx context.Context,
+	req interReq[T, R],
+	policy int) (err error) {
 	var (
-		data interface{}
-		isOk bool
+		m any = nil
+	)
+	//defer for closed chan
+	defer func() {
+		if subErr := recover(); subErr != m {
+			err = ErrQueueClosed
+		}
+	}()
+
+	switch policy {
+	case OverflowFailFast:
+		select {
+		case f.reqChan <- req:
+		default:
+			err = fmt.Errorf("%w, queue size %v", ErrQueueFull, f.queueSize)
+			f.stat.addRejected()
+		}
+	case OverflowDropNewest:
+		select {
+		case f.reqChan <- req:
+		default:
+			err = ErrQueueDropped
+			f.stat.addDropped(1)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case f.reqChan <- req:
+				f.stat.addEnqueued(int64(len(f.reqChan)))
+				return nil
+			default:
+			}
+			//drop the oldest one and notify its sender
+			select {
+			case oldReq, isOk := <- f.reqChan:
+				if !isOk {
+					return ErrQueueClosed
+				}
+				if oldReq.needResp {
+					oldReq.resp <- interResp[R]{err: ErrQueueDropped}
+				}
+				f.stat.addDropped(1)
+			default:
+			}
+			if ctx.Err() != nil {
+				f.stat.addRejected()
+				return ctx.Err()
+			}
+		}
+	default:
+		select {
+		case f.reqChan <- req:
+		case <- ctx.Done():
+			err = ctx.Err()
+			f.stat.addRejected()
+		}
+	}
+	if err == nil {
+		f.stat.addEnqueued(int64(len(f.reqChan)))
+	}
+	return err
+}
+
+//process one request
+func (f *TypedQueue[T, R]) processReq(orgReq *interReq[T, R]) {
+	var (
+		resp interResp[R]
+	)
+	if orgReq.needResp && orgReq.ctx != nil && orgReq.ctx.Err() != nil {
+		//waiting sender has gone, skip it
+		resp.err = orgReq.ctx.Err()
+		f.stat.addDropped(1)
+	}else if atomic.LoadInt32(&f.abandon) > 0 {
+		//shutdown timeout, abandon it
+		resp.err = ErrQueueClosed
+		f.stat.addDropped(1)
+	}else if f.cbForReq == nil {
+		resp.err = errors.New("queue callback not setup")
+	}else{
+		begin := time.Now()
+		resp.err = f.sv.safeCall(orgReq.req, func() (err error) {
+			resp.data, err = f.cbForReq(orgReq.req)
+			return err
+		})
+		f.stat.addResult(resp.err, time.Since(begin))
+	}
+	if orgReq.needResp {
+		orgReq.resp <- resp
+	}
+}
+
+//quit main process
+func (f *TypedQueue[T, R]) quit() {
+	//close closeChan first
+	if f.closeChan != nil {
+		select {
+		case f.closeChan <- true:
+		default: //ignore block
+		}
+		close(f.closeChan)
+	}
+
+	//wait awhile to let goroutine quit
+	time.Sleep(10 * time.Millisecond)
+
+	//close reqChan
+	if f.reqChan != nil {
+		isClosed, _ := f.IsChanClosed(f.reqChan)
+		if !isClosed {
+			close(f.reqChan)
+		}
+	}
+}
+
+//add request into batch, flush if batch full
+func (f *TypedQueue[T, R]) addToBatch(orgReq interReq[T, R]) {
+	atomic.AddInt32(&f.inFlight, 1)
+	f.batch = append(f.batch, orgReq)
+	if len(f.batch) == 1 {
+		f.batchTimer = time.NewTimer(f.batchWait)
+	}
+	if len(f.batch) >= f.batchSize {
+		f.flushBatch()
+	}
+}
+
+//flush batch requests
+func (f *TypedQueue[T, R]) flushBatch() {
+	var (
+		results []R
 	)
-	//check chan
-	if f.reqChan == nil || len(f.reqChan) <= 0 {
+	//check
+	if len(f.batch) <= 0 {
+		return
+	}
+	batch := f.batch
+	f.batch = nil
+	if f.batchTimer != nil {
+		f.batchTimer.Stop()
+		f.batchTimer = nil
+	}
+	defer atomic.AddInt32(&f.inFlight, -int32(len(batch)))
+
+	//filter invalid requests
+	validReqs := make([]interReq[T, R], 0, len(batch))
+	for _, orgReq := range batch {
+		err := error(nil)
+		if orgReq.needResp && orgReq.ctx != nil && orgReq.ctx.Err() != nil {
+			//waiting sender has gone
+			err = orgReq.ctx.Err()
+		}else if atomic.LoadInt32(&f.abandon) > 0 {
+			err = ErrQueueClosed
+		}
+		if err == nil {
+			validReqs = append(validReqs, orgReq)
+			continue
+		}
+		f.stat.addDropped(1)
+		if orgReq.needResp {
+			orgReq.resp <- interResp[R]{err: err}
+		}
+	}
+	if len(validReqs) <= 0 {
 		return
 	}
 
+	//call batch cb
+	datas := make([]T, len(validReqs))
+	for i, orgReq := range validReqs {
+		datas[i] = orgReq.req
+	}
+	begin := time.Now()
+	err := f.sv.safeCall(datas, func() (err error) {
+		results, err = f.cbForBatch(datas)
+		return err
+	})
+	f.stat.addBatchResult(err, len(validReqs), time.Since(begin))
+
+	//deliver result of each request
+	for i, orgReq := range validReqs {
+		if !orgReq.needResp {
+			continue
+		}
+		resp := interResp[R]{err: err}
+		if err == nil && i < len(results) {
+			resp.data = results[i]
+		}
+		orgReq.resp <- resp
+	}
+}
+
+//process left data in chan
+func (f *TypedQueue[T, R]) processChanLeftData() {
+	var (
+		orgReq interReq[T, R]
+		isOk bool
+	)
 	//process left data of chan one by one
-	for {
+	for f.reqChan != nil && len(f.reqChan) > 0 {
 		//pick data from chan
-		data, isOk = <- f.reqChan
-		if isOk && data != nil {
-			if f.cbForReq != nil {
-				f.cbForReq(data)
-			}
+		orgReq, isOk = <- f.reqChan
+		if !isOk {
+			break
+		}
+		if f.cbForBatch != nil {
+			f.addToBatch(orgReq)
+		}else{
+			f.processReq(&orgReq)
 		}
 	}
 
+	//flush left batch
+	f.flushBatch()
+
 	//gc opt
 	runtime.GC()
 }
 
 //run main process
-func (f *Queue) runMainProcess() {
+func (f *TypedQueue[T, R]) runMainProcess() {
 	var (
-		orgReq interReq
-		resp interface{}
+		orgReq interReq[T, R]
 		isOk bool
 		isQuitting bool
 		m any = nil
@@ -211,12 +540,17 @@ func (f *Queue) runMainProcess() {
 
 	//defer
 	defer func() {
+		f.sv.markAlive(false)
 		if err := recover(); err != m {
 			log.Printf("queue.runMainProcess panic, err:%v\n", err)
+			if !isQuitting && f.sv.restart(f.runMainProcess) {
+				return
+			}
 		}
 
 		//process left data in chan
 		f.processChanLeftData()
+		defer close(f.doneChan)
 
 		//call cb for quit
 		if isQuitting {
@@ -244,9 +578,16 @@ func (f *Queue) runMainProcess() {
 
 	//setup seed
 	rand.Seed(time.Now().UnixNano())
+	f.sv.markAlive(true)
 
 	//loop
 	for {
+		//batch flush timer
+		var batchTimerChan <- chan time.Time
+		if f.batchTimer != nil {
+			batchTimerChan = f.batchTimer.C
+		}
+
 		select {
 		case orgReq, isOk = <- f.reqChan:
 			{
@@ -255,11 +596,12 @@ func (f *Queue) runMainProcess() {
 					return
 				}
 				//process request
-				if isOk && &orgReq != nil && f.cbForReq != nil {
-					resp, _ = f.cbForReq(orgReq.req)
-					if orgReq.needResp {
-						orgReq.resp <- resp
-					}
+				if isOk && f.cbForBatch != nil {
+					f.addToBatch(orgReq)
+				}else if isOk {
+					atomic.AddInt32(&f.inFlight, 1)
+					f.processReq(&orgReq)
+					atomic.AddInt32(&f.inFlight, -1)
 				}
 				//force gc opt check and run
 				randVal := rand.Intn(DefaultTenThousandPercent)
@@ -267,6 +609,8 @@ func (f *Queue) runMainProcess() {
 					runtime.GC()
 				}
 			}
+		case <- batchTimerChan:
+			f.flushBatch()
 		case <- f.closeChan:
 			{
 				isQuitting = true
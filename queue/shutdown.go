@@ -0,0 +1,153 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"github.com/andyzhou/tinylib/util"
+	"log"
+	"sync"
+	"time"
+)
+
+/*
+ * graceful shutdown for queue workers
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - stop intake, drain in-flight data until deadline
+ * - shutdown group can be triggered by util.Signal
+ */
+
+//inter macro define
+const (
+	shutdownCheckRate = 5 * time.Millisecond
+)
+
+//shutdown report
+type ShutdownReport struct {
+	Processed int64 //data processed during shutdown
+	Abandoned int64 //data left when deadline reached
+	TimedOut  bool
+}
+
+//graceful shutdown interface
+//implemented by Queue, List, Ticker, DelayQueue and Worker
+type Shutdowner interface {
+	Shutdown(ctx context.Context) *ShutdownReport
+}
+
+//face info
+type ShutdownGroup struct {
+	members map[string]Shutdowner //name -> member
+	sync.RWMutex
+}
+
+//construct
+func NewShutdownGroup() *ShutdownGroup {
+	this := &ShutdownGroup{
+		members: map[string]Shutdowner{},
+	}
+	return this
+}
+
+//add member
+func (f *ShutdownGroup) Add(name string, member Shutdowner) error {
+	//check
+	if name == "" || member == nil {
+		return errors.New("invalid parameter")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.members[name] = member
+	return nil
+}
+
+//shutdown all members concurrently
+//return name -> report
+func (f *ShutdownGroup) Shutdown(ctx context.Context) map[string]*ShutdownReport {
+	var (
+		wg sync.WaitGroup
+		locker sync.Mutex
+	)
+	f.RLock()
+	members := make(map[string]Shutdowner, len(f.members))
+	for k, v := range f.members {
+		members[k] = v
+	}
+	f.RUnlock()
+
+	//shutdown one by one concurrently
+	result := make(map[string]*ShutdownReport, len(members))
+	for name, member := range members {
+		wg.Add(1)
+		go func(name string, member Shutdowner) {
+			defer wg.Done()
+			report := member.Shutdown(ctx)
+			locker.Lock()
+			result[name] = report
+			locker.Unlock()
+		}(name, member)
+	}
+	wg.Wait()
+	return result
+}
+
+//register into signal, all members drained when shutdown signal received
+//timeouts -> drain deadline, default one second less than signal wait seconds
+func (f *ShutdownGroup) RegisterSignal(
+	s *util.Signal,
+	timeouts ...time.Duration) error {
+	var (
+		timeout time.Duration
+	)
+	//check
+	if s == nil {
+		return errors.New("invalid parameter")
+	}
+	if timeouts != nil && len(timeouts) > 0 {
+		timeout = timeouts[0]
+	}
+	if timeout <= 0 {
+		//finish before process exit after wait seconds
+		wait := time.Duration(s.GetWaitSeconds()) * time.Second
+		timeout = wait - time.Second
+		if timeout <= 0 {
+			timeout = wait / 2
+		}
+	}
+
+	//cb for shutdown
+	cb := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		reports := f.Shutdown(ctx)
+		for name, report := range reports {
+			log.Printf("shutdown %v, processed:%v, abandoned:%v, timeout:%v\n",
+				name, report.Processed, report.Abandoned, report.TimedOut)
+		}
+	}
+	return s.RegisterShutDownFunc(cb)
+}
+
+//merge other report
+func (r *ShutdownReport) merge(other *ShutdownReport) {
+	if other == nil {
+		return
+	}
+	r.Processed += other.Processed
+	r.Abandoned += other.Abandoned
+	r.TimedOut = r.TimedOut || other.TimedOut
+}
+
+//wait until checker return true or ctx done
+func waitUntil(ctx context.Context, checker func() bool) error {
+	ticker := time.NewTicker(shutdownCheckRate)
+	defer ticker.Stop()
+	for !checker() {
+		select {
+		case <- ctx.Done():
+			return ctx.Err()
+		case <- ticker.C:
+		}
+	}
+	return nil
+}
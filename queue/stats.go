@@ -0,0 +1,138 @@
+package queue
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+/*
+ * runtime stats for queue workers
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//callback latency buckets, upper bounds in seconds
+var LatencyBuckets = []float64{
+	0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5,
+}
+
+//stats snapshot
+type (
+	Stats struct {
+		Enqueued  int64 //accepted data count
+		Processed int64 //callback succeed count
+		Failed    int64 //callback failed count
+		Dropped   int64 //dropped by policy, cancel or clear
+		Rejected  int64 //rejected when queue full or ctx done
+		Depth     int64 //current waiting data count
+		HighWater int64 //max waiting data count
+		Latency   *LatencyStats
+	}
+	LatencyStats struct {
+		Buckets []float64 //upper bounds in seconds
+		Counts  []int64   //count of each bucket, last one for overflow
+		Count   int64
+		Sum     float64   //total seconds
+	}
+	SonWorkerStats struct {
+		WorkerId int32
+		BindObjs int
+		Queue    *Stats
+		Ticker   *Stats
+	}
+	WorkerStats struct {
+		Workers    int32
+		SonWorkers []*SonWorkerStats //sorted by worker id
+	}
+)
+
+//inter stat counter
+type statCounter struct {
+	enqueued      int64
+	processed     int64
+	failed        int64
+	dropped       int64
+	rejected      int64
+	highWater     int64
+	latencyCount  int64
+	latencySum    int64 //nano seconds
+	latencyCounts []int64
+}
+
+//construct
+func newStatCounter() *statCounter {
+	this := &statCounter{
+		latencyCounts: make([]int64, len(LatencyBuckets)+1),
+	}
+	return this
+}
+
+//get stats snapshot
+func (f *statCounter) snapshot(depth int64) *Stats {
+	latency := &LatencyStats{
+		Buckets: LatencyBuckets,
+		Counts: make([]int64, len(f.latencyCounts)),
+		Count: atomic.LoadInt64(&f.latencyCount),
+		Sum: time.Duration(atomic.LoadInt64(&f.latencySum)).Seconds(),
+	}
+	for i := range f.latencyCounts {
+		latency.Counts[i] = atomic.LoadInt64(&f.latencyCounts[i])
+	}
+	return &Stats{
+		Enqueued: atomic.LoadInt64(&f.enqueued),
+		Processed: atomic.LoadInt64(&f.processed),
+		Failed: atomic.LoadInt64(&f.failed),
+		Dropped: atomic.LoadInt64(&f.dropped),
+		Rejected: atomic.LoadInt64(&f.rejected),
+		Depth: depth,
+		HighWater: atomic.LoadInt64(&f.highWater),
+		Latency: latency,
+	}
+}
+
+//add enqueued and sync high water
+func (f *statCounter) addEnqueued(depth int64) {
+	atomic.AddInt64(&f.enqueued, 1)
+	for {
+		highWater := atomic.LoadInt64(&f.highWater)
+		if depth <= highWater ||
+			atomic.CompareAndSwapInt64(&f.highWater, highWater, depth) {
+			return
+		}
+	}
+}
+
+//add callback result and latency
+func (f *statCounter) addResult(err error, duration time.Duration) {
+	f.addBatchResult(err, 1, duration)
+}
+
+//add batch callback result and latency
+func (f *statCounter) addBatchResult(err error, num int, duration time.Duration) {
+	if err != nil {
+		atomic.AddInt64(&f.failed, int64(num))
+	}else{
+		atomic.AddInt64(&f.processed, int64(num))
+	}
+
+	//sync latency bucket
+	seconds := duration.Seconds()
+	idx := len(LatencyBuckets)
+	for i, bound := range LatencyBuckets {
+		if seconds <= bound {
+			idx = i
+			break
+		}
+	}
+	atomic.AddInt64(&f.latencyCounts[idx], 1)
+	atomic.AddInt64(&f.latencyCount, 1)
+	atomic.AddInt64(&f.latencySum, int64(duration))
+}
+
+func (f *statCounter) addDropped(num int64) {
+	atomic.AddInt64(&f.dropped, num)
+}
+
+func (f *statCounter) addRejected() {
+	atomic.AddInt64(&f.rejected, 1)
+}
@@ -0,0 +1,141 @@
+package queue
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+ * callback supervisor
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - recover panic per item, report by hook
+ * - send failed item to dead letter sink
+ * - restart run loop with backoff
+ */
+
+//supervisor config
+type SupervisorConf struct {
+	CBForPanic      func(name string, data interface{}, err interface{}, stack []byte)
+	CBForDeadLetter func(name string, data interface{}, err interface{})
+	MinBackoff      time.Duration //first restart backoff
+	MaxBackoff      time.Duration //max restart backoff
+	NoRestart       bool          //not restart loop after panic
+}
+
+//face info
+type supervisor struct {
+	name     string
+	conf     *SupervisorConf
+	alive    int32
+	restarts int32 //continuous restart times
+	sync.RWMutex
+}
+
+//construct
+func newSupervisor(name string) *supervisor {
+	this := &supervisor{
+		name: name,
+		conf: &SupervisorConf{},
+	}
+	return this
+}
+
+//set config
+func (f *supervisor) setConf(conf *SupervisorConf) {
+	if conf == nil {
+		return
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.conf = conf
+}
+
+//get config
+func (f *supervisor) getConf() *SupervisorConf {
+	f.RLock()
+	defer f.RUnlock()
+	return f.conf
+}
+
+//mark run loop alive or not
+func (f *supervisor) markAlive(alive bool) {
+	if alive {
+		atomic.StoreInt32(&f.alive, 1)
+	}else{
+		atomic.StoreInt32(&f.alive, 0)
+	}
+}
+
+//check run loop alive or not
+func (f *supervisor) healthy() bool {
+	return atomic.LoadInt32(&f.alive) > 0
+}
+
+//call cb with panic recover
+func (f *supervisor) safeCall(
+	data interface{},
+	cb func() error) (err error) {
+	var (
+		m any = nil
+	)
+	defer func() {
+		if subErr := recover(); subErr != m {
+			err = fmt.Errorf("%w, err:%v", ErrCallbackPanic, subErr)
+			f.report(data, subErr, debug.Stack())
+		}
+	}()
+	err = cb()
+	atomic.StoreInt32(&f.restarts, 0)
+	return err
+}
+
+//report panic and send data to dead letter sink
+func (f *supervisor) report(
+	data interface{},
+	err interface{},
+	stack []byte) {
+	conf := f.getConf()
+	if conf.CBForPanic != nil {
+		conf.CBForPanic(f.name, data, err, stack)
+	}else{
+		log.Printf("%v callback panic, err:%v, trace:%v\n", f.name, err, string(stack))
+	}
+	if data != nil && conf.CBForDeadLetter != nil {
+		conf.CBForDeadLetter(f.name, data, err)
+	}
+}
+
+//restart run loop with backoff
+//return false if restart disabled
+func (f *supervisor) restart(run func()) bool {
+	conf := f.getConf()
+	if conf.NoRestart || run == nil {
+		return false
+	}
+	minBackoff := conf.MinBackoff
+	if minBackoff <= 0 {
+		minBackoff = DefaultMinRestartBackoff
+	}
+	maxBackoff := conf.MaxBackoff
+	if maxBackoff < minBackoff {
+		maxBackoff = DefaultMaxRestartBackoff
+	}
+
+	//calculate backoff
+	times := atomic.AddInt32(&f.restarts, 1)
+	backoff := minBackoff
+	for i := int32(1); i < times && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	log.Printf("%v run loop restart after %v, times:%v\n", f.name, backoff, times)
+	time.AfterFunc(backoff, run)
+	return true
+}
@@ -1,9 +1,11 @@
 package queue
 
 import (
+	"context"
 	"errors"
 	"github.com/andyzhou/tinylib/util"
 	"log"
+	"sync"
 	"time"
 )
 
@@ -22,6 +24,10 @@ type Ticker struct {
 	closeChan    chan bool
 	cbForChecker func(inputs ...interface{}) error
 	cbForQuit    func()
+	stat         *statCounter
+	sv           *supervisor
+	doneChan     chan struct{} //closed when main process quit
+	quitOnce     sync.Once
 	util.Util
 }
 
@@ -43,31 +49,37 @@ func NewTicker(tickDurations ...float64) *Ticker {
 		tickDuration: durationTicker,
 		tickChan: make(chan struct{}, 1),
 		closeChan: make(chan bool, 1),
+		stat: newStatCounter(),
+		sv: newSupervisor("ticker"),
+		doneChan: make(chan struct{}),
 	}
 
 	//spawn main process
+	this.sv.markAlive(true)
 	go this.runMainProcess()
 	return this
 }
 
 //quit
 func (f *Ticker) Quit() {
-	//close closeChan first
-	if f.closeChan != nil {
-		select {
-		case f.closeChan <- true:
-		default: //ignore block
-		}
-		close(f.closeChan)
-	}
-
-	//wait awhile to let goroutine quit
-	time.Sleep(10 * time.Millisecond)
+	f.quitOnce.Do(f.quit)
+}
 
-	//close tickChan
-	if f.tickChan != nil {
-		close(f.tickChan)
+//shutdown gracefully
+//stop ticking and wait for the last checker done until ctx done
+func (f *Ticker) Shutdown(ctx context.Context) *ShutdownReport {
+	before := f.GetStats()
+	report := &ShutdownReport{}
+	go f.Quit()
+	select {
+	case <- f.doneChan:
+	case <- ctx.Done():
+		report.Abandoned = 1
+		report.TimedOut = true
 	}
+	after := f.GetStats()
+	report.Processed = after.Processed + after.Failed - before.Processed - before.Failed
+	return report
 }
 
 //check ticker is closed
@@ -76,6 +88,21 @@ func (f *Ticker) QueueClosed() bool {
 	return closed
 }
 
+//check main process is alive or not
+func (f *Ticker) Healthy() bool {
+	return f.sv.healthy()
+}
+
+//set supervisor for checker panic and process restart
+func (f *Ticker) SetSupervisor(conf *SupervisorConf) {
+	f.sv.setConf(conf)
+}
+
+//get runtime stats snapshot
+func (f *Ticker) GetStats() *Stats {
+	return f.stat.snapshot(0)
+}
+
 //get current ticker duration
 func (f *Ticker) GetDuration() float64 {
 	return f.tickDuration.Seconds()
@@ -117,6 +144,39 @@ func (f *Ticker) SetCheckerCallback(cb func(inputs ...interface{}) error, inputs
 //private func
 ///////////////
 
+//quit main process
+func (f *Ticker) quit() {
+	//close closeChan first
+	if f.closeChan != nil {
+		select {
+		case f.closeChan <- true:
+		default: //ignore block
+		}
+		close(f.closeChan)
+	}
+
+	//wait awhile to let goroutine quit
+	time.Sleep(10 * time.Millisecond)
+
+	//close tickChan
+	if f.tickChan != nil {
+		isClosed, _ := f.IsChanClosed(f.tickChan)
+		if !isClosed {
+			close(f.tickChan)
+		}
+	}
+}
+
+//call checker with panic recover
+func (f *Ticker) callChecker() error {
+	begin := time.Now()
+	err := f.sv.safeCall(f.inputs, func() error {
+		return f.cbForChecker(f.inputs...)
+	})
+	f.stat.addResult(err, time.Since(begin))
+	return err
+}
+
 //run main process
 func (f *Ticker) runMainProcess() {
 	var (
@@ -125,14 +185,20 @@ func (f *Ticker) runMainProcess() {
 	)
 
 	defer func() {
+		f.sv.markAlive(false)
 		if err := recover(); err != m {
 			log.Printf("ticker.runMainProcess panic, err:%v\n", err)
+			if !isQuitting && f.sv.restart(f.runMainProcess) {
+				return
+			}
 		}
 
+		defer close(f.doneChan)
+
 		//only normal quit to execute
 		if isQuitting {
 			if f.cbForChecker != nil {
-				f.cbForChecker(f.inputs...)
+				f.callChecker()
 			}
 			if f.cbForQuit != nil {
 				f.cbForQuit()
@@ -157,8 +223,12 @@ func (f *Ticker) runMainProcess() {
 	}()
 
 	//start first ticker
+	f.sv.markAlive(true)
 	if f.tickChan != nil && !f.QueueClosed() {
-		f.tickChan <- struct{}{}
+		select {
+		case f.tickChan <- struct{}{}:
+		default: //ignore block
+		}
 	}
 
 	//loop
@@ -172,7 +242,7 @@ func (f *Ticker) runMainProcess() {
 				}
 				if f.cbForChecker != nil {
 					//call cb
-					f.cbForChecker(f.inputs...)
+					f.callChecker()
 				}
 				//send next tick
 				time.Sleep(f.tickDuration)
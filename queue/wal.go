@@ -0,0 +1,367 @@
+package queue
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"github.com/andyzhou/tinylib/util"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/*
+ * write ahead log for disk list
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - record frame: length(4) + crc32(4) + payload
+ * - payload: op(1) + id(8) + gob value(push and join only)
+ */
+
+//inter macro define
+const (
+	walOpPush = iota + 1
+	walOpJoin
+	walOpAck
+)
+const (
+	walSegmentSuffix = ".seg"
+	walFrameHeadSize = 8
+)
+
+//inter type
+type (
+	walItem struct {
+		id  uint64
+		val interface{}
+	}
+)
+
+//face info
+type listWal struct {
+	conf    *ListConf
+	file    *os.File
+	segs    []int64         //sorted segment no list
+	segSize int64           //active segment size
+	segLive map[int64]int   //segmentNo -> live item count
+	idSeg   map[uint64]int64 //itemId -> segmentNo
+	nextId  uint64
+	util.File
+}
+
+//construct, replay and compact old segments
+func newListWal(conf *ListConf) (*listWal, []walItem, error) {
+	//check
+	if conf == nil || conf.DataPath == "" {
+		return nil, nil, errors.New("invalid parameter")
+	}
+	if conf.SegmentSize <= 0 {
+		conf.SegmentSize = DefaultListSegmentSize
+	}
+
+	//self init
+	this := &listWal{
+		conf: conf,
+		segs: []int64{},
+		segLive: map[int64]int{},
+		idSeg: map[uint64]int64{},
+		nextId: 1,
+	}
+	err := this.CheckOrCreateDir(conf.DataPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	//replay old segments
+	oldSegs, items, err := this.replay()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	//write live items into new segment
+	newSegNo := int64(1)
+	if len(oldSegs) > 0 {
+		newSegNo = oldSegs[len(oldSegs)-1] + 1
+	}
+	err = this.openSegment(newSegNo)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, item := range items {
+		err = this.write(walOpPush, item.id, item.val)
+		if err != nil {
+			this.close()
+			return nil, nil, err
+		}
+		this.idSeg[item.id] = newSegNo
+		this.segLive[newSegNo]++
+	}
+	if err = this.file.Sync(); err != nil {
+		this.close()
+		return nil, nil, err
+	}
+
+	//remove old segments, oldest first
+	for _, segNo := range oldSegs {
+		os.Remove(this.segmentPath(segNo))
+	}
+	return this, items, nil
+}
+
+//close active segment
+func (f *listWal) close() {
+	if f.file != nil {
+		f.file.Sync()
+		f.file.Close()
+		f.file = nil
+	}
+}
+
+//gen next item id
+func (f *listWal) genId() uint64 {
+	id := f.nextId
+	f.nextId++
+	return id
+}
+
+//append push or join record
+func (f *listWal) add(op int, id uint64, val interface{}) error {
+	//check
+	if f.file == nil {
+		return errors.New("wal has closed")
+	}
+	err := f.write(op, id, val)
+	if err != nil {
+		return err
+	}
+
+	//sync live info
+	segNo := f.segs[len(f.segs)-1]
+	f.idSeg[id] = segNo
+	f.segLive[segNo]++
+	return f.checkRoll()
+}
+
+//append ack record
+func (f *listWal) ack(id uint64) error {
+	//check
+	if f.file == nil {
+		return errors.New("wal has closed")
+	}
+	segNo, ok := f.idSeg[id]
+	if !ok {
+		return nil
+	}
+	err := f.write(walOpAck, id, nil)
+	if err != nil {
+		return err
+	}
+
+	//sync live info
+	delete(f.idSeg, id)
+	f.segLive[segNo]--
+	f.compact()
+	return f.checkRoll()
+}
+
+//remove all segments and open new one
+func (f *listWal) reset() error {
+	f.close()
+	for _, segNo := range f.segs {
+		os.Remove(f.segmentPath(segNo))
+	}
+	newSegNo := int64(1)
+	if len(f.segs) > 0 {
+		newSegNo = f.segs[len(f.segs)-1] + 1
+	}
+	f.segs = []int64{}
+	f.segLive = map[int64]int{}
+	f.idSeg = map[uint64]int64{}
+	return f.openSegment(newSegNo)
+}
+
+///////////////
+//private func
+///////////////
+
+//remove fully consumed segments, oldest first
+func (f *listWal) compact() {
+	for len(f.segs) > 1 {
+		segNo := f.segs[0]
+		if f.segLive[segNo] > 0 {
+			break
+		}
+		os.Remove(f.segmentPath(segNo))
+		delete(f.segLive, segNo)
+		f.segs = f.segs[1:]
+	}
+}
+
+//roll to new segment if active one is full
+func (f *listWal) checkRoll() error {
+	if f.segSize < f.conf.SegmentSize {
+		return nil
+	}
+	f.close()
+	err := f.openSegment(f.segs[len(f.segs)-1] + 1)
+	if err != nil {
+		return err
+	}
+	f.compact()
+	return nil
+}
+
+//open new active segment
+func (f *listWal) openSegment(segNo int64) error {
+	file, err := os.OpenFile(f.segmentPath(segNo),
+		os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, util.FilePerm)
+	if err != nil {
+		return err
+	}
+	f.file = file
+	f.segSize = 0
+	f.segs = append(f.segs, segNo)
+	return nil
+}
+
+//write one record frame
+func (f *listWal) write(op int, id uint64, val interface{}) error {
+	//encode payload
+	buff := bytes.NewBuffer(nil)
+	buff.WriteByte(byte(op))
+	binary.Write(buff, binary.BigEndian, id)
+	if op != walOpAck {
+		err := gob.NewEncoder(buff).Encode(&val)
+		if err != nil {
+			return err
+		}
+	}
+	payload := buff.Bytes()
+
+	//setup frame
+	frame := make([]byte, walFrameHeadSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(payload))
+	copy(frame[walFrameHeadSize:], payload)
+
+	//write into file
+	_, err := f.file.Write(frame)
+	if err != nil {
+		return err
+	}
+	f.segSize += int64(len(frame))
+	if f.conf.SyncWrite {
+		return f.file.Sync()
+	}
+	return nil
+}
+
+//replay all segments, return old segments and live items
+func (f *listWal) replay() ([]int64, []walItem, error) {
+	//get sorted segments
+	fileNames, err := f.GetDirFiles(f.conf.DataPath, walSegmentSuffix)
+	if err != nil {
+		return nil, nil, err
+	}
+	segs := make([]int64, 0)
+	for _, fileName := range fileNames {
+		segNo, subErr := strconv.ParseInt(strings.TrimSuffix(fileName, walSegmentSuffix), 10, 64)
+		if subErr != nil || segNo <= 0 {
+			continue
+		}
+		segs = append(segs, segNo)
+	}
+
+	//replay records one by one
+	l := list.New()
+	eleMap := map[uint64]*list.Element{}
+	for _, segNo := range segs {
+		err = f.replaySegment(segNo, l, eleMap)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	//gen live items
+	items := make([]walItem, 0, l.Len())
+	for e := l.Front(); e != nil; e = e.Next() {
+		items = append(items, e.Value.(walItem))
+	}
+	return segs, items, nil
+}
+
+//replay one segment
+//broken tail frame means crash when writing, just stop
+func (f *listWal) replaySegment(
+	segNo int64,
+	l *list.List,
+	eleMap map[uint64]*list.Element) error {
+	byteData, err := f.ReadBinFile(f.segmentPath(segNo))
+	if err != nil {
+		return err
+	}
+	reader := bytes.NewReader(byteData)
+	head := make([]byte, walFrameHeadSize)
+	for {
+		//read frame head
+		if _, err = io.ReadFull(reader, head); err != nil {
+			break
+		}
+		size := binary.BigEndian.Uint32(head[0:4])
+		if size < 9 || int64(size) > int64(reader.Len()) {
+			break
+		}
+		payload := make([]byte, size)
+		if _, err = io.ReadFull(reader, payload); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(head[4:8]) {
+			break
+		}
+
+		//decode payload
+		op := int(payload[0])
+		id := binary.BigEndian.Uint64(payload[1:9])
+		if id >= f.nextId {
+			f.nextId = id + 1
+		}
+		switch op {
+		case walOpPush, walOpJoin:
+			{
+				var val interface{}
+				if _, ok := eleMap[id]; ok {
+					continue
+				}
+				err = gob.NewDecoder(bytes.NewReader(payload[9:])).Decode(&val)
+				if err != nil {
+					return fmt.Errorf("decode segment %v failed, err:%v", segNo, err)
+				}
+				item := walItem{id: id, val: val}
+				if op == walOpPush {
+					eleMap[id] = l.PushBack(item)
+				}else{
+					eleMap[id] = l.PushFront(item)
+				}
+			}
+		case walOpAck:
+			{
+				if e, ok := eleMap[id]; ok {
+					l.Remove(e)
+					delete(eleMap, id)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+//get segment file path
+func (f *listWal) segmentPath(segNo int64) string {
+	return filepath.Join(f.conf.DataPath, fmt.Sprintf("%020d%v", segNo, walSegmentSuffix))
+}
@@ -1,11 +1,14 @@
 package queue
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"github.com/andyzhou/tinylib/algorithm"
 	"github.com/andyzhou/tinylib/util"
 	"math/rand"
 	"runtime"
+	"sort"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -20,30 +23,51 @@ import (
 
 //inter type
 //gen and bind obj ticker, only one works
+//K -> bind obj id type, T -> bind obj type
 type (
-	SonWorker struct {
+	TypedSonWorker[K comparable, T any] struct {
 		workerId int32
-		bindObjs map[int64]interface{}
+		bindObjs map[K]T
 		queue    *Queue
 		ticker   *Ticker
+		keyCheck func(K) bool //check obj id is valid or not
+		wrapper  interface{}  //cached non-generic wrapper
 		//cb for ticker
 		cbForGenTicker     func(int32) error
-		cbForBindObjTicker func(int32, ...interface{}) error
+		cbForBindObjTicker func(int32, map[K]T) error
 		sync.RWMutex
 	}
 )
 
-//face info
+//non-generic son worker, bind obj id is int64
+type SonWorker struct {
+	*TypedSonWorker[int64, interface{}]
+}
+
+//non-generic worker, bind obj id is int64
 type Worker struct {
+	*TypedWorker[int64, interface{}]
+}
+
+//face info
+//K -> bind obj id type, T -> bind obj type
+type TypedWorker[K comparable, T any] struct {
 	//basic
-	workerMap   map[int32]*SonWorker //workerId -> *SonWorker
-	workerIdMap map[int64]int32      //dataId -> workerId, for bind obj
+	workerMap   map[int32]*TypedSonWorker[K, T] //workerId -> *TypedSonWorker
+	workerIdMap map[K]int32                     //dataId -> workerId, for bind obj
+	workerRing  *algorithm.ConsistentHash       //hashed by data id
+	workerIds   []int32                         //sorted running worker ids
+	workerSeq   int32                           //worker id generator
 	workers     int32
+	keyCheck    func(K) bool                    //check obj id is valid or not
 
 	//cb func
 	cbForQueueOpt         func(interface{}) (interface{}, error)
 	cbForGenTickerOpt     func(int32) error
-	cbForBindObjTickerOpt func(int32, ...interface{}) error
+	cbForBindObjTickerOpt func(int32, map[K]T) error
+
+	//supervisor for son workers
+	svConf *SupervisorConf
 
 	sync.RWMutex
 	util.Util
@@ -51,28 +75,41 @@ type Worker struct {
 
 //construct
 func NewWorker() *Worker {
-	this := &Worker{
-		workerMap: map[int32]*SonWorker{},
-		workerIdMap: map[int64]int32{},
+	tw := NewTypedWorker[int64, interface{}]()
+	tw.keyCheck = isPositiveId
+	return &Worker{TypedWorker: tw}
+}
+
+//construct typed worker
+//zero value of K is treated as invalid obj id
+func NewTypedWorker[K comparable, T any]() *TypedWorker[K, T] {
+	this := &TypedWorker[K, T]{
+		workerMap: map[int32]*TypedSonWorker[K, T]{},
+		workerIdMap: map[K]int32{},
+		workerRing: algorithm.NewCustomConsistentHash(algorithm.DefaultVirtualNodeCount, nil),
+		workerIds: []int32{},
+		keyCheck: isNonZeroKey[K],
 	}
 	return this
 }
 
 //quit
-func (f *Worker) Quit() {
+func (f *TypedWorker[K, T]) Quit() {
 	f.Lock()
 	defer f.Unlock()
 	for k, v := range f.workerMap {
 		v.Quit()
+		f.workerRing.Remove(k)
 		delete(f.workerMap, k)
 	}
+	f.workerIds = []int32{}
 	atomic.StoreInt32(&f.workers, 0)
 	runtime.GC()
 }
 
 //set cb for queue opt, STEP-1-1
 //if setup, will open queue
-func (f *Worker) SetCBForQueueOpt(cb func(interface{}) (interface{}, error)) {
+func (f *TypedWorker[K, T]) SetCBForQueueOpt(cb func(interface{}) (interface{}, error)) {
 	//check
 	if cb == nil {
 		return
@@ -85,14 +122,81 @@ func (f *Worker) SetCBForQueueOpt(cb func(interface{}) (interface{}, error)) {
 	for _, v := range f.workerMap {
 		if v.queue == nil {
 			v.queue = NewQueue()
+			if f.svConf != nil {
+				v.queue.SetSupervisor(f.svConf)
+			}
 		}
 		v.queue.SetCallback(cb)
 	}
 }
 
+//shutdown gracefully
+//stop intake, drain all son workers concurrently until ctx done
+func (f *TypedWorker[K, T]) Shutdown(ctx context.Context) *ShutdownReport {
+	var (
+		wg sync.WaitGroup
+		locker sync.Mutex
+	)
+	//stop intake
+	f.Lock()
+	sonWorkers := make([]*TypedSonWorker[K, T], 0, len(f.workerMap))
+	for k, v := range f.workerMap {
+		sonWorkers = append(sonWorkers, v)
+		f.workerRing.Remove(k)
+		delete(f.workerMap, k)
+	}
+	f.workerIds = []int32{}
+	atomic.StoreInt32(&f.workers, 0)
+	f.Unlock()
+
+	//drain son workers
+	report := &ShutdownReport{}
+	for _, v := range sonWorkers {
+		wg.Add(1)
+		go func(sw *TypedSonWorker[K, T]) {
+			defer wg.Done()
+			subReport := sw.Shutdown(ctx)
+			locker.Lock()
+			report.merge(subReport)
+			locker.Unlock()
+		}(v)
+	}
+	wg.Wait()
+	runtime.GC()
+	return report
+}
+
+//set supervisor for son workers
+func (f *TypedWorker[K, T]) SetSupervisor(conf *SupervisorConf) {
+	//check
+	if conf == nil {
+		return
+	}
+
+	//sync into running son workers
+	f.Lock()
+	defer f.Unlock()
+	f.svConf = conf
+	for _, v := range f.workerMap {
+		v.SetSupervisor(conf)
+	}
+}
+
+//check all son workers are alive or not
+func (f *TypedWorker[K, T]) Healthy() bool {
+	f.Lock()
+	defer f.Unlock()
+	for _, v := range f.workerMap {
+		if !v.Healthy() {
+			return false
+		}
+	}
+	return true
+}
+
 //set cb for gen ticker opt, STEP-1-2
 //if setup, will open ticker
-func (f *Worker) SetCBForGenTickerOpt(cb func(int32) error) {
+func (f *TypedWorker[K, T]) SetCBForGenTickerOpt(cb func(int32) error) {
 	//check
 	if cb == nil {
 		return
@@ -109,7 +213,7 @@ func (f *Worker) SetCBForGenTickerOpt(cb func(int32) error) {
 
 //set cb for bind obj ticker opt, STEP-1-3
 //if setup, used for bind obj ticker opt
-func (f *Worker) SetCBForBindObjTickerOpt(cb func(int32,...interface{}) error) {
+func (f *TypedWorker[K, T]) SetCBForBindObjTickerOpt(cb func(int32, map[K]T) error) {
 	//check
 	if cb == nil {
 		return
@@ -126,7 +230,14 @@ func (f *Worker) SetCBForBindObjTickerOpt(cb func(int32,...interface{}) error) {
 
 //create workers, STEP-2
 //if tickerRates > 0, will create son worker ticker
-func (f *Worker) CreateWorkers(
+func (f *TypedWorker[K, T]) CreateWorkers(
+	num int, tickerRates ...float64) error {
+	return f.AddWorkers(num, tickerRates...)
+}
+
+//add workers in runtime
+//bind objs will be migrated to new owner workers
+func (f *TypedWorker[K, T]) AddWorkers(
 	num int, tickerRates ...float64) error {
 	//check
 	if num <= 0 {
@@ -138,10 +249,11 @@ func (f *Worker) CreateWorkers(
 	defer f.Unlock()
 	for i := 0; i < num; i++ {
 		//gen new worker id
-		newWorkerId := atomic.AddInt32(&f.workers, 1)
+		newWorkerId := atomic.AddInt32(&f.workerSeq, 1)
 
 		//init son worker
-		sw := NewSonWorker(newWorkerId, tickerRates...)
+		sw := NewTypedSonWorker[K, T](newWorkerId, tickerRates...)
+		sw.keyCheck = f.keyCheck
 
 		//set queue cb
 		if f.cbForQueueOpt != nil {
@@ -160,19 +272,76 @@ func (f *Worker) CreateWorkers(
 			}
 		}
 
-		//sync into run map
+		//set supervisor
+		if f.svConf != nil {
+			sw.SetSupervisor(f.svConf)
+		}
+
+		//sync into run map and ring
 		f.workerMap[newWorkerId] = sw
+		f.workerRing.Add(newWorkerId)
+		atomic.AddInt32(&f.workers, 1)
 	}
+
+	//migrate bind objs
+	f.syncWorkerIds()
+	f.migrateBindObjs()
+	return nil
+}
+
+//remove workers in runtime
+//bind objs will be migrated to left workers
+func (f *TypedWorker[K, T]) RemoveWorkers(workerIds ...int32) error {
+	//check
+	if workerIds == nil || len(workerIds) <= 0 {
+		return errors.New("invalid parameter")
+	}
+
+	//remove son workers with locker
+	f.Lock()
+	defer f.Unlock()
+	removedWorkers := make([]*TypedSonWorker[K, T], 0)
+	for _, workerId := range workerIds {
+		v, ok := f.workerMap[workerId]
+		if !ok || v == nil {
+			continue
+		}
+		removedWorkers = append(removedWorkers, v)
+	}
+	if len(removedWorkers) <= 0 {
+		return errors.New("no such workers")
+	}
+	if len(removedWorkers) >= len(f.workerMap) {
+		return errors.New("can't remove all workers")
+	}
+	for _, v := range removedWorkers {
+		f.workerRing.Remove(v.workerId)
+		delete(f.workerMap, v.workerId)
+		atomic.AddInt32(&f.workers, -1)
+	}
+
+	//migrate bind objs of removed workers
+	f.syncWorkerIds()
+	for _, v := range removedWorkers {
+		for objId, obj := range v.GetAllBindObjs() {
+			targetWorkerId := f.hashWorkerId(objId)
+			if target, ok := f.workerMap[targetWorkerId]; ok {
+				target.UpdateBindObj(objId, obj)
+			}
+		}
+		v.Quit()
+	}
+	f.migrateBindObjs()
 	return nil
 }
 
 //send data to one worker, STEP-3
 //objIds used for hash calculate value
-func (f *Worker) SendData(
+func (f *TypedWorker[K, T]) SendData(
 		data interface{},
-		objIds []int64,
+		objIds []K,
 		needResponses ...bool,
-	) (map[int64]interface{}, error) {
+	) (map[K]interface{}, error) {
 	//check
 	if data == nil || objIds == nil {
 		return nil, errors.New("invalid parameter")
@@ -182,7 +351,7 @@ func (f *Worker) SendData(
 	}
 
 	//loop process
-	result := map[int64]interface{}{}
+	result := map[K]interface{}{}
 	for _, objId := range objIds {
 		//get son worker
 		sonWorker, err := f.GetTargetWorker(objId)
@@ -200,7 +369,7 @@ func (f *Worker) SendData(
 }
 
 //cast data to all workers
-func (f *Worker) CastData(data interface{}) error {
+func (f *TypedWorker[K, T]) CastData(data interface{}) error {
 	//check
 	if data == nil {
 		return errors.New("invalid parameter")
@@ -210,6 +379,8 @@ func (f *Worker) CastData(data interface{}) error {
 	}
 
 	//send data to all workers
+	f.Lock()
+	defer f.Unlock()
 	for _, v := range f.workerMap {
 		v.queue.SendData(data)
 	}
@@ -217,12 +388,39 @@ func (f *Worker) CastData(data interface{}) error {
 }
 
 //get workers
-func (f *Worker) GetWorkers() int32 {
-	return f.workers
+func (f *TypedWorker[K, T]) GetWorkers() int32 {
+	return atomic.LoadInt32(&f.workers)
+}
+
+//get running worker ids
+func (f *TypedWorker[K, T]) GetWorkerIds() []int32 {
+	f.Lock()
+	defer f.Unlock()
+	result := make([]int32, len(f.workerIds))
+	copy(result, f.workerIds)
+	return result
+}
+
+//get runtime stats snapshot
+func (f *TypedWorker[K, T]) GetStats() *WorkerStats {
+	f.Lock()
+	defer f.Unlock()
+	result := &WorkerStats{
+		Workers: atomic.LoadInt32(&f.workers),
+		SonWorkers: make([]*SonWorkerStats, 0, len(f.workerIds)),
+	}
+	for _, workerId := range f.workerIds {
+		sw, ok := f.workerMap[workerId]
+		if !ok || sw == nil {
+			continue
+		}
+		result.SonWorkers = append(result.SonWorkers, sw.GetStats())
+	}
+	return result
 }
 
 //get all objs
-func (f *Worker) GetAllBindObj(workerId int32) (map[int64]interface{}, error) {
+func (f *TypedWorker[K, T]) GetAllBindObj(workerId int32) (map[K]T, error) {
 	//get target worker by id
 	sonWorker, err := f.GetWorker(workerId)
 	if err != nil || sonWorker == nil {
@@ -234,16 +432,19 @@ func (f *Worker) GetAllBindObj(workerId int32) (map[int64]interface{}, error) {
 }
 
 //get one obj by id
-func (f *Worker) GetBindObj(objId int64) (interface{}, error) {
+func (f *TypedWorker[K, T]) GetBindObj(objId K) (T, error) {
+	var (
+		zero T
+	)
 	//check
-	if objId <= 0 {
-		return nil, errors.New("invalid parameter")
+	if !f.keyCheck(objId) {
+		return zero, errors.New("invalid parameter")
 	}
 
 	//get target worker
 	sonWorker, err := f.GetTargetWorker(objId)
 	if err != nil || sonWorker == nil {
-		return nil, err
+		return zero, err
 	}
 
 	//get from son worker
@@ -252,9 +453,9 @@ func (f *Worker) GetBindObj(objId int64) (interface{}, error) {
 }
 
 //remove bind obj
-func (f *Worker) RemoveBindObj(objId int64) error {
+func (f *TypedWorker[K, T]) RemoveBindObj(objId K) error {
 	//check
-	if objId <= 0 {
+	if !f.keyCheck(objId) {
 		return errors.New("invalid parameter")
 	}
 
@@ -278,9 +479,9 @@ func (f *Worker) RemoveBindObj(objId int64) error {
 }
 
 //update bind obj
-func (f *Worker) UpdateBindObj(objId int64, obj interface{}) error {
+func (f *TypedWorker[K, T]) UpdateBindObj(objId K, obj T) error {
 	//check
-	if objId <= 0 || obj == nil {
+	if !f.keyCheck(objId) || any(obj) == nil {
 		return errors.New("invalid parameter")
 	}
 
@@ -296,37 +497,31 @@ func (f *Worker) UpdateBindObj(objId int64, obj interface{}) error {
 }
 
 //get son worker
-//extParas -> dataId(int64), needBind(bool)
-func (f *Worker) GetTargetWorker(extParas ...interface{}) (*SonWorker, error) {
+//invalid obj id means rand son worker
+//the same data id always hashed to the same son worker
+func (f *TypedWorker[K, T]) GetTargetWorker(
+	objId K,
+	needBinds ...bool) (*TypedSonWorker[K, T], error) {
 	var (
-		objId int64
 		targetWorkerId int32
 		needBind bool
 	)
 	//check
-	if extParas != nil {
-		extParaLen := len(extParas)
-		switch extParaLen {
-		case 1:
-			{
-				objId = f.Str2Int(fmt.Sprintf("%v", extParas[0]))
-			}
-		case 2:
-			{
-				objId = f.Str2Int(fmt.Sprintf("%v", extParas[0]))
-				needBind, _ = strconv.ParseBool(fmt.Sprintf("%v", extParas[0]))
-			}
-		}
+	if needBinds != nil && len(needBinds) > 0 {
+		needBind = needBinds[0]
 	}
 
 	//gen hashed worker id
 	f.Lock()
 	defer f.Unlock()
-	if objId <= 0 {
+	if len(f.workerIds) <= 0 {
+		return nil, errors.New("no any workers")
+	}
+	if !f.keyCheck(objId) {
 		//hashed by rand
 		now := time.Now().UnixNano()
 		rand.Seed(now)
-		targetWorkerId = int32(rand.Int63n(now) % int64(f.workers)) + 1
+		targetWorkerId = f.workerIds[rand.Intn(len(f.workerIds))]
 	}else{
 		//hashed by data id
 		if needBind {
@@ -334,7 +529,7 @@ func (f *Worker) GetTargetWorker(extParas ...interface{}) (*SonWorker, error) {
 			v, ok := f.workerIdMap[objId]
 			if !ok || v <= 0 {
 				//hashed by data id
-				targetWorkerId = int32(rand.Int63n(objId) % int64(f.workers)) + 1
+				targetWorkerId = f.hashWorkerId(objId)
 				//sync into cache map
 				f.workerIdMap[objId] = targetWorkerId
 			}else{
@@ -342,7 +537,7 @@ func (f *Worker) GetTargetWorker(extParas ...interface{}) (*SonWorker, error) {
 			}
 		}else{
 			//hashed by data id
-			targetWorkerId = int32(rand.Int63n(objId) % int64(f.workers)) + 1
+			targetWorkerId = f.hashWorkerId(objId)
 		}
 	}
 
@@ -354,7 +549,7 @@ func (f *Worker) GetTargetWorker(extParas ...interface{}) (*SonWorker, error) {
 	return nil, errors.New("can't get son worker")
 }
 
-func (f *Worker) GetWorker(workerId int32) (*SonWorker, error) {
+func (f *TypedWorker[K, T]) GetWorker(workerId int32) (*TypedSonWorker[K, T], error) {
 	//check
 	if workerId <= 0 {
 		return nil, errors.New("invalid parameter")
@@ -368,12 +563,60 @@ func (f *Worker) GetWorker(workerId int32) (*SonWorker, error) {
 	return nil, errors.New("no such worker")
 }
 
+//get hashed worker id by data id, should be called with locker
+func (f *TypedWorker[K, T]) hashWorkerId(objId K) int32 {
+	v, ok := f.workerRing.Get(objId)
+	if !ok || v == nil {
+		return 0
+	}
+	workerId, _ := v.(int32)
+	return workerId
+}
+
+//sync sorted running worker ids, should be called with locker
+func (f *TypedWorker[K, T]) syncWorkerIds() {
+	workerIds := make([]int32, 0, len(f.workerMap))
+	for k := range f.workerMap {
+		workerIds = append(workerIds, k)
+	}
+	sort.Slice(workerIds, func(i, j int) bool {
+		return workerIds[i] < workerIds[j]
+	})
+	f.workerIds = workerIds
+}
+
+//migrate bind objs to hashed owner workers, should be called with locker
+//only objs whose owner changed will be moved
+func (f *TypedWorker[K, T]) migrateBindObjs() {
+	for workerId, sw := range f.workerMap {
+		for objId, obj := range sw.GetAllBindObjs() {
+			targetWorkerId := f.hashWorkerId(objId)
+			if targetWorkerId == workerId {
+				continue
+			}
+			target, ok := f.workerMap[targetWorkerId]
+			if !ok || target == nil {
+				continue
+			}
+			target.UpdateBindObj(objId, obj)
+			sw.RemoveBindObj(objId)
+		}
+	}
+
+	//sync bind cache map
+	for objId := range f.workerIdMap {
+		f.workerIdMap[objId] = f.hashWorkerId(objId)
+	}
+}
+
 ////////////////////
 //api for son worker
 ////////////////////
 
-//construct
-func NewSonWorker(id int32, tickerRates ...float64) *SonWorker {
+//construct typed son worker
+func NewTypedSonWorker[K comparable, T any](
+	id int32,
+	tickerRates ...float64) *TypedSonWorker[K, T] {
 	var (
 		tickerRate float64
 	)
@@ -382,9 +625,10 @@ func NewSonWorker(id int32, tickerRates ...float64) *SonWorker {
 	}
 
 	//self init
-	this := &SonWorker{
+	this := &TypedSonWorker[K, T]{
 		workerId: id,
-		bindObjs: map[int64]interface{}{},
+		bindObjs: map[K]T{},
+		keyCheck: isNonZeroKey[K],
 	}
 
 	//check and start default ticker
@@ -396,7 +640,7 @@ func NewSonWorker(id int32, tickerRates ...float64) *SonWorker {
 }
 
 //quit
-func (f *SonWorker) Quit() {
+func (f *TypedSonWorker[K, T]) Quit() {
 	if f.queue != nil {
 		f.queue.Quit()
 	}
@@ -407,8 +651,64 @@ func (f *SonWorker) Quit() {
 	runtime.GC()
 }
 
+//get worker id
+func (f *TypedSonWorker[K, T]) GetWorkerId() int32 {
+	return f.workerId
+}
+
+//shutdown gracefully
+func (f *TypedSonWorker[K, T]) Shutdown(ctx context.Context) *ShutdownReport {
+	report := &ShutdownReport{}
+	if f.queue != nil {
+		report.merge(f.queue.Shutdown(ctx))
+	}
+	if f.ticker != nil {
+		report.merge(f.ticker.Shutdown(ctx))
+	}
+	return report
+}
+
+//set supervisor for queue and ticker
+func (f *TypedSonWorker[K, T]) SetSupervisor(conf *SupervisorConf) {
+	if f.queue != nil {
+		f.queue.SetSupervisor(conf)
+	}
+	if f.ticker != nil {
+		f.ticker.SetSupervisor(conf)
+	}
+}
+
+//check queue and ticker are alive or not
+func (f *TypedSonWorker[K, T]) Healthy() bool {
+	if f.queue != nil && !f.queue.Healthy() {
+		return false
+	}
+	if f.ticker != nil && !f.ticker.Healthy() {
+		return false
+	}
+	return true
+}
+
+//get runtime stats snapshot
+func (f *TypedSonWorker[K, T]) GetStats() *SonWorkerStats {
+	f.Lock()
+	bindObjs := len(f.bindObjs)
+	f.Unlock()
+	result := &SonWorkerStats{
+		WorkerId: f.workerId,
+		BindObjs: bindObjs,
+	}
+	if f.queue != nil {
+		result.Queue = f.queue.GetStats()
+	}
+	if f.ticker != nil {
+		result.Ticker = f.ticker.GetStats()
+	}
+	return result
+}
+
 //send data
-func (f *SonWorker) SendData(data interface{}) (interface{}, error) {
+func (f *TypedSonWorker[K, T]) SendData(data interface{}) (interface{}, error) {
 	//check
 	if data == nil {
 		return nil, errors.New("invalid parameter")
@@ -423,7 +723,7 @@ func (f *SonWorker) SendData(data interface{}) (interface{}, error) {
 }
 
 //set cb for gen ticker
-func (f *SonWorker) SetCBForGenTicker(cb func(int32) error) error {
+func (f *TypedSonWorker[K, T]) SetCBForGenTicker(cb func(int32) error) error {
 	//check
 	if cb == nil {
 		return errors.New("invalid parameter")
@@ -439,7 +739,7 @@ func (f *SonWorker) SetCBForGenTicker(cb func(int32) error) error {
 }
 
 //set cb for bind obj ticker
-func (f *SonWorker) SetCBForBindObjTicker(cb func(int32,...interface{}) error) error {
+func (f *TypedSonWorker[K, T]) SetCBForBindObjTicker(cb func(int32, map[K]T) error) error {
 	//check
 	if cb == nil {
 		return errors.New("invalid parameter")
@@ -455,33 +755,40 @@ func (f *SonWorker) SetCBForBindObjTicker(cb func(int32,...interface{}) error) e
 }
 
 //get all bind objs
-func (f *SonWorker) GetAllBindObjs() map[int64]interface{} {
+func (f *TypedSonWorker[K, T]) GetAllBindObjs() map[K]T {
 	//get with locker
 	f.Lock()
 	defer f.Unlock()
-	return f.bindObjs
+	result := make(map[K]T, len(f.bindObjs))
+	for k, v := range f.bindObjs {
+		result[k] = v
+	}
+	return result
 }
 
 //get one bind obj
-func (f *SonWorker) GetBindObj(objId int64) (interface{}, error) {
+func (f *TypedSonWorker[K, T]) GetBindObj(objId K) (T, error) {
+	var (
+		zero T
+	)
 	//check
-	if objId <= 0 {
-		return nil, errors.New("invalid parameter")
+	if !f.keyCheck(objId) {
+		return zero, errors.New("invalid parameter")
 	}
 	//get with locker
 	f.Lock()
 	defer f.Unlock()
 	v, ok := f.bindObjs[objId]
-	if ok && v != nil {
+	if ok && any(v) != nil {
 		return v, nil
 	}
-	return nil, errors.New("no such obj by id")
+	return zero, errors.New("no such obj by id")
 }
 
 //remove bind obj
-func (f *SonWorker) RemoveBindObj(objId int64) error {
+func (f *TypedSonWorker[K, T]) RemoveBindObj(objId K) error {
 	//check
-	if objId <= 0 {
+	if !f.keyCheck(objId) {
 		return errors.New("invalid parameter")
 	}
 
@@ -491,16 +798,16 @@ func (f *SonWorker) RemoveBindObj(objId int64) error {
 	delete(f.bindObjs, objId)
 	if len(f.bindObjs) <= 0 {
 		//init new and gc memory
-		f.bindObjs = map[int64]interface{}{}
+		f.bindObjs = map[K]T{}
 		runtime.GC()
 	}
 	return nil
 }
 
 //update bind obj
-func (f *SonWorker) UpdateBindObj(objId int64, obj interface{}) error {
+func (f *TypedSonWorker[K, T]) UpdateBindObj(objId K, obj T) error {
 	//check
-	if objId <= 0 || obj == nil {
+	if !f.keyCheck(objId) || any(obj) == nil {
 		return errors.New("invalid parameter")
 	}
 
@@ -517,7 +824,7 @@ func (f *SonWorker) UpdateBindObj(objId int64, obj interface{}) error {
 
 //inter cb opt for gen ticker
 //gen and bind obj ticker, only run one
-func (f *SonWorker) interCBForGenTicker(inputs ...interface{}) error {
+func (f *TypedSonWorker[K, T]) interCBForGenTicker(inputs ...interface{}) error {
 	if f.cbForGenTicker == nil {
 		return errors.New("inter cb for gen opt is nil")
 	}
@@ -526,10 +833,108 @@ func (f *SonWorker) interCBForGenTicker(inputs ...interface{}) error {
 }
 
 //inter cb opt for bind obj ticker
-func (f *SonWorker) interCBForBindObjTicker(inputs ...interface{}) error {
+//pass copy of bind objs, map may be changed by migration
+func (f *TypedSonWorker[K, T]) interCBForBindObjTicker(inputs ...interface{}) error {
 	if f.cbForBindObjTicker == nil {
 		return errors.New("inter cb for bind obj opt is nil")
 	}
-	err := f.cbForBindObjTicker(f.workerId, f.bindObjs)
+	err := f.cbForBindObjTicker(f.workerId, f.GetAllBindObjs())
 	return err
-}
\ No newline at end of file
+}
+
+//////////////////////////
+//api for non-generic opt
+//////////////////////////
+
+//set cb for bind obj ticker opt, STEP-1-3
+//bind objs map passed as the only input
+func (f *Worker) SetCBForBindObjTickerOpt(cb func(int32,...interface{}) error) {
+	//check
+	if cb == nil {
+		return
+	}
+	f.TypedWorker.SetCBForBindObjTickerOpt(func(workerId int32, objs map[int64]interface{}) error {
+		return cb(workerId, objs)
+	})
+}
+
+//get son worker
+//extParas -> dataId(int64), needBind(bool)
+//the same data id always hashed to the same son worker
+func (f *Worker) GetTargetWorker(extParas ...interface{}) (*SonWorker, error) {
+	var (
+		objId int64
+		needBind bool
+	)
+	//check
+	if extParas != nil {
+		extParaLen := len(extParas)
+		switch extParaLen {
+		case 1:
+			{
+				objId = f.Str2Int(fmt.Sprintf("%v", extParas[0]))
+			}
+		case 2:
+			{
+				objId = f.Str2Int(fmt.Sprintf("%v", extParas[0]))
+				needBind, _ = strconv.ParseBool(fmt.Sprintf("%v", extParas[1]))
+			}
+		}
+	}
+	sw, err := f.TypedWorker.GetTargetWorker(objId, needBind)
+	if err != nil {
+		return nil, err
+	}
+	return wrapSonWorker(sw), nil
+}
+
+//get son worker by id
+func (f *Worker) GetWorker(workerId int32) (*SonWorker, error) {
+	sw, err := f.TypedWorker.GetWorker(workerId)
+	if err != nil {
+		return nil, err
+	}
+	return wrapSonWorker(sw), nil
+}
+
+//construct
+func NewSonWorker(id int32, tickerRates ...float64) *SonWorker {
+	sw := NewTypedSonWorker[int64, interface{}](id, tickerRates...)
+	sw.keyCheck = isPositiveId
+	return wrapSonWorker(sw)
+}
+
+//set cb for bind obj ticker
+//bind objs map passed as the only input
+func (f *SonWorker) SetCBForBindObjTicker(cb func(int32,...interface{}) error) error {
+	//check
+	if cb == nil {
+		return errors.New("invalid parameter")
+	}
+	return f.TypedSonWorker.SetCBForBindObjTicker(func(workerId int32, objs map[int64]interface{}) error {
+		return cb(workerId, objs)
+	})
+}
+
+//get cached wrapper of son worker, keep the same pointer for the same son worker
+func wrapSonWorker(sw *TypedSonWorker[int64, interface{}]) *SonWorker {
+	sw.Lock()
+	defer sw.Unlock()
+	if v, ok := sw.wrapper.(*SonWorker); ok {
+		return v
+	}
+	v := &SonWorker{TypedSonWorker: sw}
+	sw.wrapper = v
+	return v
+}
+
+//check obj id is not zero value
+func isNonZeroKey[K comparable](objId K) bool {
+	var zero K
+	return objId != zero
+}
+
+//check int64 obj id is positive
+func isPositiveId(objId int64) bool {
+	return objId > 0
+}
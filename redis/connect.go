@@ -108,6 +108,12 @@ func (f *Connection) RunScript(
 	return script.Run(ctx, f.client, keys, args).Result()
 }
 
+//check script added or not
+func (f *Connection) HasScript(name string) bool {
+	script, ok := f.scripts[name]
+	return ok && script != nil
+}
+
 //add script
 func (f *Connection) AddScript(name, script string) error {
 	//check
@@ -0,0 +1,262 @@
+package testing
+
+import (
+	"fmt"
+	"github.com/andyzhou/tinylib/algorithm"
+	"math"
+	"sort"
+	"testing"
+)
+
+//balancer constructors for conformance test
+var balancerCreators = map[string]func() algorithm.Balancer{
+	"HashRing": func() algorithm.Balancer {
+		return algorithm.NewHashRing(algorithm.DefaultRingReplicas, nil)
+	},
+	"Consistent": func() algorithm.Balancer {
+		return algorithm.NewConsistent(nil, algorithm.Config{
+			PartitionCount: 271,
+			Hasher: algorithm.MyHasher{},
+		})
+	},
+	"ConsistentHash": func() algorithm.Balancer {
+		return algorithm.NewConsistentHash()
+	},
+	"Ring": func() algorithm.Balancer {
+		return algorithm.NewRing()
+	},
+	"XConsistent": func() algorithm.Balancer {
+		return algorithm.NewXConsistent()
+	},
+	"JumpHash": func() algorithm.Balancer {
+		return algorithm.NewJumpHash()
+	},
+	"Maglev": func() algorithm.Balancer {
+		return algorithm.NewMaglev(0)
+	},
+	"Rendezvous": func() algorithm.Balancer {
+		return algorithm.NewRendezvous()
+	},
+}
+
+//test all balancers with the same suite
+func TestBalancerConformance(t *testing.T) {
+	for name, creator := range balancerCreators {
+		t.Run(name, func(t *testing.T) {
+			runBalancerConformance(t, creator())
+		})
+	}
+}
+
+//balancer conformance suite
+func runBalancerConformance(t *testing.T, b algorithm.Balancer) {
+	members := []string{"node-a", "node-b", "node-c"}
+	keys := make([]string, 0, 3000)
+	for i := 0; i < 3000; i++ {
+		keys = append(keys, fmt.Sprintf("key-%d", i))
+	}
+
+	//empty balancer
+	if _, err := b.Locate("key"); err != algorithm.ErrNoMembers {
+		t.Fatalf("locate on empty balancer should fail, err:%v", err)
+	}
+
+	//add members
+	for _, member := range members {
+		if err := b.AddMember(member); err != nil {
+			t.Fatalf("add member %v failed, err:%v", member, err)
+		}
+	}
+	if err := b.AddMember(members[0]); err != algorithm.ErrMemberExists {
+		t.Fatalf("add duplicated member should fail, err:%v", err)
+	}
+	if got := b.Members(); fmt.Sprint(got) != fmt.Sprint(members) {
+		t.Fatalf("invalid members:%v", got)
+	}
+
+	//locate is stable and first of locate n
+	owners := map[string]string{}
+	for _, key := range keys {
+		owner, err := b.Locate(key)
+		if err != nil || owner == "" {
+			t.Fatalf("locate %v failed, owner:%v, err:%v", key, owner, err)
+		}
+		again, _ := b.Locate(key)
+		replicas, err := b.LocateN(key, 2)
+		if err != nil || len(replicas) != 2 || replicas[0] != owner ||
+			replicas[0] == replicas[1] || again != owner {
+			t.Fatalf("locate n of %v invalid, owner:%v, replicas:%v, err:%v",
+				key, owner, replicas, err)
+		}
+		owners[key] = owner
+	}
+	if _, err := b.LocateN("key", len(members) + 1); err == nil {
+		t.Fatalf("locate more than members should fail")
+	}
+
+	//heavier member owns more keys
+	if err := b.SetWeight("node-b", 4); err != nil {
+		t.Fatalf("set weight failed, err:%v", err)
+	}
+	if err := b.SetWeight("node-x", 4); err != algorithm.ErrMemberNotFound {
+		t.Fatalf("set weight of unknown member should fail, err:%v", err)
+	}
+	counts := map[string]int{}
+	for _, key := range keys {
+		owner, _ := b.Locate(key)
+		counts[owner]++
+	}
+	if counts["node-b"] <= len(keys) / len(members) {
+		t.Fatalf("weight not applied, counts:%v", counts)
+	}
+
+	//removed member never located
+	if err := b.RemoveMember("node-b"); err != nil {
+		t.Fatalf("remove member failed, err:%v", err)
+	}
+	if err := b.RemoveMember("node-b"); err != algorithm.ErrMemberNotFound {
+		t.Fatalf("remove unknown member should fail, err:%v", err)
+	}
+	for _, key := range keys {
+		owner, _ := b.Locate(key)
+		if owner == "node-b" || owner == "" {
+			t.Fatalf("invalid owner %v after remove", owner)
+		}
+	}
+	if got := b.Members(); len(got) != len(members) - 1 {
+		t.Fatalf("invalid members after remove:%v", got)
+	}
+}
+
+//benchmark key locate
+func BenchmarkBalancerLocate(b *testing.B) {
+	for _, name := range sortedBalancerNames() {
+		bl := newBalancerWithMembers(name, 10)
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				bl.Locate(fmt.Sprintf("key-%d", i))
+			}
+		})
+	}
+}
+
+//benchmark key movement after adding or removing one member and load variance
+//moved% -> moved keys percent after add, ideal is 1/11, bounded by 2/11
+//remove-moved% -> moved keys percent after remove, ideal is 1/10, bounded by 2/10
+//load-cv -> coefficient of variation of key count per member
+func BenchmarkBalancerMovement(b *testing.B) {
+	keys := make([]string, 20000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	for _, name := range sortedBalancerNames() {
+		b.Run(name, func(b *testing.B) {
+			var (
+				moved, removeMoved, cv float64
+			)
+			for i := 0; i < b.N; i++ {
+				bl := newBalancerWithMembers(name, 10)
+				owners := make([]string, len(keys))
+				counts := map[string]float64{}
+				for j, key := range keys {
+					owners[j], _ = bl.Locate(key)
+					counts[owners[j]]++
+				}
+				cv = coefficientOfVariation(counts)
+
+				//add one member, count moved keys
+				bl.AddMember("node-new")
+				moved = float64(movedKeyCount(bl, keys, owners)) * 100 / float64(len(keys))
+
+				//remove one middle member, count moved keys
+				bl = newBalancerWithMembers(name, 10)
+				for j, key := range keys {
+					owners[j], _ = bl.Locate(key)
+				}
+				bl.RemoveMember("node-3")
+				removeMoved = float64(movedKeyCount(bl, keys, owners)) * 100 / float64(len(keys))
+			}
+			if moved > 200.0 / 11 + 1 || removeMoved > 200.0 / 10 + 2 {
+				b.Fatalf("too many keys moved, add:%v%%, remove:%v%%", moved, removeMoved)
+			}
+			b.ReportMetric(moved, "moved%")
+			b.ReportMetric(removeMoved, "remove-moved%")
+			b.ReportMetric(cv, "load-cv")
+		})
+	}
+}
+
+//test jump hash key movement after removing member
+func TestJumpHashMovement(t *testing.T) {
+	keys := make([]string, 20000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	for _, c := range []struct {
+		member string
+		bound  float64
+	}{
+		{"node-9", 0},               //tail member, only its keys moved
+		{"node-3", 1.0 / 10 + 0.02}, //keys of tail bucket moved too
+	} {
+		bl := newBalancerWithMembers("JumpHash", 10)
+		owners := make([]string, len(keys))
+		owned := 0
+		for j, key := range keys {
+			owners[j], _ = bl.Locate(key)
+			if owners[j] == c.member {
+				owned++
+			}
+		}
+		bl.RemoveMember(c.member)
+		extra := float64(movedKeyCount(bl, keys, owners) - owned) / float64(len(keys))
+		if extra > c.bound {
+			t.Fatalf("too many keys moved after removing %v, extra:%v", c.member, extra)
+		}
+	}
+}
+
+//get moved key count against old owners
+func movedKeyCount(bl algorithm.Balancer, keys, owners []string) int {
+	moved := 0
+	for j, key := range keys {
+		if owner, _ := bl.Locate(key); owner != owners[j] {
+			moved++
+		}
+	}
+	return moved
+}
+
+//get sorted balancer names
+func sortedBalancerNames() []string {
+	names := make([]string, 0, len(balancerCreators))
+	for name := range balancerCreators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//create balancer with members
+func newBalancerWithMembers(name string, num int) algorithm.Balancer {
+	bl := balancerCreators[name]()
+	for i := 0; i < num; i++ {
+		bl.AddMember(fmt.Sprintf("node-%d", i))
+	}
+	return bl
+}
+
+//get coefficient of variation
+func coefficientOfVariation(counts map[string]float64) float64 {
+	var (
+		sum, variance float64
+	)
+	for _, v := range counts {
+		sum += v
+	}
+	mean := sum / float64(len(counts))
+	for _, v := range counts {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance / float64(len(counts))) / mean
+}
@@ -0,0 +1,204 @@
+package testing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/andyzhou/tinylib/util"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//test breaker state transitions
+func TestBreaker(t *testing.T) {
+	var (
+		changes []string
+		failed  = errors.New("failed")
+	)
+	timer := &util.Time{}
+	breaker := util.NewBreaker(&util.BreakerConf{
+		Name: "db",
+		MinRequests: 4,
+		FailureRatio: 0.5,
+		OpenTimeout: time.Minute,
+		HalfOpenRequests: 2,
+		OnStateChange: func(name string, from, to util.BreakerState) {
+			changes = append(changes, fmt.Sprintf("%v:%v->%v", name, from, to))
+		},
+	})
+	breaker.SetTime(timer)
+
+	//trip by failure ratio after min requests
+	breaker.Execute(func() error { return nil })
+	breaker.Execute(func() error { return failed })
+	breaker.Execute(func() error { return nil })
+	if breaker.State() != util.BreakerClosed || breaker.Counts().Requests != 3 {
+		t.Fatalf("tripped before min requests, counts:%+v", breaker.Counts())
+	}
+	if err := breaker.Execute(func() error { return failed }); err != failed {
+		t.Fatalf("invalid error:%v", err)
+	}
+	if err := breaker.Execute(func() error { return nil }); err != util.ErrBreakerOpen {
+		t.Fatalf("call allowed in open state, err:%v", err)
+	}
+
+	//half-open limits probes, failed probe opens again
+	timer.SetControlDuration(time.Minute)
+	done1, err := breaker.Allow()
+	if err != nil || breaker.State() != util.BreakerHalfOpen {
+		t.Fatalf("not half-open, state:%v, err:%v", breaker.State(), err)
+	}
+	done2, _ := breaker.Allow()
+	if _, err = breaker.Allow(); err != util.ErrBreakerOpen {
+		t.Fatalf("extra probe allowed, err:%v", err)
+	}
+	done1(nil)
+	done2(failed)
+	if breaker.State() != util.BreakerOpen {
+		t.Fatalf("failed probe not opened, state:%v", breaker.State())
+	}
+
+	//state check turns into half-open and notified, succeed probes close
+	timer.SetControlDuration(2 * time.Minute)
+	if breaker.State() != util.BreakerHalfOpen {
+		t.Fatalf("not half-open by state check, state:%v", breaker.State())
+	}
+	done1, _ = breaker.Allow()
+	done2, _ = breaker.Allow()
+	done1(nil)
+	done2(nil)
+	if breaker.State() != util.BreakerClosed || breaker.Counts().Requests != 0 {
+		t.Fatalf("succeed probes not closed, state:%v", breaker.State())
+	}
+	expected := "[db:closed->open db:open->half-open db:half-open->open db:open->half-open db:half-open->closed]"
+	if fmt.Sprint(changes) != expected {
+		t.Fatalf("invalid state changes:%v", changes)
+	}
+
+	//ignored results neither counted nor close half-open
+	breaker.Execute(func() error { return util.ErrBreakerIgnored })
+	if breaker.Counts().Requests != 0 {
+		t.Fatalf("ignored result counted:%+v", breaker.Counts())
+	}
+	for i := 0; i < 4; i++ {
+		breaker.Execute(func() error { return failed })
+	}
+	timer.SetControlDuration(3 * time.Minute)
+	for i := 0; i < 3; i++ {
+		done1, _ = breaker.Allow()
+		done1(util.ErrBreakerIgnored)
+	}
+	if breaker.State() != util.BreakerHalfOpen {
+		t.Fatalf("ignored probes changed state:%v", breaker.State())
+	}
+
+	//trip by slow calls
+	breaker = util.NewBreaker(&util.BreakerConf{
+		MinRequests: 2,
+		SlowCallDuration: 10 * time.Millisecond,
+		SlowCallRatio: 1,
+	})
+	for i := 0; i < 2; i++ {
+		breaker.Execute(func() error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		})
+	}
+	if breaker.State() != util.BreakerOpen || breaker.Counts().Slow != 2 {
+		t.Fatalf("slow calls not tripped, counts:%+v", breaker.Counts())
+	}
+	breaker.Reset()
+	if breaker.State() != util.BreakerClosed {
+		t.Fatalf("reset failed, state:%v", breaker.State())
+	}
+}
+
+//test bulkhead concurrency limit
+func TestBulkhead(t *testing.T) {
+	bulkhead := util.NewBulkhead(2, 20 * time.Millisecond)
+	if bulkhead.Acquire(nil) != nil || bulkhead.Acquire(nil) != nil {
+		t.Fatal("acquire failed")
+	}
+	if err := bulkhead.Acquire(nil); err != util.ErrBulkheadFull {
+		t.Fatalf("full bulkhead acquired, err:%v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := bulkhead.Acquire(ctx); err != context.Canceled {
+		t.Fatalf("canceled context ignored, err:%v", err)
+	}
+	bulkhead.Release()
+	if err := bulkhead.Execute(nil, func() error { return nil }); err != nil || bulkhead.InUse() != 1 {
+		t.Fatalf("execute failed, in use:%v, err:%v", bulkhead.InUse(), err)
+	}
+}
+
+//test http client per host guard
+func TestHttpClientGuard(t *testing.T) {
+	var (
+		calls   int32
+		changes int32
+	)
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/down", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	mux.HandleFunc("/hang", func(w http.ResponseWriter, r *http.Request) {
+		<- release
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := util.NewHttpClient()
+	defer client.Quit()
+	client.SetGuard(&util.HttpGuardConf{
+		Breaker: &util.BreakerConf{
+			MinRequests: 3,
+			OpenTimeout: time.Minute,
+			OnStateChange: func(name string, from, to util.BreakerState) {
+				atomic.AddInt32(&changes, 1)
+			},
+		},
+		MaxConcurrent: 1,
+		MaxWait: 2 * time.Second,
+	})
+
+	//5xx trips breaker of host
+	for i := 0; i < 5; i++ {
+		req := client.GenRequest()
+		req.Url = server.URL + "/down"
+		client.Do(req)
+	}
+	serverUrl, _ := url.Parse(server.URL)
+	breaker := client.GetBreaker(serverUrl.Host)
+	if breaker == nil || breaker.State() != util.BreakerOpen || calls != 3 || changes != 1 {
+		t.Fatalf("breaker not opened, calls:%v, changes:%v", calls, changes)
+	}
+	breaker.Reset()
+
+	//queued request of full host fails fast without worker
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		req := client.GenRequest()
+		req.Url = server.URL + "/hang"
+		client.SendReq(req)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	req := client.GenRequest()
+	req.Url = server.URL + "/down"
+	begin := time.Now()
+	resp, _ := client.SendReq(req)
+	if resp.Err != util.ErrBulkheadFull || time.Since(begin) > time.Second {
+		t.Fatalf("bulkhead not full, err:%v, cost:%v", resp.Err, time.Since(begin))
+	}
+	close(release)
+	wg.Wait()
+}
@@ -0,0 +1,168 @@
+package testing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/andyzhou/tinylib/util"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//test cache eviction policies
+func TestCachePolicy(t *testing.T) {
+	newCache := func(policy util.CachePolicy, evicted *[]string) *util.Cache[string, int] {
+		return util.NewCache[string, int](&util.CacheConf[string, int]{
+			Policy: policy,
+			ShardCount: 1,
+			MaxEntries: 3,
+			OnEvict: func(key string, value int, reason util.CacheEvictReason) {
+				if reason == util.CacheEvictCapacity {
+					*evicted = append(*evicted, key)
+				}
+			},
+		})
+	}
+
+	//lru evicts the least recently used
+	var evicted []string
+	lru := newCache(util.CachePolicyLRU, &evicted)
+	defer lru.Quit()
+	lru.Set("a", 1)
+	lru.Set("b", 2)
+	lru.Set("c", 3)
+	lru.Get("a")
+	lru.Set("d", 4)
+	if len(evicted) != 1 || evicted[0] != "b" || lru.Len() != 3 {
+		t.Fatalf("invalid lru eviction:%v", evicted)
+	}
+
+	//lfu evicts the least frequently used
+	evicted = nil
+	lfu := newCache(util.CachePolicyLFU, &evicted)
+	defer lfu.Quit()
+	lfu.Set("a", 1)
+	lfu.Set("b", 2)
+	lfu.Set("c", 3)
+	lfu.Get("a")
+	lfu.Get("a")
+	lfu.Get("c")
+	lfu.Set("d", 4)
+	lfu.Set("e", 5)
+	if fmt.Sprint(evicted) != "[b d]" {
+		t.Fatalf("invalid lfu eviction:%v", evicted)
+	}
+
+	//arc keeps frequent entries under scan
+	evicted = nil
+	arc := newCache(util.CachePolicyARC, &evicted)
+	defer arc.Quit()
+	arc.Set("hot", 1)
+	arc.Get("hot")
+	for i := 0; i < 10; i++ {
+		arc.Set(fmt.Sprintf("scan%v", i), i)
+	}
+	if _, ok := arc.Get("hot"); !ok || arc.Len() != 3 {
+		t.Fatalf("arc lost frequent entry, evicted:%v", evicted)
+	}
+
+	//replace keeps one entry
+	arc.Set("hot", 2)
+	if v, _ := arc.Get("hot"); v != 2 || arc.Len() != 3 {
+		t.Fatalf("invalid replaced value:%v", v)
+	}
+}
+
+//test cache ttl and byte cap
+func TestCacheLimit(t *testing.T) {
+	timer := &util.Time{}
+	cache := util.NewCache[string, string](&util.CacheConf[string, string]{
+		ShardCount: 1,
+		MaxBytes: 10,
+		TTL: time.Minute,
+		Sizer: func(key string, value string) int64 {
+			return int64(len(value))
+		},
+	})
+	defer cache.Quit()
+	cache.SetTime(timer)
+
+	//byte cap
+	cache.Set("a", "1234")
+	cache.Set("b", "1234")
+	cache.Set("c", "1234")
+	if _, ok := cache.Get("a"); ok || cache.Bytes() != 8 {
+		t.Fatalf("invalid bytes:%v", cache.Bytes())
+	}
+	if cache.Set("d", "12345678901") {
+		t.Fatal("too large entry cached")
+	}
+
+	//ttl
+	cache.SetWithTTL("forever", "1", 0)
+	timer.SetControlDuration(2 * time.Minute)
+	if _, ok := cache.Get("b"); ok {
+		t.Fatal("expired entry returned")
+	}
+	if removed := cache.RemoveExpired(); removed != 1 {
+		t.Fatalf("invalid removed count:%v", removed)
+	}
+	if _, ok := cache.Get("forever"); !ok {
+		t.Fatal("entry without ttl expired")
+	}
+	stats := cache.Stats()
+	if stats.Expirations != 2 || stats.Entries != 1 || stats.Hits != 1 || stats.Misses != 2 {
+		t.Fatalf("invalid stats:%+v", stats)
+	}
+}
+
+//test cache loader
+func TestCacheLoader(t *testing.T) {
+	var (
+		calls int32
+		wg    sync.WaitGroup
+	)
+	cache := util.NewCache[int64, string](&util.CacheConf[int64, string]{
+		Policy: util.CachePolicyARC,
+		MaxEntries: 1024,
+		Loader: func(ctx context.Context, key int64) (string, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(50 * time.Millisecond)
+			return fmt.Sprintf("v%v", key), nil
+		},
+	})
+	defer cache.Quit()
+
+	//concurrent misses share one load
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := cache.GetOrLoad(context.Background(), 1)
+			if err != nil || v != "v1" {
+				t.Errorf("invalid load value:%v, err:%v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 || cache.Stats().Loads != 1 {
+		t.Fatalf("invalid load calls:%v", calls)
+	}
+	if v, ok := cache.Get(1); !ok || v != "v1" {
+		t.Fatalf("loaded value not cached:%v", v)
+	}
+
+	//failed load not cached
+	failed := errors.New("failed")
+	_, err := cache.GetOrLoad(context.Background(), 2, func(ctx context.Context, key int64) (string, error) {
+		return "", failed
+	})
+	if err != failed || cache.Len() != 1 || cache.Stats().LoadErrors != 1 {
+		t.Fatalf("invalid failed load, err:%v", err)
+	}
+	if hitRate := cache.Stats().HitRate(); hitRate <= 0 || hitRate >= 1 {
+		t.Fatalf("invalid hit rate:%v", hitRate)
+	}
+}
@@ -1,7 +1,9 @@
 package testing
 
 import (
+	"fmt"
 	"github.com/andyzhou/tinylib/algorithm"
+	"hash/crc32"
 	"testing"
 )
 
@@ -48,3 +50,37 @@ func TestCHash(t *testing.T) {
 		t.Logf("Part2. Asking for %s, should have %v, yielded %s\n", k, v, hr)
 	}
 }
+
+//test collided virtual node kept by its owner after other node removed
+func TestHashRingCollision(t *testing.T) {
+	//virtual nodes of all members collide on replica index
+	ring := algorithm.NewHashRing(2, func(data []byte) uint32 {
+		return uint32(data[0])
+	})
+	ring.AddMember("a")
+	ring.AddMember("b")
+	ring.RemoveMember("b")
+	for _, key := range []string{"0", "1", "9"} {
+		if member, err := ring.Locate(key); err != nil || member != "a" {
+			t.Fatalf("invalid member of %v:%v, err:%v", key, member, err)
+		}
+	}
+}
+
+//test ring locate n with custom hash function
+func TestRingLocateN(t *testing.T) {
+	ring := algorithm.NewRing(func(data []byte) uint64 {
+		return uint64(crc32.ChecksumIEEE(data))
+	})
+	for _, member := range []string{"a", "b", "c"} {
+		ring.AddMember(member)
+	}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		member, _ := ring.Locate(key)
+		members, err := ring.LocateN(key, 2)
+		if err != nil || members[0] != member {
+			t.Fatalf("locate n not match locate, key:%v, %v != %v, err:%v", key, members, member, err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package testing
+
+import (
+	"github.com/andyzhou/tinylib/queue"
+	"github.com/andyzhou/tinylib/util"
+	"testing"
+	"time"
+)
+
+//test cron expression parse
+func TestParseCron(t *testing.T) {
+	from := time.Date(2024, 1, 31, 23, 59, 30, 0, time.UTC)
+	cases := map[string]time.Time{
+		"*/15 * * * *":              time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		"30 10 * * mon-fri":         time.Date(2024, 2, 1, 10, 30, 0, 0, time.UTC),
+		"0 0 29 2 *":                time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		"45 59 23 * * *":            time.Date(2024, 1, 31, 23, 59, 45, 0, time.UTC),
+		"0 0 1,15 * 0":              time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		"@monthly":                  time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		"@every 1m":                 time.Date(2024, 2, 1, 0, 0, 30, 0, time.UTC),
+		"TZ=Asia/Shanghai 0 8 * * *": time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for expr, expect := range cases {
+		schedule, err := queue.ParseCron(expr)
+		if err != nil {
+			t.Errorf("parse %q failed, err:%v\n", expr, err)
+			return
+		}
+		if next := schedule.Next(from); !next.Equal(expect) {
+			t.Errorf("next of %q invalid, next:%v, expect:%v\n", expr, next, expect)
+			return
+		}
+	}
+	for _, expr := range []string{"* * *", "60 * * * *", "* * 0 * *", "@never", "5-1 * * * *"} {
+		if _, err := queue.ParseCron(expr); err == nil {
+			t.Errorf("invalid expression %q should be rejected\n", expr)
+			return
+		}
+	}
+}
+
+//test cron job run with shifted clock and overlap
+func TestCron(t *testing.T) {
+	c := queue.NewCron(0.05)
+	defer c.Quit()
+
+	//shifted clock, next run time follows it
+	timer := &util.Time{}
+	timer.SetControlDuration(24 * time.Hour)
+	c.SetTime(timer)
+	c.AddJob("daily", "@daily", func(name string) error { return nil })
+	nextTimes, err := c.NextRunTimes("daily", 3)
+	if err != nil || len(nextTimes) != 3 {
+		t.Errorf("get next run times failed, err:%v\n", err)
+		return
+	}
+	if !nextTimes[0].After(time.Now().Add(23 * time.Hour)) ||
+		nextTimes[2].Sub(nextTimes[1]) != 24 * time.Hour {
+		t.Errorf("invalid next run times:%v\n", nextTimes)
+		return
+	}
+	c.RemoveJob("daily")
+
+	//slow job run every second, overlap skipped
+	runs := make(chan time.Time, 10)
+	c.AddJob("slow", "* * * * * *", func(name string) error {
+		runs <- time.Now()
+		time.Sleep(1500 * time.Millisecond)
+		return nil
+	})
+	first := <- runs
+	select {
+	case second := <- runs:
+		if second.Sub(first) < 1500 * time.Millisecond {
+			t.Errorf("overlap run not skipped, interval:%v\n", second.Sub(first))
+			return
+		}
+	case <- time.After(3 * time.Second):
+		t.Errorf("job not run again\n")
+		return
+	}
+	if stats := c.GetStats(); stats.Dropped <= 0 {
+		t.Errorf("skipped run not counted, stats:%+v\n", stats)
+		return
+	}
+	t.Logf("test cron succeed, jobs:%v\n", c.GetJobNames())
+}
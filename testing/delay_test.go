@@ -0,0 +1,59 @@
+package testing
+
+import (
+	"github.com/andyzhou/tinylib/queue"
+	"testing"
+	"time"
+)
+
+//test delay and priority delivery
+func TestDelayQueue(t *testing.T) {
+	dq := queue.NewDelayQueue()
+	defer dq.Quit()
+	gate := make(chan struct{})
+	delivered := make(chan interface{}, 8)
+	dq.SetCallback(func(id int64, data interface{}) error {
+		if data == "gate" {
+			<- gate
+		}
+		delivered <- data
+		return nil
+	})
+
+	//block main process by gate item
+	dq.Push(0, "gate", 0)
+	time.Sleep(10 * time.Millisecond)
+
+	//due items, higher priority first
+	delay := 50 * time.Millisecond
+	dq.Push(0, "low", 0, 1)
+	dq.Push(0, "high", 0, 9)
+	canceledId, _ := dq.Push(0, "canceled", 0, 5)
+	laterId, _ := dq.Push(0, "later", 0, 9)
+	if dq.LenOfPriority(9) != 2 {
+		t.Errorf("invalid priority len:%v\n", dq.LenOfPriority(9))
+		return
+	}
+	dq.Cancel(canceledId)
+	dq.Reschedule(laterId, delay)
+	begin := time.Now()
+	close(gate)
+
+	//check deliver order
+	for _, expect := range []string{"gate", "high", "low", "later"} {
+		data := <- delivered
+		if data != expect {
+			t.Errorf("invalid deliver order, data:%v, expect:%v\n", data, expect)
+			return
+		}
+	}
+	if time.Since(begin) < delay {
+		t.Errorf("deliver too early\n")
+		return
+	}
+	if dq.Len() != 0 {
+		t.Errorf("invalid delay queue len:%v\n", dq.Len())
+		return
+	}
+	t.Logf("test delay queue succeed\n")
+}
@@ -0,0 +1,76 @@
+package testing
+
+import (
+	"fmt"
+	"github.com/andyzhou/tinylib/util"
+	"sync"
+	"testing"
+)
+
+//test aho-corasick matcher
+func TestAhoCorasick(t *testing.T) {
+	ac := util.NewAhoCorasick([]util.AcWord{
+		{Word: "he", Id: 1},
+		{Word: "she", Id: 2, Category: "a"},
+		{Word: "his", Id: 3},
+		{Word: "hers", Id: 4, Category: "b"},
+		{Word: ""},
+	})
+	if ac.Size() != 4 {
+		t.Fatalf("invalid size:%v", ac.Size())
+	}
+	matches := ac.FindAll("ushers, 他his")
+	result := ""
+	for _, m := range matches {
+		result += fmt.Sprintf("%v:%v-%v%v ", m.Id, m.Start, m.End, m.Category)
+	}
+	if result != "2:1-4a 1:2-4 4:2-6b 3:11-14 " {
+		t.Fatalf("invalid matches:%v", result)
+	}
+	if ac.Contains("hi sh") || !ac.Contains("ahe") {
+		t.Fatal("invalid contains")
+	}
+}
+
+//test words filter
+func TestDFA(t *testing.T) {
+	dfa := util.NewDFA()
+	if found, txt := dfa.ChangeSensitiveWords("nothing"); found || txt != "nothing" {
+		t.Fatalf("empty filter changed text:%v", txt)
+	}
+	dfa.AddFilterWords("坏人", "bad", " ")
+	dfa.AddCategoryWords("ad", "buy now")
+
+	//invalid runes inside word skipped and masked
+	found, txt := dfa.ChangeSensitiveWords("你是坏*人, b-a-d, buynow!")
+	if !found || txt != "你是***, *****, ******!" {
+		t.Fatalf("invalid changed text:%v", txt)
+	}
+	matches := dfa.FindAll("buy now")
+	if len(matches) != 1 || matches[0].Category != "ad" || matches[0].Id != 2 {
+		t.Fatalf("invalid matches:%+v", matches)
+	}
+
+	//swap dictionary while scanning
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				dfa.ChangeSensitiveWords("bad words")
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		dfa.SetFilterWords(util.AcWord{Word: fmt.Sprintf("word%v", i), Id: 100 + i})
+	}
+	wg.Wait()
+	if dfa.Contains("bad") || !dfa.Contains("word9") {
+		t.Fatal("dictionary not swapped")
+	}
+	dfa.ResetFilterWords()
+	if dfa.Contains("word9") {
+		t.Fatal("dictionary not reset")
+	}
+}
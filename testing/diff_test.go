@@ -0,0 +1,109 @@
+package testing
+
+import (
+	"github.com/andyzhou/tinylib/algorithm"
+	"testing"
+	"time"
+)
+
+//game state for diff test
+type (
+	diffPlayer struct {
+		Name  string `json:"name"`
+		Score int    `json:"score"`
+	}
+	diffMeta struct {
+		Version int `json:"version"`
+	}
+	diffRoom struct {
+		diffMeta
+		Title   string                 `json:"title"`
+		Players []*diffPlayer          `json:"players"`
+		Props   map[string]interface{} `json:"props,omitempty"`
+		Start   time.Time              `json:"start"`
+		Next    *diffRoom              `json:"-"`
+		secret  string
+	}
+)
+
+//test structural diff
+func TestDiff(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newRoom := func() *diffRoom {
+		room := &diffRoom{
+			diffMeta: diffMeta{Version: 1},
+			Title: "room",
+			Players: []*diffPlayer{{"a", 1}, {"b", 2}, {"c", 3}},
+			Props: map[string]interface{}{"mode": "pvp", "max": 4},
+			Start: start,
+			secret: "a",
+		}
+		room.Next = room
+		return room
+	}
+	a, b := newRoom(), newRoom()
+	b.secret = "b"
+	b.Start = start.In(time.FixedZone("CST", 8 * 3600))
+	if !algorithm.Equal(a, b) {
+		t.Fatalf("equal rooms with cycle diff:%v", algorithm.Diff(a, b))
+	}
+
+	//change values
+	b.Version = 2
+	b.Players[1].Score = 12
+	b.Players = append(b.Players, &diffPlayer{"d/e", 0})
+	b.Props["max"] = 8
+	delete(b.Props, "mode")
+	b.Props["map"] = "desert"
+	b.Start = start.Add(time.Hour)
+	changes := algorithm.Diff(a, b)
+	expect := `.Version: 1 -> 2
+.Players[1].Score: 2 -> 12
+.Players[3]: + {d/e 0}
+.Props["map"]: + "desert"
+.Props["max"]: 4 -> 8
+.Props["mode"]: - "pvp"
+.Start: 2024-01-01 00:00:00 +0000 UTC -> 2024-01-01 01:00:00 +0000 UTC`
+	if changes.String() != expect {
+		t.Fatalf("invalid changes:\n%v", changes)
+	}
+
+	//json patch
+	patch, err := changes.JSONPatch()
+	expectPatch := `[{"op":"replace","path":"/version","value":2},` +
+		`{"op":"replace","path":"/players/1/score","value":12},` +
+		`{"op":"add","path":"/players/3","value":{"name":"d/e","score":0}},` +
+		`{"op":"add","path":"/props/map","value":"desert"},` +
+		`{"op":"replace","path":"/props/max","value":8},` +
+		`{"op":"remove","path":"/props/mode"},` +
+		`{"op":"replace","path":"/start","value":"2024-01-01T01:00:00Z"}]`
+	if err != nil || string(patch) != expectPatch {
+		t.Fatalf("invalid json patch:%s, err:%v", patch, err)
+	}
+
+	//remove from tail first, type changed at root
+	changes = algorithm.Diff([]int{1, 2, 3}, []int{1})
+	if changes.String() != "[2]: - 3\n[1]: - 2" {
+		t.Fatalf("invalid slice changes:\n%v", changes)
+	}
+	if changes = algorithm.Diff(1, "1"); len(changes) != 1 || changes[0].PathString() != "." {
+		t.Fatalf("invalid root changes:%v", changes)
+	}
+
+	//shared pointer compared on each path
+	type sharedPair struct {
+		X *diffPlayer `json:"x"`
+		Y *diffPlayer `json:"y"`
+	}
+	pa, pb := &diffPlayer{"a", 1}, &diffPlayer{"a", 2}
+	changes = algorithm.Diff(&sharedPair{pa, pa}, &sharedPair{pb, pb})
+	if changes.String() != ".X.Score: 1 -> 2\n.Y.Score: 1 -> 2" {
+		t.Fatalf("invalid shared pointer changes:\n%v", changes)
+	}
+	patch, err = changes.JSONPatch()
+	expectPatch = `[{"op":"replace","path":"/x/score","value":2},` +
+		`{"op":"replace","path":"/y/score","value":2}]`
+	if err != nil || string(patch) != expectPatch {
+		t.Fatalf("invalid shared pointer json patch:%s, err:%v", patch, err)
+	}
+}
@@ -0,0 +1,137 @@
+package testing
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/andyzhou/tinylib/util"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//json objects for http test
+type (
+	httpUser struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	}
+)
+
+//test http client methods, retry and json helpers
+func TestHttpClient(t *testing.T) {
+	var (
+		flaky int32
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(r.URL.RawQuery + "|" + string(body)))
+	})
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&flaky, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		var user httpUser
+		if r.Method == http.MethodPost {
+			json.NewDecoder(r.Body).Decode(&user)
+			user.Id = 2
+		}else{
+			user = httpUser{Id: 1, Name: "a"}
+		}
+		w.Header().Set("Content-Type", util.HttpJsonContentType)
+		json.NewEncoder(w).Encode(user)
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := util.NewHttpClient()
+	defer client.Quit()
+
+	//methods with status and headers
+	for kind, method := range map[int]string{
+		util.HttpReqPut: "PUT",
+		util.HttpReqPatch: "PATCH",
+		util.HttpReqDelete: "DELETE",
+		util.HttpReqHead: "HEAD",
+	} {
+		req := client.GenRequest()
+		req.Kind = kind
+		req.Url = server.URL + "/echo"
+		req.Body = []byte("body")
+		resp, err := client.Do(req)
+		if err != nil || resp.StatusCode != http.StatusAccepted || resp.Header.Get("X-Method") != method {
+			t.Fatalf("invalid %v response:%+v, err:%v", method, resp, err)
+		}
+		if method == "PUT" && string(resp.Data) != "|body" {
+			t.Fatalf("invalid put body:%s", resp.Data)
+		}
+	}
+
+	//queued request keeps working
+	req := client.GenRequest()
+	req.Url = server.URL + "/echo"
+	req.Params["a"] = 1
+	resp, err := client.SendReq(req)
+	if err != nil || resp.Err != nil || string(resp.Data) != "a=1|" || resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("invalid queued response:%+v", resp)
+	}
+
+	//retry idempotent request only
+	client.SetRetry(&util.HttpRetry{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	req = client.GenRequest()
+	req.Kind = util.HttpReqPost
+	req.Url = server.URL + "/flaky"
+	if resp, _ = client.Do(req); resp.StatusCode != http.StatusServiceUnavailable || flaky != 1 {
+		t.Fatalf("post retried, status:%v, calls:%v", resp.StatusCode, flaky)
+	}
+	req.Kind = util.HttpReqGet
+	if resp, _ = client.Do(req); resp.StatusCode != http.StatusOK || flaky != 3 {
+		t.Fatalf("get not retried, status:%v, calls:%v", resp.StatusCode, flaky)
+	}
+
+	//timeout and context
+	req = client.GenRequest()
+	req.Url = server.URL + "/slow"
+	req.Timeout = 50 * time.Millisecond
+	req.Retry = &util.HttpRetry{MaxAttempts: 1}
+	if _, err = client.Do(req); err == nil {
+		t.Fatal("timeout not reached")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req.Ctx = ctx
+	req.Timeout = 0
+	if _, err = client.Do(req); err == nil {
+		t.Fatal("canceled context ignored")
+	}
+
+	//json helpers
+	user, _, err := util.HttpGetJSON[httpUser](context.Background(), client, server.URL + "/user")
+	if err != nil || user.Id != 1 || user.Name != "a" {
+		t.Fatalf("invalid json get:%+v, err:%v", user, err)
+	}
+	created, _, err := util.HttpPostJSON[*httpUser](context.Background(), client, server.URL + "/user",
+		httpUser{Name: "b"})
+	if err != nil || created == nil || created.Id != 2 || created.Name != "b" {
+		t.Fatalf("invalid json post:%+v, err:%v", created, err)
+	}
+	_, resp, err = util.HttpGetJSON[httpUser](context.Background(), client, server.URL + "/missing")
+	if statusErr, ok := err.(*util.HttpStatusError); !ok || statusErr.StatusCode != http.StatusNotFound ||
+		resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("invalid status error:%v", err)
+	}
+}
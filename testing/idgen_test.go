@@ -0,0 +1,155 @@
+package testing
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"github.com/andyzhou/tinylib/idgen"
+	"github.com/andyzhou/tinylib/util"
+	"sync"
+	"testing"
+	"time"
+)
+
+//test snowflake generator
+func TestSnowflake(t *testing.T) {
+	timer := &util.Time{}
+	sf, err := idgen.NewSnowflake(&idgen.SnowflakeConf{
+		NodeId: 7,
+		MaxRollback: time.Second,
+		Timer: timer,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = idgen.NewSnowflake(&idgen.SnowflakeConf{NodeId: 1024}); err == nil {
+		t.Fatal("node id out of range accepted")
+	}
+	if _, err = idgen.NewSnowflake(&idgen.SnowflakeConf{NodeBits: 20, SequenceBits: 20}); err == nil {
+		t.Fatal("too large node and sequence bits accepted")
+	}
+
+	//unique and ordered per goroutine
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		seen  = map[int64]bool{}
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := int64(0)
+			for j := 0; j < 5000; j++ {
+				id, subErr := sf.NextId()
+				if subErr != nil || id <= last {
+					t.Errorf("invalid id:%v, last:%v, err:%v", id, last, subErr)
+					return
+				}
+				last = id
+				mu.Lock()
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 8 * 5000 {
+		t.Fatalf("duplicated ids, unique:%v", len(seen))
+	}
+
+	//parse
+	before := time.Now()
+	id, _ := sf.NextId()
+	at, nodeId, _ := sf.Parse(id)
+	if nodeId != 7 || at.Before(before.Add(-time.Millisecond)) || at.After(time.Now()) {
+		t.Fatalf("invalid parse, time:%v, node:%v", at, nodeId)
+	}
+
+	//small rollback keeps increasing
+	timer.SetControlDuration(-500 * time.Millisecond)
+	next, err := sf.NextId()
+	if err != nil || next <= id {
+		t.Fatalf("small rollback failed, id:%v, err:%v", next, err)
+	}
+
+	//large rollback rejected, recovered when clock back
+	timer.SetControlDuration(-time.Minute)
+	if _, err = sf.NextId(); err != idgen.ErrClockRollback {
+		t.Fatalf("large rollback not rejected, err:%v", err)
+	}
+	timer.ResetControlDuration()
+	if last, err := sf.NextId(); err != nil || last <= next {
+		t.Fatalf("recover failed, id:%v, err:%v", last, err)
+	}
+}
+
+//test ulid encode and decode
+func TestULID(t *testing.T) {
+	id, err := idgen.ParseULID("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	if err != nil || id.Time().UnixMilli() != 1469922850259 {
+		t.Fatalf("invalid ulid time:%v, err:%v", id.Time().UnixMilli(), err)
+	}
+	if id.String() != "01ARZ3NDEKTSV4RRFFQ69G5FAV" {
+		t.Fatalf("invalid ulid string:%v", id)
+	}
+	if lower, _ := idgen.ParseULID("01arz3ndektsv4rrffq69g5fav"); lower != id {
+		t.Fatalf("lower case ulid not matched:%v", lower)
+	}
+	for _, bad := range []string{"", "81ARZ3NDEKTSV4RRFFQ69G5FAV", "01ARZ3NDEKTSV4RRFFQ69G5FAU"} {
+		if _, err = idgen.ParseULID(bad); err == nil {
+			t.Fatalf("invalid ulid %q accepted", bad)
+		}
+	}
+
+	//monotonic under the same millisecond
+	gen := idgen.NewULIDGenerator()
+	last := ""
+	for i := 0; i < 1000; i++ {
+		next, subErr := gen.New()
+		if subErr != nil || next.String() <= last {
+			t.Fatalf("ulid not monotonic, %v after %v, err:%v", next, last, subErr)
+		}
+		last = next.String()
+	}
+
+	//json round trip
+	data, _ := json.Marshal(id)
+	var decoded idgen.ULID
+	if err = json.Unmarshal(data, &decoded); err != nil || decoded != id {
+		t.Fatalf("ulid json round trip failed, data:%s, err:%v", data, err)
+	}
+}
+
+//test ksuid encode and decode
+func TestKSUID(t *testing.T) {
+	id, err := idgen.ParseKSUID("0ujtsYcgvSTl8PAuAdqWYSMnLOv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts := id.Time().Unix() - idgen.KSUIDEpoch; ts != 107608047 {
+		t.Fatalf("invalid ksuid timestamp:%v", ts)
+	}
+	payload := hex.EncodeToString(id.Payload())
+	if payload != "b5a1cd34b5f99d1154fb6853345c9735" {
+		t.Fatalf("invalid ksuid payload:%v", payload)
+	}
+	if id.String() != "0ujtsYcgvSTl8PAuAdqWYSMnLOv" {
+		t.Fatalf("invalid ksuid string:%v", id)
+	}
+	for _, bad := range []string{"", "0ujtsYcgvSTl8PAuAdqWYSMnLO!", "zzzzzzzzzzzzzzzzzzzzzzzzzzz"} {
+		if _, err = idgen.ParseKSUID(bad); err == nil {
+			t.Fatalf("invalid ksuid %q accepted", bad)
+		}
+	}
+
+	//round trip and ordering by time
+	now := time.Now()
+	a, _ := idgen.NewKSUIDAt(now)
+	b, _ := idgen.NewKSUIDAt(now.Add(time.Second))
+	if a.String() >= b.String() || a.Time().Unix() != now.Unix() {
+		t.Fatalf("invalid ksuid order, %v, %v", a, b)
+	}
+	if parsed, _ := idgen.ParseKSUID(a.String()); parsed != a {
+		t.Fatalf("ksuid round trip failed:%v", parsed)
+	}
+}
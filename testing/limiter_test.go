@@ -0,0 +1,99 @@
+package testing
+
+import (
+	"github.com/andyzhou/tinylib/limiter"
+	"github.com/andyzhou/tinylib/util"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+//local limiter with shifted clock
+type clockLimiter interface {
+	limiter.Limiter
+	SetTime(t *util.Time)
+}
+
+//test local limiters with the same suite
+func TestLocalLimiters(t *testing.T) {
+	limit := limiter.Limit{Rate: 10, Period: time.Second, Burst: 5}
+	bucket, _ := limiter.NewTokenBucket(limit)
+	gcra, _ := limiter.NewGCRA(limit)
+	window, _ := limiter.NewSlidingWindow(limiter.Limit{Rate: 5, Period: 500 * time.Millisecond})
+	for name, l := range map[string]clockLimiter{"TokenBucket": bucket, "GCRA": gcra, "SlidingWindow": window} {
+		t.Run(name, func(t *testing.T) {
+			timer := &util.Time{}
+			timer.SetControlDuration(-time.Hour)
+			l.SetTime(timer)
+
+			//burst allowed at once
+			for i := 0; i < 5; i++ {
+				if !l.Allow("ip-1") {
+					t.Fatalf("request %v of burst rejected", i)
+				}
+			}
+			result, err := l.AllowN("ip-1", 1)
+			if err != nil || result.Allowed || result.Remaining != 0 ||
+				result.RetryAfter <= 0 || result.RetryAfter > 500 * time.Millisecond {
+				t.Fatalf("request over burst should be rejected, result:%+v, err:%v", result, err)
+			}
+			if !l.Allow("ip-2") {
+				t.Fatalf("other key should not be limited")
+			}
+			if _, err = l.AllowN("ip-1", 6); err != limiter.ErrExceedsBurst {
+				t.Fatalf("cost over burst should fail, err:%v", err)
+			}
+
+			//allowed again after retry after
+			timer.SetControlDuration(-time.Hour + result.RetryAfter)
+			if !l.Allow("ip-1") {
+				t.Fatalf("request after retry time rejected")
+			}
+
+			//fully recovered
+			timer.SetControlDuration(-time.Hour + time.Second)
+			result, _ = l.AllowN("ip-1", 5)
+			if !result.Allowed {
+				t.Fatalf("limit not recovered, result:%+v", result)
+			}
+		})
+	}
+	if _, err := limiter.NewGCRA(limiter.Limit{}); err != limiter.ErrInvalidLimit {
+		t.Fatalf("invalid limit should fail, err:%v", err)
+	}
+}
+
+//test gin middleware
+func TestLimiterMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l, _ := limiter.NewGCRA(limiter.Limit{Rate: 2, Period: time.Minute})
+	engine := gin.New()
+	engine.Use(limiter.GinMiddleware(l))
+	engine.GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	//same ip with different ports share limit
+	for _, addr := range []string{"10.0.0.1:1000", "10.0.0.1:1001"} {
+		if rec := request(addr); rec.Code != http.StatusOK {
+			t.Fatalf("request of %v should be allowed, code:%v", addr, rec.Code)
+		}
+	}
+	rec := request("10.0.0.1:1002")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get(limiter.HeaderRetryAfter) != "30" {
+		t.Fatalf("request should be limited, code:%v, header:%v", rec.Code, rec.Header())
+	}
+	if rec = request("10.0.0.2:1000"); rec.Code != http.StatusOK ||
+		rec.Header().Get(limiter.HeaderRemaining) != "1" {
+		t.Fatalf("other ip should be allowed, code:%v, header:%v", rec.Code, rec.Header())
+	}
+}
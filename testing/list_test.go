@@ -1,6 +1,7 @@
 package testing
 
 import (
+	"errors"
 	"github.com/andyzhou/tinylib/queue"
 	"math/rand"
 	"runtime"
@@ -75,3 +76,114 @@ func BenchmarkListPush(b *testing.B) {
 	b.Logf("list len:%v\n", getLen())
 }
 
+
+//test disk list replay
+func TestDiskList(t *testing.T) {
+	conf := &queue.ListConf{
+		DataPath: t.TempDir(),
+	}
+
+	//push and force quit, elements kept in segment files
+	dl, err := queue.NewDiskList(conf)
+	if err != nil {
+		t.Errorf("new disk list failed, err:%v\n", err)
+		return
+	}
+	for i := 1; i <= 3; i++ {
+		dl.Push(int64(i))
+	}
+	dl.Join(int64(0))
+	dl.Quit(true)
+
+	//replay and consume
+	dl, err = queue.NewDiskList(conf)
+	if err != nil {
+		t.Errorf("reopen disk list failed, err:%v\n", err)
+		return
+	}
+	if dl.Len() != 4 {
+		t.Errorf("replay disk list failed, len:%v\n", dl.Len())
+		return
+	}
+	consumed := make(chan interface{}, 4)
+	dl.SetConsumer(func(data interface{}) error {
+		consumed <- data
+		return nil
+	}, 0.01)
+	for i := 0; i < 4; i++ {
+		data := <- consumed
+		if data != int64(i) {
+			t.Errorf("disk list order invalid, data:%v, expect:%v\n", data, i)
+			return
+		}
+	}
+	dl.Quit()
+
+	//all consumed elements acked
+	dl, err = queue.NewDiskList(conf)
+	if err != nil || dl.Len() != 0 {
+		t.Errorf("disk list ack failed, len:%v, err:%v\n", dl.Len(), err)
+		return
+	}
+	dl.Quit(true)
+
+	//failed element kept unacked, redelivered after replay
+	dl, _ = queue.NewDiskList(conf)
+	dl.Push(int64(7))
+	dl.Push(int64(8))
+	failed := make(chan interface{}, 100)
+	dl.SetConsumer(func(data interface{}) error {
+		failed <- data
+		return errors.New("consume failed")
+	}, 0.01)
+	if data := <- failed; data != int64(7) {
+		t.Errorf("invalid failed data:%v\n", data)
+		return
+	}
+	dl.Quit(true)
+	dl, err = queue.NewDiskList(conf)
+	if err != nil || dl.Len() != 2 {
+		t.Errorf("failed element not replayed, len:%v, err:%v\n", dl.Len(), err)
+		return
+	}
+	dl.SetConsumer(func(data interface{}) error {
+		consumed <- data
+		return nil
+	}, 0.01)
+	for _, expect := range []int64{7, 8} {
+		if data := <- consumed; data != expect {
+			t.Errorf("redelivery order invalid, data:%v, expect:%v\n", data, expect)
+			return
+		}
+	}
+	dl.Quit(true)
+	t.Logf("test disk list succeed\n")
+}
+
+//test typed list without type assertion
+func TestTypedList(t *testing.T) {
+	type item struct {
+		id int64
+	}
+	l := queue.NewTypedList[*item]()
+	for i := int64(1); i <= 3; i++ {
+		l.Push(&item{id: i})
+	}
+	if ele := l.Pop(); ele == nil || l.GetVal(ele).id != 1 {
+		t.Errorf("typed list pop failed\n")
+		return
+	}
+	consumed := make(chan int64, 2)
+	l.SetConsumer(func(v *item) error {
+		consumed <- v.id
+		return nil
+	}, 0.01)
+	for i := int64(2); i <= 3; i++ {
+		if id := <- consumed; id != i {
+			t.Errorf("typed list consume order invalid, id:%v, expect:%v\n", id, i)
+			return
+		}
+	}
+	l.Quit()
+	t.Logf("test typed list succeed\n")
+}
@@ -0,0 +1,373 @@
+package testing
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/andyzhou/tinylib/config"
+	"github.com/andyzhou/tinylib/util"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//test structured logger
+func TestLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	level := util.NewLogLevelVar(util.LogLevelInfo)
+	logger := util.NewLogger(util.NewLogTextHandler(buf, &util.LogHandlerOptions{
+		Level: level,
+		AddSource: true,
+	}))
+
+	//text with bound fields and group
+	child := logger.With("uid", 7).WithGroup("req")
+	child.Debug("hidden")
+	child.Info("hello world", "path", "/a b", util.Field("err", errors.New("bad")))
+	line := buf.String()
+	for _, sub := range []string{`level=INFO source=logger_test.go:`, `msg="hello world" uid=7 req.path="/a b" req.err=bad`} {
+		if !strings.Contains(line, sub) {
+			t.Fatalf("invalid text log:%v", line)
+		}
+	}
+
+	//change level at runtime
+	buf.Reset()
+	level.SetString("debug")
+	child.Debug("shown", "odd")
+	if !strings.Contains(buf.String(), `level=DEBUG`) || !strings.Contains(buf.String(), `!BADKEY=odd`) {
+		t.Fatalf("invalid debug log:%v", buf.String())
+	}
+
+	//json with nested groups
+	buf.Reset()
+	logger = util.NewLogger(util.NewLogJSONHandler(buf))
+	logger.With("req", 1).WithGroup("http").With("method", "GET").
+		Info("done", "status", 200, "cost", time.Second)
+	var data map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &data); err != nil {
+		t.Fatalf("invalid json log:%v, err:%v", buf.String(), err)
+	}
+	result := fmt.Sprint(data["level"], data["msg"], data["req"], data["http"])
+	if result != "INFOdone1 map[cost:1s method:GET status:200]" {
+		t.Fatalf("invalid json log:%v", result)
+	}
+}
+
+//test log service levels
+func TestLogService(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	path := t.TempDir()
+	service := util.NewLogServiceWithConf(&util.LogConf{
+		Path: path,
+		Prefix: "test",
+		Format: util.LogFormatJSON,
+	})
+	service.Info("first", "n", 1)
+	if !service.ReloadLevel(map[string]interface{}{util.LogLevelConfKey: "error"}) ||
+		service.GetLevel() != util.LogLevelError {
+		t.Fatalf("invalid reloaded level:%v", service.GetLevel())
+	}
+	service.Info("second")
+	service.With("n", 3).Error("third")
+	service.Close()
+
+	//check file
+	fileName := fmt.Sprintf("%s/test-%s.log", path, time.Now().Format("2006-01-02"))
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"msg":"first","n":1`) ||
+		!strings.Contains(lines[1], `"level":"ERROR","msg":"third","n":3`) {
+		t.Fatalf("invalid log file:%v", string(data))
+	}
+}
+
+//test log level hot reload by sub config
+func TestLogServiceSubConfig(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	path := t.TempDir()
+	service := util.NewLogServiceWithConf(&util.LogConf{
+		Path: path,
+		Prefix: "subconf",
+	})
+	defer service.Close()
+
+	//level loaded by sub config callback
+	confFile := path + "/conf.json"
+	os.WriteFile(confFile, []byte(`{"log_level":"warn"}`), 0644)
+	subConf := config.NewSubConfig(confFile, service.OnConfigReload)
+	defer subConf.Quit()
+	if service.GetLevel() != util.LogLevelWarn {
+		t.Fatalf("level not reloaded:%v", service.GetLevel())
+	}
+
+	//missing key keeps level, invalid level fails
+	if !service.OnConfigReload(map[string]interface{}{"other": 1}) || service.GetLevel() != util.LogLevelWarn {
+		t.Fatalf("missing level key failed, level:%v", service.GetLevel())
+	}
+	if service.OnConfigReload(map[string]interface{}{util.LogLevelConfKey: "bad"}) {
+		t.Fatal("invalid level accepted")
+	}
+}
+
+//test log rotation, compression and retention
+func TestLogRotate(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	path := t.TempDir()
+	service := util.NewLogServiceWithConf(&util.LogConf{
+		Path: path,
+		Prefix: "rotate",
+		Rotate: util.LogRotateSize,
+		MaxSize: 200,
+		Compress: true,
+		MaxBackups: 2,
+	})
+	for i := 0; i < 20; i++ {
+		service.Info("rotate by size", "index", i)
+	}
+
+	//reopen after moved away
+	if err := os.Rename(path + "/rotate.log", path + "/moved.log"); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	service.Info("after reopen")
+	service.Close()
+
+	//check files
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var backups []string
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".log.gz") {
+			backups = append(backups, entry.Name())
+		}else if entry.Name() != "rotate.log" && entry.Name() != "moved.log" {
+			t.Fatalf("unexpected file:%v", entry.Name())
+		}
+	}
+	if len(backups) != 2 {
+		t.Fatalf("invalid backups:%v", backups)
+	}
+	data, _ := os.ReadFile(path + "/rotate.log")
+	if !strings.Contains(string(data), "after reopen") {
+		t.Fatalf("invalid reopened file:%v", string(data))
+	}
+
+	//gzip content readable
+	file, err := os.Open(path + "/" + backups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(reader)
+	if !strings.Contains(string(content), "rotate by size") {
+		t.Fatalf("invalid gzip content:%v", string(content))
+	}
+}
+
+//test retention only removes files of same service
+func TestLogRetention(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	path := t.TempDir()
+	old := time.Now().Add(-24 * time.Hour)
+	files := map[string]bool{
+		"app-2020-01-01.log": true,
+		"app-2020-01-01.2.log.gz": true,
+		"app.3.log": true,
+		"app-worker-2020-01-01.log": false,
+		"app-worker.1.log": false,
+		"app.worker.log": false,
+		"app-2020-01-01-x.log": false,
+		"apps-2020-01-01.log": false,
+	}
+	for name := range files {
+		os.WriteFile(path + "/" + name, []byte("old"), 0644)
+		os.Chtimes(path + "/" + name, old, old)
+	}
+	service := util.NewLogServiceWithConf(&util.LogConf{
+		Path: path,
+		Prefix: "app",
+		MaxAge: time.Hour,
+	})
+	defer service.Close()
+	if err := service.CleanBackups(); err != nil {
+		t.Fatal(err)
+	}
+	for name, removed := range files {
+		if _, err := os.Stat(path + "/" + name); os.IsNotExist(err) != removed {
+			t.Fatalf("invalid retention of %v, expect removed:%v", name, removed)
+		}
+	}
+}
+
+//sink blocked until gate opened
+type gateLogSink struct {
+	gate    chan struct{}
+	entries []string
+	sync.Mutex
+}
+
+func (s *gateLogSink) WriteBatch(entries [][]byte) error {
+	<- s.gate
+	s.Lock()
+	defer s.Unlock()
+	for _, v := range entries {
+		s.entries = append(s.entries, string(v))
+	}
+	return nil
+}
+
+func (s *gateLogSink) Close() error {
+	return nil
+}
+
+//test async log writer and sinks
+func TestAsyncLogWriter(t *testing.T) {
+	path := t.TempDir()
+
+	//syslog over unix socket
+	syslogAddr := path + "/syslog.sock"
+	syslogConn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: syslogAddr, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syslogConn.Close()
+	syslogSink, err := util.NewLogSyslogSink(&util.LogSyslogConf{Address: syslogAddr, Tag: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//http batch endpoint
+	bodies := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		bodies <- r.Header.Get("Content-Type") + "|" + string(data)
+	}))
+	defer server.Close()
+	client := util.NewHttpClient()
+	defer client.Quit()
+	httpSink, _ := util.NewLogHttpSink(client, server.URL)
+
+	//file sink, flush on close
+	fileSink, err := util.NewLogFileSink(path + "/async.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := util.NewAsyncLogWriter(&util.AsyncLogConf{
+		FlushInterval: time.Hour,
+	}, fileSink, syslogSink, httpSink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write([]byte("line1\n"))
+	writer.Write([]byte("line2\n"))
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = writer.Write([]byte("closed\n")); err != util.ErrLogWriterClosed {
+		t.Fatalf("write after close, err:%v", err)
+	}
+	data, _ := os.ReadFile(path + "/async.log")
+	if string(data) != "line1\nline2\n" {
+		t.Fatalf("invalid file sink:%q", data)
+	}
+	buf := make([]byte, 1024)
+	syslogConn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _ := syslogConn.Read(buf)
+	if msg := string(buf[:n]); !strings.HasPrefix(msg, "<14>") || !strings.HasSuffix(msg, "]: line1\n") {
+		t.Fatalf("invalid syslog message:%q", msg)
+	}
+	if body := <- bodies; body != util.LogHttpContentType + "|line1\nline2\n" {
+		t.Fatalf("invalid http body:%q", body)
+	}
+
+	//overflow drops newest, flush writes queued
+	gate := &gateLogSink{gate: make(chan struct{})}
+	writer, _ = util.NewAsyncLogWriter(&util.AsyncLogConf{
+		BufferSize: 4,
+		BatchSize: 1,
+	}, gate)
+	for i := 0; i < 10; i++ {
+		writer.Write([]byte(fmt.Sprintf("%v", i)))
+	}
+	close(gate.gate)
+	writer.Flush()
+	if writer.Dropped() == 0 || len(gate.entries) + int(writer.Dropped()) != 10 || gate.entries[0] != "0" {
+		t.Fatalf("invalid overflow, entries:%v, dropped:%v", gate.entries, writer.Dropped())
+	}
+	writer.Close()
+}
+
+//test http sink errors
+func TestLogHttpSink(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	client := util.NewHttpClient()
+	sink, _ := util.NewLogHttpSink(client, server.URL)
+
+	//non 2xx status is error
+	err := sink.WriteBatch([][]byte{[]byte("line\n")})
+	if statusErr, ok := err.(*util.HttpStatusError); !ok || statusErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("invalid status error:%v", err)
+	}
+
+	//failure not written into global log, works after client quit
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	client.Quit()
+	sink, _ = util.NewLogHttpSink(client, "127.0.0.1:1")
+	if err = sink.WriteBatch([][]byte{[]byte("line\n")}); err == nil {
+		t.Fatal("unreachable endpoint succeed")
+	}
+	if buf.Len() > 0 {
+		t.Fatalf("sink failure logged:%v", buf.String())
+	}
+}
+
+//test log service with async writer
+func TestAsyncLogService(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	path := t.TempDir()
+	extra := &bytes.Buffer{}
+	service := util.NewLogServiceWithConf(&util.LogConf{
+		Path: path,
+		Prefix: "async",
+		Async: &util.AsyncLogConf{FlushInterval: time.Hour},
+		Sinks: []util.LogSink{util.NewLogWriterSink(extra)},
+	})
+	log.Println("global log")
+	service.Info("structured log")
+	if err := service.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	service.Close()
+	fileName := fmt.Sprintf("%s/async-%s.log", path, time.Now().Format("2006-01-02"))
+	data, _ := os.ReadFile(fileName)
+	if !strings.Contains(string(data), "global log") || !strings.Contains(string(data), "structured log") ||
+		string(data) != extra.String() {
+		t.Fatalf("invalid async log file:%v", string(data))
+	}
+}
@@ -0,0 +1,365 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/andyzhou/tinylib/queue"
+	"github.com/andyzhou/tinylib/util"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//cb for slow queue
+func cbForSlowQueue(data interface{}) (interface{}, error) {
+	time.Sleep(50 * time.Millisecond)
+	if data == "bad" {
+		return nil, errors.New("bad data")
+	}
+	return data, nil
+}
+
+//test send data with context
+func TestQueueSendDataCtx(t *testing.T) {
+	q := queue.NewQueue(1)
+	q.SetCallback(cbForSlowQueue)
+	defer q.Quit()
+
+	//callback error should reach caller
+	opt := &queue.SendOption{NeedResp: true}
+	_, err := q.SendDataCtx(context.Background(), "bad", opt)
+	if err == nil || err.Error() != "bad data" {
+		t.Errorf("expect callback error, got:%v\n", err)
+		return
+	}
+
+	//timeout while waiting for response
+	ctx, cancel := context.WithTimeout(context.Background(), 10 * time.Millisecond)
+	defer cancel()
+	_, err = q.SendDataCtx(ctx, "good", opt)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect deadline exceeded, got:%v\n", err)
+		return
+	}
+
+	//fire and forget data still processed after ctx canceled
+	var processed int32
+	fq := queue.NewQueue(8)
+	fq.SetCallback(func(data interface{}) (interface{}, error) {
+		atomic.AddInt32(&processed, 1)
+		return nil, nil
+	})
+	defer fq.Quit()
+	for i := 0; i < 5; i++ {
+		subCtx, subCancel := context.WithCancel(context.Background())
+		fq.SendDataCtx(subCtx, i+1)
+		subCancel()
+	}
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&processed) != 5 {
+		t.Errorf("expect 5 processed, got:%v\n", processed)
+		return
+	}
+	t.Logf("test send data ctx succeed\n")
+}
+
+//test overflow policy
+func TestQueueOverflow(t *testing.T) {
+	q := queue.NewQueue(1)
+	q.SetCallback(cbForSlowQueue)
+	defer q.Quit()
+
+	//fill the queue
+	for i := 0; i < 3; i++ {
+		q.SendDataCtx(context.Background(), i, &queue.SendOption{Policy: queue.OverflowDropNewest})
+	}
+
+	//fail fast
+	_, err := q.SendData("full")
+	if !errors.Is(err, queue.ErrQueueFull) {
+		t.Errorf("expect queue full, got:%v\n", err)
+		return
+	}
+
+	//drop newest
+	_, err = q.SendDataCtx(context.Background(), "newest", &queue.SendOption{Policy: queue.OverflowDropNewest})
+	if !errors.Is(err, queue.ErrQueueDropped) {
+		t.Errorf("expect data dropped, got:%v\n", err)
+		return
+	}
+
+	//drop oldest always succeed
+	_, err = q.SendDataCtx(context.Background(), "oldest", &queue.SendOption{Policy: queue.OverflowDropOldest})
+	if err != nil {
+		t.Errorf("expect drop oldest succeed, got:%v\n", err)
+		return
+	}
+
+	//fill again while consumer busy, then block until ctx done
+	time.Sleep(10 * time.Millisecond)
+	q.SendDataCtx(context.Background(), "fill", &queue.SendOption{Policy: queue.OverflowDropNewest})
+	ctx, cancel := context.WithTimeout(context.Background(), 10 * time.Millisecond)
+	defer cancel()
+	_, err = q.SendDataCtx(ctx, "block")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect deadline exceeded, got:%v\n", err)
+		return
+	}
+	t.Logf("test queue overflow succeed\n")
+}
+
+//test queue stats and exporter
+func TestQueueStats(t *testing.T) {
+	q := queue.NewQueue(4)
+	q.SetCallback(cbForSlowQueue)
+	defer q.Quit()
+	q.SendData("good", true)
+	q.SendData("bad", true)
+
+	//check stats
+	stats := q.GetStats()
+	if stats.Enqueued != 2 || stats.Processed != 1 || stats.Failed != 1 {
+		t.Errorf("invalid queue stats:%+v\n", stats)
+		return
+	}
+	if stats.Latency.Count != 2 || stats.HighWater < 1 {
+		t.Errorf("invalid latency stats:%+v\n", stats.Latency)
+		return
+	}
+
+	//export
+	w := queue.NewWorker()
+	defer w.Quit()
+	w.CreateWorkers(2)
+	w.UpdateBindObj(1, "obj")
+	exporter := queue.NewStatsExporter()
+	exporter.Register("test", q)
+	exporter.RegisterWorker("worker", w)
+	buff := bytes.NewBuffer(nil)
+	exporter.Export(buff)
+	for _, line := range []string{
+		`tinylib_queue_processed_total{name="test"} 1`,
+		`tinylib_queue_callback_seconds_count{name="test"} 2`,
+		`tinylib_worker_son_workers{name="worker"} 2`,
+	} {
+		if !strings.Contains(buff.String(), line) {
+			t.Errorf("export line not found:%v\n", line)
+			return
+		}
+	}
+	t.Logf("test queue stats succeed\n")
+}
+
+//test callback panic isolation
+func TestQueuePanic(t *testing.T) {
+	q := queue.NewQueue()
+	defer q.Quit()
+	deadLetters := make(chan interface{}, 1)
+	q.SetSupervisor(&queue.SupervisorConf{
+		CBForPanic: func(name string, data interface{}, err interface{}, stack []byte) {},
+		CBForDeadLetter: func(name string, data interface{}, err interface{}) {
+			deadLetters <- data
+		},
+	})
+	q.SetCallback(func(data interface{}) (interface{}, error) {
+		if data == "panic" {
+			panic("bad data")
+		}
+		return data, nil
+	})
+
+	//panic item reported and sent to dead letter
+	_, err := q.SendData("panic", true)
+	if !errors.Is(err, queue.ErrCallbackPanic) {
+		t.Errorf("expect callback panic, got:%v\n", err)
+		return
+	}
+	if data := <- deadLetters; data != "panic" {
+		t.Errorf("invalid dead letter:%v\n", data)
+		return
+	}
+
+	//queue still alive
+	resp, err := q.SendData("good", true)
+	if err != nil || resp != "good" || !q.Healthy() {
+		t.Errorf("queue not alive after panic, resp:%v, err:%v\n", resp, err)
+		return
+	}
+	t.Logf("test queue panic succeed\n")
+}
+
+//test graceful shutdown with deadline
+func TestQueueShutdown(t *testing.T) {
+	q := queue.NewQueue(10)
+	q.SetCallback(cbForSlowQueue)
+	l := queue.NewList()
+	l.SetConsumer(cbForConsume, 0.5)
+	for i := 0; i < 5; i++ {
+		q.SendData(i)
+		l.Push(int64(i))
+	}
+
+	//shutdown group with deadline
+	group := queue.NewShutdownGroup()
+	group.Add("queue", q)
+	group.Add("list", l)
+	ctx, cancel := context.WithTimeout(context.Background(), 120 * time.Millisecond)
+	defer cancel()
+	reports := group.Shutdown(ctx)
+
+	//queue can't drain all in time
+	qReport := reports["queue"]
+	if !qReport.TimedOut || qReport.Abandoned <= 0 ||
+		qReport.Processed + qReport.Abandoned > 5 {
+		t.Errorf("invalid queue report:%+v\n", qReport)
+		return
+	}
+	if _, err := q.SendData("late"); err == nil {
+		t.Errorf("queue intake not stopped\n")
+		return
+	}
+
+	//list drained without wait
+	lReport := reports["list"]
+	if lReport.TimedOut || lReport.Abandoned != 0 || l.Len() != 0 {
+		t.Errorf("invalid list report:%+v\n", lReport)
+		return
+	}
+	t.Logf("test queue shutdown succeed, queue:%+v, list:%+v\n", qReport, lReport)
+}
+
+//test shutdown group and app callback registered into same signal
+func TestShutdownGroupSignal(t *testing.T) {
+	for _, groupFirst := range []bool{true, false} {
+		q := queue.NewQueue(10)
+		q.SetCallback(cbForSlowQueue)
+		group := queue.NewShutdownGroup()
+		group.Add("queue", q)
+		appDone := make(chan bool, 1)
+		s := util.NewSignal(2)
+		if groupFirst {
+			group.RegisterSignal(s)
+		}
+		s.RegisterShutDownChan(make(chan bool, 1), func() {
+			appDone <- true
+		})
+		if !groupFirst {
+			group.RegisterSignal(s)
+		}
+		s.ForceNotify()
+
+		//both app callback and group drain run
+		select {
+		case <- appDone:
+		case <- time.After(time.Second):
+			t.Errorf("app callback not run, group first:%v\n", groupFirst)
+			return
+		}
+		drained := false
+		for i := 0; i < 100 && !drained; i++ {
+			_, err := q.SendData("late")
+			drained = err != nil
+			time.Sleep(10 * time.Millisecond)
+		}
+		if !drained {
+			t.Errorf("group not drained, group first:%v\n", groupFirst)
+			return
+		}
+	}
+}
+
+//test batch consumer mode
+func TestQueueBatch(t *testing.T) {
+	batchSizes := make(chan int, 10)
+	q := queue.NewQueue(10)
+	q.SetBatchCallback(func(data []interface{}) ([]interface{}, error) {
+		batchSizes <- len(data)
+		results := make([]interface{}, len(data))
+		for i, v := range data {
+			results[i] = v.(int) * 2
+		}
+		return results, nil
+	}, 3, 50 * time.Millisecond)
+
+	//each sender get its own result
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	results := make(chan error, 4)
+	for i := 1; i <= 4; i++ {
+		go func(i int) {
+			resp, err := q.SendDataCtx(ctx, i, &queue.SendOption{NeedResp: true})
+			if err == nil && resp != i * 2 {
+				err = errors.New("result not matched")
+			}
+			results <- err
+		}(i)
+	}
+	for i := 0; i < 4; i++ {
+		if err := <- results; err != nil {
+			t.Errorf("batch send failed, err:%v\n", err)
+			return
+		}
+	}
+	if size := <- batchSizes; size != 3 {
+		t.Errorf("first batch should be full, size:%v\n", size)
+		return
+	}
+	q.Quit()
+
+	//disk list batch consumer, failed batch retried with origin order
+	var (
+		failed bool
+		consumed []interface{}
+	)
+	done := make(chan bool, 1)
+	l, err := queue.NewDiskList(&queue.ListConf{DataPath: t.TempDir()})
+	if err != nil {
+		t.Errorf("new disk list failed, err:%v\n", err)
+		return
+	}
+	for i := 0; i < 5; i++ {
+		l.Push(int64(i))
+	}
+	l.SetBatchConsumer(func(data []interface{}) error {
+		if !failed {
+			failed = true
+			return errors.New("fail once")
+		}
+		consumed = append(consumed, data...)
+		if len(consumed) >= 5 {
+			done <- true
+		}
+		return nil
+	}, 2, 10 * time.Millisecond)
+	select {
+	case <- done:
+	case <- time.After(time.Second):
+		t.Errorf("list batch consume timeout, consumed:%v\n", consumed)
+		return
+	}
+	l.Quit()
+	for i, v := range consumed {
+		if v != int64(i) {
+			t.Errorf("list batch order invalid, consumed:%v\n", consumed)
+			return
+		}
+	}
+	t.Logf("test queue batch succeed, list consumed:%v\n", consumed)
+}
+
+//test typed queue without type assertion
+func TestTypedQueue(t *testing.T) {
+	q := queue.NewTypedQueue[int, string](10)
+	defer q.Quit()
+	q.SetCallback(func(data int) (string, error) {
+		return strings.Repeat("a", data), nil
+	})
+	resp, err := q.SendData(3, true)
+	if err != nil || resp != "aaa" {
+		t.Errorf("typed queue failed, resp:%v, err:%v\n", resp, err)
+		return
+	}
+	t.Logf("test typed queue succeed\n")
+}
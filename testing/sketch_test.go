@@ -0,0 +1,271 @@
+package testing
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/andyzhou/tinylib/algorithm"
+	"github.com/andyzhou/tinylib/util"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+//test bloom filter
+func TestBloomFilter(t *testing.T) {
+	f := algorithm.NewBloomFilter(10000, 0.01)
+	other := algorithm.NewBloomFilter(10000, 0.01)
+	for i := 0; i < 5000; i++ {
+		f.AddString(fmt.Sprintf("a-%d", i))
+		other.AddString(fmt.Sprintf("b-%d", i))
+	}
+	if !f.TestAndAdd([]byte("a-1")) || f.TestAndAdd([]byte("new")) || !f.TestString("new") {
+		t.Fatalf("bloom filter test and add failed")
+	}
+
+	//merge and false positive
+	if err := f.Merge(other); err != nil {
+		t.Fatalf("merge failed, err:%v", err)
+	}
+	falsePositive := 0
+	for i := 0; i < 10000; i++ {
+		if !f.TestString(fmt.Sprintf("a-%d", i%5000)) || !f.TestString(fmt.Sprintf("b-%d", i%5000)) {
+			t.Fatalf("added item not found after merge")
+		}
+		if f.TestString(fmt.Sprintf("c-%d", i)) {
+			falsePositive++
+		}
+	}
+	if rate := float64(falsePositive) / 10000; rate > 0.02 {
+		t.Fatalf("false positive rate too high:%v", rate)
+	}
+	if count := f.Count(); math.Abs(float64(count) - 10001) > 500 {
+		t.Fatalf("invalid estimate count:%v", count)
+	}
+	if err := f.Merge(algorithm.NewBloomFilter(100)); err != algorithm.ErrSketchMismatch {
+		t.Fatalf("merge different size should fail, err:%v", err)
+	}
+
+	//store by gob
+	loaded := &algorithm.BloomFilter{}
+	gobRoundTrip(t, "bloom.gob", f, loaded)
+	if !loaded.TestString("b-4999") || loaded.Count() != f.Count() {
+		t.Fatalf("invalid loaded filter")
+	}
+}
+
+//test cuckoo filter
+func TestCuckooFilter(t *testing.T) {
+	f := algorithm.NewCuckooFilter(10000)
+	for i := 0; i < 9000; i++ {
+		if !f.AddString(fmt.Sprintf("a-%d", i)) {
+			t.Fatalf("add item %v failed, load:%v", i, f.LoadFactor())
+		}
+	}
+	falsePositive := 0
+	for i := 0; i < 9000; i++ {
+		if !f.TestString(fmt.Sprintf("a-%d", i)) {
+			t.Fatalf("added item %v not found", i)
+		}
+		if f.TestString(fmt.Sprintf("c-%d", i)) {
+			falsePositive++
+		}
+	}
+	if falsePositive > 20 {
+		t.Fatalf("false positive too many:%v", falsePositive)
+	}
+
+	//delete
+	for i := 0; i < 4500; i++ {
+		if !f.DeleteString(fmt.Sprintf("a-%d", i)) {
+			t.Fatalf("delete item %v failed", i)
+		}
+	}
+	if f.Count() != 4500 || f.DeleteString("not-exists") {
+		t.Fatalf("invalid count after delete:%v", f.Count())
+	}
+	for i := 4500; i < 9000; i++ {
+		if !f.TestString(fmt.Sprintf("a-%d", i)) {
+			t.Fatalf("item %v lost after delete", i)
+		}
+	}
+
+	//merge and store
+	other := algorithm.NewCuckooFilter(10000)
+	other.AddString("b-1")
+	if ok, err := f.Merge(other); !ok || err != nil || !f.TestString("b-1") {
+		t.Fatalf("merge failed, ok:%v, err:%v", ok, err)
+	}
+	loaded := &algorithm.CuckooFilter{}
+	gobRoundTrip(t, "cuckoo.gob", f, loaded)
+	if !loaded.TestString("b-1") || !loaded.DeleteString("a-8999") || loaded.Count() != 4500 {
+		t.Fatalf("invalid loaded filter")
+	}
+}
+
+//test hyperloglog
+func TestHyperLogLog(t *testing.T) {
+	for _, num := range []int{100, 10000, 200000} {
+		a, b := algorithm.NewHyperLogLog(), algorithm.NewHyperLogLog()
+		for i := 0; i < num; i++ {
+			a.AddString(fmt.Sprintf("user-%d", i))
+			b.AddString(fmt.Sprintf("user-%d", i + num / 2))
+		}
+		if err := a.Merge(b); err != nil {
+			t.Fatalf("merge failed, err:%v", err)
+		}
+		expect := float64(num + num / 2)
+		if diff := math.Abs(float64(a.Count()) - expect) / expect; diff > 0.03 {
+			t.Fatalf("estimate of %v out of range, count:%v", expect, a.Count())
+		}
+		loaded := &algorithm.HyperLogLog{}
+		gobRoundTrip(t, "hll.gob", a, loaded)
+		if loaded.Count() != a.Count() {
+			t.Fatalf("invalid loaded estimator")
+		}
+	}
+	if err := algorithm.NewHyperLogLog(10).Merge(algorithm.NewHyperLogLog(12)); err != algorithm.ErrSketchMismatch {
+		t.Fatalf("merge different precision should fail, err:%v", err)
+	}
+}
+
+//test count-min sketch
+func TestCountMinSketch(t *testing.T) {
+	a := algorithm.NewCountMinSketch(0.001, 0.01, 3)
+	b := algorithm.NewCountMinSketch(0.001, 0.01, 3)
+	for i := 0; i < 10000; i++ {
+		a.AddString(fmt.Sprintf("key-%d", i%1000), 1)
+		b.AddString(fmt.Sprintf("key-%d", i%500), 1)
+	}
+	a.AddString("hot-1", 500)
+	b.AddString("hot-2", 400)
+	b.AddString("hot-3", 300)
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("merge failed, err:%v", err)
+	}
+	if got := a.EstimateString("key-1"); got < 30 || got > 30 + uint64(0.001 * float64(a.Total())) {
+		t.Fatalf("invalid estimate:%v", got)
+	}
+	top := a.TopK()
+	if len(top) != 3 || top[0].Key != "hot-1" || top[1].Key != "hot-2" || top[2].Key != "hot-3" {
+		t.Fatalf("invalid heavy hitters:%v", top)
+	}
+	loaded := &algorithm.CountMinSketch{}
+	gobRoundTrip(t, "countmin.gob", a, loaded)
+	if loaded.EstimateString("hot-2") != a.EstimateString("hot-2") || len(loaded.TopK()) != 3 {
+		t.Fatalf("invalid loaded sketch")
+	}
+}
+
+//sketch able to decode from binary
+type binarySketch interface {
+	MarshalBinary() ([]byte, error)
+	UnmarshalBinary(data []byte) error
+}
+
+//test corrupt sketch data rejected, decoded sketch always usable
+func TestSketchCorrupt(t *testing.T) {
+	header := func(magic string, values ...uint64) []byte {
+		data := append([]byte(magic), 1)
+		for _, v := range values {
+			data = binary.AppendUvarint(data, v)
+		}
+		return data
+	}
+	cases := map[string]struct {
+		sketch binarySketch
+		data   []byte
+	}{
+		"bloom overflow words": {&algorithm.BloomFilter{}, header("TLBF", math.MaxUint64, 1, 0, 0)},
+		"cuckoo overflow slots": {&algorithm.CuckooFilter{}, header("TLCF", 1 << 62, 0, 0, 0)},
+		"countmin overflow counters": {&algorithm.CountMinSketch{}, header("TLCM", 1 << 32, 1 << 32, 0, 0, 0, 0)},
+	}
+	for name, c := range cases {
+		if err := c.sketch.UnmarshalBinary(c.data); err == nil {
+			t.Fatalf("%v accepted", name)
+		}
+	}
+
+	//truncated or flipped valid data, never panic after decoded
+	bloom := algorithm.NewBloomFilter(100)
+	cuckoo := algorithm.NewCuckooFilter(100)
+	countMin := algorithm.NewCountMinSketch(0.01, 0.01, 3)
+	hll := algorithm.NewHyperLogLog()
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		bloom.AddString(key)
+		cuckoo.AddString(key)
+		countMin.AddString(key, 1)
+		hll.AddString(key)
+	}
+	creators := []func() binarySketch{
+		func() binarySketch { return &algorithm.BloomFilter{} },
+		func() binarySketch { return &algorithm.CuckooFilter{} },
+		func() binarySketch { return &algorithm.CountMinSketch{} },
+		func() binarySketch { return &algorithm.HyperLogLog{} },
+	}
+	for idx, sketch := range []binarySketch{bloom, cuckoo, countMin, hll} {
+		data, _ := sketch.MarshalBinary()
+		for i := 0; i < len(data); i++ {
+			for _, corrupt := range [][]byte{
+				data[:i],
+				append(append(append([]byte{}, data[:i]...), data[i] ^ 0xff), data[i+1:]...),
+			} {
+				decoded := creators[idx]()
+				if decoded.UnmarshalBinary(corrupt) != nil {
+					continue
+				}
+				switch v := decoded.(type) {
+				case *algorithm.BloomFilter:
+					v.AddString("x")
+					v.TestString("y")
+				case *algorithm.CuckooFilter:
+					v.AddString("x")
+					v.TestString("y")
+				case *algorithm.CountMinSketch:
+					v.AddString("x", 1)
+					v.EstimateString("y")
+				case *algorithm.HyperLogLog:
+					v.AddString("x")
+					v.Count()
+				}
+			}
+		}
+	}
+}
+
+//test concurrent cross merge without deadlock
+func TestSketchCrossMerge(t *testing.T) {
+	bloomA, bloomB := algorithm.NewBloomFilter(1000), algorithm.NewBloomFilter(1000)
+	cuckooA, cuckooB := algorithm.NewCuckooFilter(1000), algorithm.NewCuckooFilter(1000)
+	done := make(chan bool)
+	go func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 200; i++ {
+			wg.Add(4)
+			go func() { defer wg.Done(); bloomA.Merge(bloomB) }()
+			go func() { defer wg.Done(); bloomB.Merge(bloomA) }()
+			go func() { defer wg.Done(); cuckooA.Merge(cuckooB) }()
+			go func() { defer wg.Done(); cuckooB.Merge(cuckooA) }()
+		}
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <- done:
+	case <- time.After(5 * time.Second):
+		t.Fatal("cross merge deadlocked")
+	}
+}
+
+//store and load by gob
+func gobRoundTrip(t *testing.T, fileName string, in, out interface{}) {
+	g := util.NewGob()
+	g.SetRootPath(t.TempDir())
+	if err := g.Store(fileName, in); err != nil {
+		t.Fatalf("store %v failed, err:%v", fileName, err)
+	}
+	if err := g.Load(fileName, out); err != nil {
+		t.Fatalf("load %v failed, err:%v", fileName, err)
+	}
+}
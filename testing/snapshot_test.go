@@ -0,0 +1,128 @@
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/andyzhou/tinylib/algorithm"
+	"hash/crc32"
+	"testing"
+)
+
+//snapshot able balancer
+type snapshotBalancer interface {
+	algorithm.Balancer
+	Snapshot() *algorithm.RingSnapshot
+	Restore(snap *algorithm.RingSnapshot) error
+}
+
+//test snapshot export, import and diff
+func TestRingSnapshot(t *testing.T) {
+	creators := map[string]func() snapshotBalancer{
+		"HashRing": func() snapshotBalancer {
+			return algorithm.NewHashRing(algorithm.DefaultRingReplicas, nil)
+		},
+		"Consistent": func() snapshotBalancer {
+			return algorithm.NewConsistent(nil, algorithm.Config{
+				PartitionCount: 271,
+				Hasher: algorithm.MyHasher{},
+			})
+		},
+		"XConsistent": func() snapshotBalancer {
+			return algorithm.NewXConsistent()
+		},
+	}
+	for name, creator := range creators {
+		t.Run(name, func(t *testing.T) {
+			runRingSnapshot(t, creator)
+		})
+	}
+}
+
+//snapshot suite
+func runRingSnapshot(t *testing.T, creator func() snapshotBalancer) {
+	keys := make([]string, 2000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	src := creator()
+	src.AddMember("node-a")
+	src.AddMember("node-b", 2)
+	src.AddMember("node-c")
+	before := src.Snapshot()
+
+	//binary and json round trip
+	binData, err := before.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal binary failed, err:%v", err)
+	}
+	jsonData, err := json.Marshal(before)
+	if err != nil {
+		t.Fatalf("marshal json failed, err:%v", err)
+	}
+	for _, data := range [][]byte{binData, jsonData} {
+		snap, subErr := algorithm.ParseRingSnapshot(data)
+		if subErr != nil {
+			t.Fatalf("parse snapshot failed, err:%v", subErr)
+		}
+		dst := creator()
+		if subErr = dst.Restore(snap); subErr != nil {
+			t.Fatalf("restore snapshot failed, err:%v", subErr)
+		}
+		for _, key := range keys {
+			org, _ := src.Locate(key)
+			got, _ := dst.Locate(key)
+			if org != got {
+				t.Fatalf("owner of %v mismatch after restore, %v != %v", key, org, got)
+			}
+		}
+		if diff, _ := algorithm.DiffRingSnapshot(before, dst.Snapshot()); !diff.IsEmpty() {
+			t.Fatalf("restored snapshot should be same, diff:%+v", diff)
+		}
+	}
+	t.Logf("snapshot size, binary:%v, json:%v", len(binData), len(jsonData))
+
+	//broken data
+	binData[len(binData)/2]++
+	if _, err = algorithm.ParseRingSnapshot(binData); err == nil {
+		t.Fatalf("broken snapshot should be rejected")
+	}
+
+	//diff after add member covers all moved keys
+	owners := map[string]string{}
+	for _, key := range keys {
+		owners[key], _ = src.Locate(key)
+	}
+	src.AddMember("node-d")
+	diff, err := algorithm.DiffRingSnapshot(before, src.Snapshot())
+	if err != nil || len(diff.Added) != 1 || diff.Added[0] != "node-d" {
+		t.Fatalf("invalid diff:%+v, err:%v", diff, err)
+	}
+	for _, key := range keys {
+		owner, _ := src.Locate(key)
+		from, to, moved := movedByDiff(diff, key)
+		if moved != (owner != owners[key]) || (moved && (from != owners[key] || to != owner)) {
+			t.Fatalf("diff of %v mismatch, owner:%v -> %v, diff:%v -> %v",
+				key, owners[key], owner, from, to)
+		}
+	}
+}
+
+//check key moved by diff
+func movedByDiff(diff *algorithm.RingDiff, key string) (string, string, bool) {
+	if diff.Kind == algorithm.SnapshotKindConsistent {
+		partID := int(algorithm.MyHasher{}.Sum64([]byte(key)) % 271)
+		for _, move := range diff.Partitions {
+			if move.PartitionID == partID {
+				return move.From, move.To, true
+			}
+		}
+		return "", "", false
+	}
+	hash := uint64(crc32.ChecksumIEEE([]byte(key)))
+	for _, move := range diff.Ranges {
+		if hash >= move.Start && hash <= move.End {
+			return move.From, move.To, true
+		}
+	}
+	return "", "", false
+}
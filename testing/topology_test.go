@@ -0,0 +1,147 @@
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/andyzhou/tinylib/algorithm"
+	"math"
+	"testing"
+)
+
+//test replica placement across failure domains
+func TestConsistentReplicas(t *testing.T) {
+	c := algorithm.NewConsistent(nil, algorithm.Config{
+		PartitionCount: 271,
+		Hasher: algorithm.MyHasher{},
+	})
+	for i := 0; i < 6; i++ {
+		zone := fmt.Sprintf("zone-%d", i%3)
+		c.Add(algorithm.NewTopologyNode(fmt.Sprintf("node-%d", i), zone, "rack-1", fmt.Sprintf("host-%d", i)))
+	}
+	if _, err := c.ReplicaTable(7); err != algorithm.ErrInsufficientMemberCount {
+		t.Fatalf("replicas more than members should fail, err:%v", err)
+	}
+
+	//every partition spread across zones, replica count bounded
+	table, err := c.ReplicaTable(3)
+	if err != nil {
+		t.Fatalf("get replica table failed, err:%v", err)
+	}
+	loads := map[string]float64{}
+	for partID := 0; partID < 271; partID++ {
+		replicas := table[partID]
+		if len(replicas) != 3 || replicas[0].String() != c.GetPartitionOwner(partID).String() {
+			t.Fatalf("invalid replicas of partition %v:%v", partID, replicas)
+		}
+		zones := map[string]bool{}
+		for _, replica := range replicas {
+			zones[replica.(algorithm.TopologyMember).Topology().Zone] = true
+			loads[replica.String()]++
+		}
+		if len(zones) != 3 {
+			t.Fatalf("replicas of partition %v not spread:%v", partID, replicas)
+		}
+	}
+	bound := math.Ceil(271 * 3 / 6.0 * algorithm.DefaultLoad)
+	for name, load := range loads {
+		if load > bound {
+			t.Fatalf("replica load of %v over bound, %v > %v", name, load, bound)
+		}
+	}
+	replicas, err := c.GetReplicas([]byte("key"), 3)
+	if err != nil || replicas[0].String() != c.LocateKey([]byte("key")).String() {
+		t.Fatalf("invalid replicas of key:%v, err:%v", replicas, err)
+	}
+
+	//rebalance plan matches real change
+	owners := func() []string {
+		result := make([]string, 271)
+		for partID := range result {
+			result[partID] = c.GetPartitionOwner(partID).String()
+		}
+		return result
+	}
+	checkPlan := func(plan *algorithm.RebalancePlan, before []string) {
+		after := owners()
+		moved := 0
+		for partID := range after {
+			if after[partID] != before[partID] {
+				moved++
+			}
+		}
+		if moved != len(plan.Moves) {
+			t.Fatalf("plan moves %v, real moves %v", len(plan.Moves), moved)
+		}
+		for _, move := range plan.Moves {
+			if before[move.PartitionID] != move.From || after[move.PartitionID] != move.To {
+				t.Fatalf("invalid plan move:%+v", move)
+			}
+		}
+	}
+	newNode := algorithm.NewTopologyNode("node-6", "zone-0", "rack-2", "host-6")
+	plan, err := c.PlanAdd(newNode)
+	if err != nil || len(plan.Moves) == 0 {
+		t.Fatalf("plan add failed, plan:%+v, err:%v", plan, err)
+	}
+	before := owners()
+	c.Add(newNode)
+	checkPlan(plan, before)
+
+	plan, err = c.PlanRemove("node-2")
+	if err != nil {
+		t.Fatalf("plan remove failed, err:%v", err)
+	}
+	before = owners()
+	c.Remove("node-2")
+	checkPlan(plan, before)
+	if _, err = c.PlanRemove("node-2"); err != algorithm.ErrMemberNotFound {
+		t.Fatalf("plan remove unknown member should fail, err:%v", err)
+	}
+}
+
+//test replica placement kept after snapshot restore
+func TestConsistentReplicaSnapshot(t *testing.T) {
+	creator := func() *algorithm.Consistent {
+		return algorithm.NewConsistent(nil, algorithm.Config{
+			PartitionCount: 271,
+			Hasher: algorithm.MyHasher{},
+		})
+	}
+	c := creator()
+	for i := 0; i < 6; i++ {
+		zone := fmt.Sprintf("zone-%d", i%3)
+		c.Add(algorithm.NewTopologyNode(fmt.Sprintf("node-%d", i), zone, "rack-1", fmt.Sprintf("host-%d", i)))
+	}
+	c.Add(algorithm.NewNode("plain"))
+	replicas := func(c *algorithm.Consistent) string {
+		table, err := c.ReplicaTable(3)
+		if err != nil {
+			t.Fatalf("get replica table failed, err:%v", err)
+		}
+		return fmt.Sprint(table)
+	}
+	expected := replicas(c)
+
+	//binary and json round trip
+	binData, err := c.Snapshot().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonData, err := json.Marshal(c.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]byte{binData, jsonData} {
+		snap, err := algorithm.ParseRingSnapshot(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		restored := creator()
+		if err = restored.Restore(snap); err != nil {
+			t.Fatal(err)
+		}
+		if replicas(restored) != expected {
+			t.Fatalf("replica table changed after restore")
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -106,4 +107,126 @@ func BenchmarkBindObj(b *testing.B) {
 		}
 	}
 	b.Logf("benchmark bind obj, succeed:%v, failed:%v\n", succeed, failed)
-}
\ No newline at end of file
+}
+
+//test worker hashed routing and resizing
+func TestWorkerResize(t *testing.T) {
+	w := queue.NewWorker()
+	defer w.Quit()
+	w.CreateWorkers(3)
+
+	//same obj id always routed to same son worker
+	objOwners := map[int64]int32{}
+	for objId := int64(1); objId <= 100; objId++ {
+		sw, err := w.GetTargetWorker(objId)
+		if err != nil {
+			t.Errorf("get target worker failed, err:%v\n", err)
+			return
+		}
+		sw2, _ := w.GetTargetWorker(objId, true)
+		if sw != sw2 {
+			t.Errorf("route not deterministic, objId:%v\n", objId)
+			return
+		}
+		w.UpdateBindObj(objId, objId)
+	}
+	for objId := int64(1); objId <= 100; objId++ {
+		sw, _ := w.GetTargetWorker(objId)
+		objOwners[objId] = sw.GetWorkerId()
+	}
+
+	//add and remove workers, bind objs migrated
+	w.AddWorkers(2)
+	moved := 0
+	for objId := int64(1); objId <= 100; objId++ {
+		obj, err := w.GetBindObj(objId)
+		if err != nil || obj != objId {
+			t.Errorf("bind obj lost after add, objId:%v, err:%v\n", objId, err)
+			return
+		}
+		sw, _ := w.GetTargetWorker(objId)
+		if sw.GetWorkerId() != objOwners[objId] {
+			moved++
+		}
+	}
+	//about 2/5 objs moved to new workers, modulo routing moves about 4/5
+	if moved > 100 * 2 / 5 + 15 {
+		t.Errorf("too many bind objs moved:%v\n", moved)
+		return
+	}
+	w.RemoveWorkers(1, 2)
+	for objId := int64(1); objId <= 100; objId++ {
+		obj, err := w.GetBindObj(objId)
+		if err != nil || obj != objId {
+			t.Errorf("bind obj lost after remove, objId:%v, err:%v\n", objId, err)
+			return
+		}
+	}
+	if w.GetWorkers() != 3 {
+		t.Errorf("invalid workers:%v\n", w.GetWorkers())
+		return
+	}
+	t.Logf("test worker resize succeed, moved:%v\n", moved)
+}
+
+//test bind obj ticker while migrating
+func TestWorkerResizeTicker(t *testing.T) {
+	var ticks int32
+	w := queue.NewTypedWorker[int64, int64]()
+	defer w.Quit()
+	w.SetCBForBindObjTickerOpt(func(workerId int32, objs map[int64]int64) error {
+		for k, v := range objs {
+			if k != v {
+				return errors.New("invalid bind obj")
+			}
+		}
+		atomic.AddInt32(&ticks, 1)
+		return nil
+	})
+	w.CreateWorkers(3, 0.001)
+	for objId := int64(1); objId <= 100; objId++ {
+		w.UpdateBindObj(objId, objId)
+	}
+
+	//resize while ticker reading bind objs
+	for i := 0; i < 20; i++ {
+		w.AddWorkers(1, 0.001)
+		time.Sleep(time.Millisecond)
+		w.RemoveWorkers(int32(i + 1))
+	}
+	if atomic.LoadInt32(&ticks) <= 0 {
+		t.Errorf("bind obj ticker not run\n")
+		return
+	}
+	for objId := int64(1); objId <= 100; objId++ {
+		if obj, err := w.GetBindObj(objId); err != nil || obj != objId {
+			t.Errorf("bind obj lost, objId:%v, err:%v\n", objId, err)
+			return
+		}
+	}
+}
+
+//test typed worker with string obj id
+func TestTypedWorker(t *testing.T) {
+	w := queue.NewTypedWorker[string, []int]()
+	defer w.Quit()
+	w.CreateWorkers(3)
+	if err := w.UpdateBindObj("", []int{0}); err == nil {
+		t.Errorf("zero obj id should be rejected\n")
+		return
+	}
+	for _, objId := range []string{"a", "b", "c"} {
+		w.UpdateBindObj(objId, []int{len(objId)})
+	}
+	obj, err := w.GetBindObj("b")
+	if err != nil || len(obj) != 1 || obj[0] != 1 {
+		t.Errorf("get typed bind obj failed, obj:%v, err:%v\n", obj, err)
+		return
+	}
+	sw, _ := w.GetTargetWorker("b")
+	if objs := sw.GetAllBindObjs(); len(objs["b"]) != 1 {
+		t.Errorf("bind obj not in target worker, objs:%v\n", objs)
+		return
+	}
+	t.Logf("test typed worker succeed\n")
+}
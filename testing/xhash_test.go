@@ -65,4 +65,24 @@ func getStandardDeviation(list []float64) float64 {
 		dTotal += dValue * dValue
 	}
 	return math.Sqrt(dTotal / avg)
-}
\ No newline at end of file
+}
+
+//test collided virtual node kept by its owner after other node removed
+func TestXConsistentCollision(t *testing.T) {
+	//virtual node `a` + `10` collides with `a1` + `0`
+	consistentHash := algorithm.NewXConsistent()
+	consistentHash.Add("a", 11)
+	consistentHash.Add("a1", 1)
+	if points := len(consistentHash.Snapshot().Points); points != 11 {
+		t.Fatalf("collided virtual node duplicated, points:%v", points)
+	}
+	consistentHash.Remove("a1")
+	if points := len(consistentHash.Snapshot().Points); points != 11 {
+		t.Fatalf("collided virtual node of owner removed, points:%v", points)
+	}
+	for i := 0; i < 1000; i++ {
+		if node := consistentHash.GetNode("key" + strconv.Itoa(i)); node != "a" {
+			t.Fatalf("invalid node of key%v:%v", i, node)
+		}
+	}
+}
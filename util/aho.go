@@ -0,0 +1,271 @@
+package util
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+/*
+ * aho-corasick multi pattern matcher
+ * - compact automaton, transitions of all states in flat sorted arrays
+ * - linear scanning, return all matches with word id and category
+ * - skip runes ignored when scanning, like `f*u*ck`
+ * - immutable after built, safe for concurrent scanning and atomic swap
+ */
+
+//dictionary word
+type AcWord struct {
+	Word     string
+	Id       int
+	Category string
+}
+
+//single match, byte offsets in text, end excluded
+type AcMatch struct {
+	AcWord
+	Start int
+	End   int
+}
+
+//face info
+type AhoCorasick struct {
+	base     []int32 //transitions of state i in [base[i], base[i+1])
+	labels   []rune  //transition runes, sorted per state
+	nexts    []int32 //transition target states
+	fail     []int32
+	dictLink []int32 //nearest fail state with outputs, -1 if none
+	outBase  []int32 //outputs of state i in [outBase[i], outBase[i+1])
+	outs     []int32 //word indexes
+	runeLens []int32 //matched rune length of word
+	words    []AcWord
+	skip     map[rune]struct{}
+}
+
+//build automaton, empty words ignored
+func NewAhoCorasick(words []AcWord, skipRunes ...rune) *AhoCorasick {
+	var (
+		children = []map[rune]int32{{}}
+		outputs  = [][]int32{nil}
+	)
+	this := &AhoCorasick{
+		skip: map[rune]struct{}{},
+	}
+	for _, r := range skipRunes {
+		this.skip[r] = struct{}{}
+	}
+
+	//build trie, skip runes removed from words
+	for _, word := range words {
+		state, runeLen := int32(0), int32(0)
+		for _, r := range word.Word {
+			if _, ok := this.skip[r]; ok {
+				continue
+			}
+			next, ok := children[state][r]
+			if !ok {
+				next = int32(len(children))
+				children[state][r] = next
+				children = append(children, map[rune]int32{})
+				outputs = append(outputs, nil)
+			}
+			state = next
+			runeLen++
+		}
+		if runeLen <= 0 {
+			continue
+		}
+		outputs[state] = append(outputs[state], int32(len(this.words)))
+		this.words = append(this.words, word)
+		this.runeLens = append(this.runeLens, runeLen)
+	}
+
+	//compact transitions and outputs
+	total := len(children)
+	this.base = make([]int32, total + 1)
+	this.outBase = make([]int32, total + 1)
+	for state, edges := range children {
+		runes := make([]rune, 0, len(edges))
+		for r := range edges {
+			runes = append(runes, r)
+		}
+		sort.Slice(runes, func(i, j int) bool {
+			return runes[i] < runes[j]
+		})
+		for _, r := range runes {
+			this.labels = append(this.labels, r)
+			this.nexts = append(this.nexts, edges[r])
+		}
+		this.outs = append(this.outs, outputs[state]...)
+		this.base[state + 1] = int32(len(this.labels))
+		this.outBase[state + 1] = int32(len(this.outs))
+	}
+
+	//fail and dictionary links by bfs
+	this.fail = make([]int32, total)
+	this.dictLink = make([]int32, total)
+	this.dictLink[0] = -1
+	queue := make([]int32, 0, total)
+	for i := this.base[0]; i < this.base[1]; i++ {
+		this.dictLink[this.nexts[i]] = -1
+		queue = append(queue, this.nexts[i])
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for i := this.base[state]; i < this.base[state + 1]; i++ {
+			r, child := this.labels[i], this.nexts[i]
+			f := this.fail[state]
+			next := this.next(f, r)
+			for next < 0 && f != 0 {
+				f = this.fail[f]
+				next = this.next(f, r)
+			}
+			if next < 0 {
+				next = 0
+			}
+			this.fail[child] = next
+			if this.outBase[next + 1] > this.outBase[next] {
+				this.dictLink[child] = next
+			}else{
+				this.dictLink[child] = this.dictLink[next]
+			}
+			queue = append(queue, child)
+		}
+	}
+	return this
+}
+
+//find all matches, ordered by end position
+func (f *AhoCorasick) FindAll(text string) []AcMatch {
+	var (
+		matches []AcMatch
+	)
+	f.scan(text, func(word int32, start, end int) bool {
+		matches = append(matches, AcMatch{
+			AcWord: f.words[word],
+			Start: start,
+			End: end,
+		})
+		return true
+	})
+	return matches
+}
+
+//check text contains any word or not
+func (f *AhoCorasick) Contains(text string) bool {
+	found := false
+	f.scan(text, func(word int32, start, end int) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+//replace all runes of matches with mask
+func (f *AhoCorasick) Replace(text string, mask rune) (bool, string) {
+	var (
+		marks []int32 //difference array of covered bytes
+	)
+	f.scan(text, func(word int32, start, end int) bool {
+		if marks == nil {
+			marks = make([]int32, len(text) + 1)
+		}
+		marks[start]++
+		marks[end]--
+		return true
+	})
+	if marks == nil {
+		return false, text
+	}
+	runes := make([]rune, 0, len(text))
+	covered := int32(0)
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		for j := i; j < i + size; j++ {
+			covered += marks[j]
+		}
+		if covered > 0 {
+			r = mask
+		}
+		runes = append(runes, r)
+		i += size
+	}
+	return true, string(runes)
+}
+
+//get words count
+func (f *AhoCorasick) Size() int {
+	return len(f.words)
+}
+
+//get states count
+func (f *AhoCorasick) States() int {
+	return len(f.fail)
+}
+
+//////////////
+//private func
+//////////////
+
+//scan text, stop when callback returns false
+func (f *AhoCorasick) scan(text string, cb func(word int32, start, end int) bool) {
+	var (
+		state  int32
+		starts []int //byte offset of each scanned rune
+	)
+	if len(f.words) <= 0 {
+		return
+	}
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		pos := i
+		i += size
+		if _, ok := f.skip[r]; ok {
+			continue
+		}
+		starts = append(starts, pos)
+
+		//goto or fail
+		next := f.next(state, r)
+		for next < 0 && state != 0 {
+			state = f.fail[state]
+			next = f.next(state, r)
+		}
+		if next < 0 {
+			next = 0
+		}
+		state = next
+
+		//outputs of state and its dictionary links
+		out := state
+		if f.outBase[out + 1] <= f.outBase[out] {
+			out = f.dictLink[out]
+		}
+		for ; out > 0; out = f.dictLink[out] {
+			for j := f.outBase[out]; j < f.outBase[out + 1]; j++ {
+				word := f.outs[j]
+				start := starts[len(starts) - int(f.runeLens[word])]
+				if !cb(word, start, i) {
+					return
+				}
+			}
+		}
+	}
+}
+
+//get next state by rune, -1 if none
+func (f *AhoCorasick) next(state int32, r rune) int32 {
+	low, high := f.base[state], f.base[state + 1]
+	for low < high {
+		mid := int32(uint32(low + high) >> 1)
+		if f.labels[mid] < r {
+			low = mid + 1
+		}else{
+			high = mid
+		}
+	}
+	if low < f.base[state + 1] && f.labels[low] == r {
+		return f.nexts[low]
+	}
+	return -1
+}
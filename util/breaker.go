@@ -0,0 +1,446 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+ * circuit breaker and bulkhead
+ * - breaker states: closed -> open -> half-open -> closed or open
+ * - trip by failure ratio or slow call ratio in rolling window
+ * - bulkhead limits concurrent calls, wait up to max wait
+ * - usable standalone, like wrapping mysql or mongo calls
+ */
+
+//breaker state
+type BreakerState int32
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+//default setup
+const (
+	defaultBreakerWindow       = 10 * time.Second
+	defaultBreakerBuckets      = 10
+	defaultBreakerMinRequests  = 20
+	defaultBreakerFailureRatio = 0.5
+	defaultBreakerOpenTimeout  = 5 * time.Second
+)
+
+//inter error define
+var (
+	ErrBreakerOpen    = errors.New("circuit breaker is open")
+	ErrBreakerIgnored = errors.New("circuit breaker result ignored") //pass into done, like canceled by caller
+	ErrBulkheadFull   = errors.New("bulkhead is full")
+)
+
+//breaker config
+type BreakerConf struct {
+	Name             string        //used in state change hook
+	Window           time.Duration //rolling window of stats, default 10s
+	Buckets          int           //buckets of window, default 10
+	MinRequests      int           //min requests in window before trip, default 20
+	FailureRatio     float64       //trip when failures reach ratio, default 0.5
+	SlowCallDuration time.Duration //calls slower than it are slow, 0 means disabled
+	SlowCallRatio    float64       //trip when slow calls reach ratio, 0 means disabled
+	OpenTimeout      time.Duration //open to half-open after it, default 5s
+	HalfOpenRequests int           //probe calls of half-open, all success to close, default 1
+	IsFailure        func(err error) bool //default err != nil
+	OnStateChange    func(name string, from, to BreakerState)
+}
+
+//breaker counts in window
+type BreakerCounts struct {
+	Requests int
+	Failures int
+	Slow     int
+}
+
+//single bucket of window
+type breakerBucket struct {
+	epoch int64 //bucket sequence since unix
+	BreakerCounts
+}
+
+//face info
+type Breaker struct {
+	conf       BreakerConf
+	bucketSize time.Duration
+	buckets    []breakerBucket
+	state      BreakerState
+	generation uint64    //increased on state change
+	openedAt   time.Time //time of last open
+	probing    int       //running probes of half-open
+	probed     int       //succeed probes of half-open
+	timer      atomic.Pointer[Time]
+	sync.Mutex
+}
+
+//bulkhead face
+type Bulkhead struct {
+	slots   chan struct{}
+	maxWait time.Duration
+}
+
+//construct breaker
+func NewBreaker(confs ...*BreakerConf) *Breaker {
+	var (
+		conf BreakerConf
+	)
+	//use input config
+	if len(confs) > 0 && confs[0] != nil {
+		conf = *confs[0]
+	}
+	if conf.Window <= 0 {
+		conf.Window = defaultBreakerWindow
+	}
+	if conf.Buckets <= 0 {
+		conf.Buckets = defaultBreakerBuckets
+	}
+	if conf.MinRequests <= 0 {
+		conf.MinRequests = defaultBreakerMinRequests
+	}
+	if conf.FailureRatio <= 0 {
+		conf.FailureRatio = defaultBreakerFailureRatio
+	}
+	if conf.OpenTimeout <= 0 {
+		conf.OpenTimeout = defaultBreakerOpenTimeout
+	}
+	if conf.HalfOpenRequests <= 0 {
+		conf.HalfOpenRequests = 1
+	}
+	if conf.IsFailure == nil {
+		conf.IsFailure = func(err error) bool {
+			return err != nil
+		}
+	}
+
+	//self init
+	this := &Breaker{
+		conf: conf,
+		bucketSize: conf.Window / time.Duration(conf.Buckets),
+		buckets: make([]breakerBucket, conf.Buckets),
+	}
+	if this.bucketSize <= 0 {
+		this.bucketSize = time.Millisecond
+	}
+	return this
+}
+
+//set time, used for shifted clock
+func (b *Breaker) SetTime(t *Time) {
+	b.timer.Store(t)
+}
+
+//get name
+func (b *Breaker) Name() string {
+	return b.conf.Name
+}
+
+//get current state
+func (b *Breaker) State() BreakerState {
+	b.Lock()
+	from, state := b.currentState(b.now())
+	b.Unlock()
+	b.notify(from, state)
+	return state
+}
+
+//get counts of current window
+func (b *Breaker) Counts() BreakerCounts {
+	b.Lock()
+	defer b.Unlock()
+	return b.sumCounts(b.now())
+}
+
+//force into closed state and clear stats
+func (b *Breaker) Reset() {
+	b.Lock()
+	from, to := b.setState(BreakerClosed, b.now())
+	b.Unlock()
+	b.notify(from, to)
+}
+
+//check call allowed or not
+//done must be called with call result if allowed
+//done with ErrBreakerIgnored releases the call without recording
+func (b *Breaker) Allow() (func(err error), error) {
+	b.Lock()
+	from, state := b.currentState(b.now())
+	if state == BreakerOpen || (state == BreakerHalfOpen && b.probing >= b.conf.HalfOpenRequests) {
+		b.Unlock()
+		b.notify(from, state)
+		return nil, ErrBreakerOpen
+	}
+	if state == BreakerHalfOpen {
+		b.probing++
+	}
+	generation := b.generation
+	b.Unlock()
+	b.notify(from, state)
+
+	begin := time.Now()
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			b.record(generation, err, time.Since(begin))
+		})
+	}, nil
+}
+
+//run fn under breaker
+func (b *Breaker) Execute(fn func() error) error {
+	//check
+	if fn == nil {
+		return errors.New("invalid parameter")
+	}
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	var (
+		m any = nil
+	)
+	defer func() {
+		if subErr := recover(); subErr != m {
+			done(errors.New("panic"))
+			panic(subErr)
+		}
+	}()
+	err = fn()
+	done(err)
+	return err
+}
+
+//get state name
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+//construct bulkhead
+//maxWaits -> max wait for free slot, default not wait
+func NewBulkhead(maxConcurrent int, maxWaits ...time.Duration) *Bulkhead {
+	if maxConcurrent <= 0 {
+		maxConcurrent = 1
+	}
+	this := &Bulkhead{
+		slots: make(chan struct{}, maxConcurrent),
+	}
+	if len(maxWaits) > 0 && maxWaits[0] > 0 {
+		this.maxWait = maxWaits[0]
+	}
+	return this
+}
+
+//try acquire one slot without wait, Release must be called if succeed
+func (b *Bulkhead) TryAcquire() bool {
+	select {
+	case b.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+//acquire one slot, Release must be called if succeed
+func (b *Bulkhead) Acquire(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if b.TryAcquire() {
+		return nil
+	}
+	if b.maxWait <= 0 {
+		return ErrBulkheadFull
+	}
+	timer := time.NewTimer(b.maxWait)
+	defer timer.Stop()
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <- timer.C:
+		return ErrBulkheadFull
+	case <- ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//release one slot
+func (b *Bulkhead) Release() {
+	select {
+	case <- b.slots:
+	default:
+	}
+}
+
+//run fn in bulkhead
+func (b *Bulkhead) Execute(ctx context.Context, fn func() error) error {
+	//check
+	if fn == nil {
+		return errors.New("invalid parameter")
+	}
+	if err := b.Acquire(ctx); err != nil {
+		return err
+	}
+	defer b.Release()
+	return fn()
+}
+
+//get running calls count
+func (b *Bulkhead) InUse() int {
+	return len(b.slots)
+}
+
+//get max concurrent calls
+func (b *Bulkhead) Capacity() int {
+	return cap(b.slots)
+}
+
+//////////////
+//private func
+//////////////
+
+//record call result
+func (b *Breaker) record(generation uint64, err error, cost time.Duration) {
+	ignored := err == ErrBreakerIgnored
+	failed := !ignored && b.conf.IsFailure(err)
+	slow := !ignored && b.conf.SlowCallDuration > 0 && cost >= b.conf.SlowCallDuration
+
+	b.Lock()
+	now := b.now()
+	if generation != b.generation {
+		//started in other state, ignored
+		b.Unlock()
+		return
+	}
+	if ignored {
+		//release probe only
+		if b.state == BreakerHalfOpen {
+			b.probing--
+		}
+		b.Unlock()
+		return
+	}
+	var from, to BreakerState
+	switch b.state {
+	case BreakerHalfOpen:
+		b.probing--
+		if failed || slow {
+			from, to = b.setState(BreakerOpen, now)
+		}else{
+			b.probed++
+			if b.probed >= b.conf.HalfOpenRequests {
+				from, to = b.setState(BreakerClosed, now)
+			}
+		}
+	case BreakerClosed:
+		bucket := b.getBucket(now)
+		bucket.Requests++
+		if failed {
+			bucket.Failures++
+		}
+		if slow {
+			bucket.Slow++
+		}
+		if b.shouldTrip(now) {
+			from, to = b.setState(BreakerOpen, now)
+		}
+	}
+	b.Unlock()
+	b.notify(from, to)
+}
+
+//check window stats reach thresholds or not
+func (b *Breaker) shouldTrip(now time.Time) bool {
+	counts := b.sumCounts(now)
+	if counts.Requests < b.conf.MinRequests {
+		return false
+	}
+	if float64(counts.Failures) >= b.conf.FailureRatio * float64(counts.Requests) {
+		return true
+	}
+	return b.conf.SlowCallRatio > 0 &&
+		float64(counts.Slow) >= b.conf.SlowCallRatio * float64(counts.Requests)
+}
+
+//get state, open turns into half-open after timeout
+//return state before and after check, called within lock
+func (b *Breaker) currentState(now time.Time) (BreakerState, BreakerState) {
+	if b.state == BreakerOpen && now.Sub(b.openedAt) >= b.conf.OpenTimeout {
+		return b.setState(BreakerHalfOpen, now)
+	}
+	return b.state, b.state
+}
+
+//change state, called within lock
+func (b *Breaker) setState(state BreakerState, now time.Time) (BreakerState, BreakerState) {
+	from := b.state
+	b.state = state
+	b.generation++
+	b.probing, b.probed = 0, 0
+	if state == BreakerOpen {
+		b.openedAt = now
+	}
+	if state == BreakerClosed {
+		for i := range b.buckets {
+			b.buckets[i] = breakerBucket{}
+		}
+	}
+	return from, state
+}
+
+//run state change hook
+func (b *Breaker) notify(from, to BreakerState) {
+	if from == to || b.conf.OnStateChange == nil {
+		return
+	}
+	b.conf.OnStateChange(b.conf.Name, from, to)
+}
+
+//get bucket of time
+func (b *Breaker) getBucket(now time.Time) *breakerBucket {
+	epoch := now.UnixNano() / int64(b.bucketSize)
+	bucket := &b.buckets[epoch % int64(len(b.buckets))]
+	if bucket.epoch != epoch {
+		*bucket = breakerBucket{epoch: epoch}
+	}
+	return bucket
+}
+
+//sum counts of window
+func (b *Breaker) sumCounts(now time.Time) BreakerCounts {
+	var (
+		counts BreakerCounts
+	)
+	epoch := now.UnixNano() / int64(b.bucketSize)
+	for _, v := range b.buckets {
+		if v.epoch > epoch - int64(len(b.buckets)) && v.epoch <= epoch {
+			counts.Requests += v.Requests
+			counts.Failures += v.Failures
+			counts.Slow += v.Slow
+		}
+	}
+	return counts
+}
+
+//get current time
+func (b *Breaker) now() time.Time {
+	if timer := b.timer.Load(); timer != nil {
+		return timer.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,588 @@
+package util
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/cespare/xxhash"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+/*
+ * generic sharded cache face
+ * - lru, lfu or arc eviction inside each shard
+ * - per entry ttl, max entries and max bytes split evenly across shards
+ * - loader with singleflight, concurrent misses of one key load only once
+ * - eviction callback and hit/miss stats
+ * - front cache for read path, like mysql.JsonData or mongo.Connection
+ */
+
+//default setup
+const (
+	defaultCacheShardCount    = 16
+	defaultCacheCleanInterval = time.Minute
+)
+
+//evict reason define
+type CacheEvictReason int
+
+const (
+	CacheEvictCapacity CacheEvictReason = iota //evicted for max entries or max bytes
+	CacheEvictExpired                          //ttl reached
+	CacheEvictDeleted                          //deleted or purged
+	CacheEvictReplaced                         //value replaced by set
+)
+
+//loader of missed key
+type CacheLoader[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+//config
+type CacheConf[K comparable, V any] struct {
+	Policy        CachePolicy   //default CachePolicyLRU
+	ShardCount    uint          //default 16
+	MaxEntries    int           //max entries, 0 means no limit
+	MaxBytes      int64         //max bytes, 0 means no limit
+	TTL           time.Duration //default ttl, 0 means never expired
+	CleanInterval time.Duration //expired entries cleanup interval, default one minute
+	Sizer         func(key K, value V) int64 //entry size, default by string or bytes length
+	Hasher        func(key K) uint64         //shard hash, optional
+	Loader        CacheLoader[K, V]          //default loader, optional
+	OnEvict       func(key K, value V, reason CacheEvictReason)
+}
+
+//cache stats
+type CacheStats struct {
+	Hits        uint64
+	Misses      uint64
+	Loads       uint64
+	LoadErrors  uint64
+	Evictions   uint64
+	Expirations uint64
+	Entries     int
+	Bytes       int64
+}
+
+//single cache entry
+type cacheEntry[K comparable, V any] struct {
+	key      K
+	value    V
+	size     int64
+	expireAt int64 //unix nano, 0 means never expired
+	elem     *list.Element
+	bucket   *list.Element //lfu bucket
+	frequent bool          //in arc frequent list
+}
+
+//evicted entry for callback
+type cacheEvicted[K comparable, V any] struct {
+	key    K
+	value  V
+	reason CacheEvictReason
+}
+
+//single cache shard
+type cacheShard[K comparable, V any] struct {
+	items  map[K]*cacheEntry[K, V]
+	policy cachePolicy[K, V]
+	bytes  int64
+	sync.Mutex
+}
+
+//running load call
+type cacheCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+//face info
+type Cache[K comparable, V any] struct {
+	conf        CacheConf[K, V]
+	shards      []*cacheShard[K, V]
+	maxEntries  int   //per shard
+	maxBytes    int64 //per shard
+	timer       atomic.Pointer[Time]
+	calls       map[K]*cacheCall[V]
+	callLock    sync.Mutex
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	loads       atomic.Uint64
+	loadErrors  atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+	closeChan   chan struct{}
+	closeOnce   sync.Once
+}
+
+//construct
+func NewCache[K comparable, V any](configs ...*CacheConf[K, V]) *Cache[K, V] {
+	var (
+		conf CacheConf[K, V]
+	)
+	//use input config
+	if len(configs) > 0 && configs[0] != nil {
+		conf = *configs[0]
+	}
+	if conf.ShardCount <= 0 {
+		conf.ShardCount = defaultCacheShardCount
+	}
+	if conf.CleanInterval <= 0 {
+		conf.CleanInterval = defaultCacheCleanInterval
+	}
+
+	//self init
+	this := &Cache[K, V]{
+		conf: conf,
+		shards: make([]*cacheShard[K, V], conf.ShardCount),
+		calls: map[K]*cacheCall[V]{},
+		closeChan: make(chan struct{}),
+	}
+	if conf.MaxEntries > 0 {
+		this.maxEntries = (conf.MaxEntries + int(conf.ShardCount) - 1) / int(conf.ShardCount)
+	}
+	if conf.MaxBytes > 0 {
+		this.maxBytes = (conf.MaxBytes + int64(conf.ShardCount) - 1) / int64(conf.ShardCount)
+	}
+
+	//inter init
+	this.interInit()
+	return this
+}
+
+//quit, stop expired entries cleanup
+func (f *Cache[K, V]) Quit() {
+	f.closeOnce.Do(func() {
+		close(f.closeChan)
+	})
+}
+
+//set time, used for shifted clock
+func (f *Cache[K, V]) SetTime(t *Time) {
+	f.timer.Store(t)
+}
+
+//set key and value with default ttl
+//return false if entry larger than max bytes of shard
+func (f *Cache[K, V]) Set(key K, value V) bool {
+	return f.SetWithTTL(key, value, f.conf.TTL)
+}
+
+//set key and value with ttl, ttl <= 0 means never expired
+func (f *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) bool {
+	var (
+		evicted  []cacheEvicted[K, V]
+		expireAt int64
+	)
+	if ttl > 0 {
+		expireAt = f.now().Add(ttl).UnixNano()
+	}
+	size := f.sizeOf(key, value)
+	s := f.getShard(key)
+
+	s.Lock()
+	old := s.items[key]
+	if f.maxBytes > 0 && size > f.maxBytes {
+		//too large, never cached
+		if old != nil {
+			s.removeEntry(old, false)
+			evicted = append(evicted, cacheEvicted[K, V]{key, old.value, CacheEvictReplaced})
+		}
+		s.Unlock()
+		f.notify(evicted)
+		return false
+	}
+
+	//make room
+	for f.overflow(s, old, size) {
+		victim := s.policy.victim(key)
+		if victim == nil {
+			break
+		}
+		if victim == old {
+			s.removeEntry(old, false)
+			evicted = append(evicted, cacheEvicted[K, V]{key, old.value, CacheEvictReplaced})
+			old = nil
+			continue
+		}
+		s.removeEntry(victim, true)
+		evicted = append(evicted, cacheEvicted[K, V]{victim.key, victim.value, CacheEvictCapacity})
+		f.evictions.Add(1)
+	}
+
+	//update or add entry
+	if old != nil {
+		evicted = append(evicted, cacheEvicted[K, V]{key, old.value, CacheEvictReplaced})
+		s.bytes += size - old.size
+		old.value, old.size, old.expireAt = value, size, expireAt
+		s.policy.access(old)
+	}else{
+		e := &cacheEntry[K, V]{
+			key: key,
+			value: value,
+			size: size,
+			expireAt: expireAt,
+		}
+		s.items[key] = e
+		s.bytes += size
+		s.policy.add(e)
+	}
+	s.Unlock()
+	f.notify(evicted)
+	return true
+}
+
+//get value of key
+func (f *Cache[K, V]) Get(key K) (V, bool) {
+	return f.get(key, true)
+}
+
+//get value of key, load by loader if missed
+//concurrent loads of the same key share one loader call
+func (f *Cache[K, V]) GetOrLoad(
+	ctx context.Context,
+	key K,
+	loaders ...CacheLoader[K, V]) (V, error) {
+	var (
+		zero V
+	)
+	//check cache
+	if v, ok := f.get(key, true); ok {
+		return v, nil
+	}
+	loader := f.conf.Loader
+	if len(loaders) > 0 && loaders[0] != nil {
+		loader = loaders[0]
+	}
+	if ctx == nil || loader == nil {
+		return zero, errors.New("invalid parameter")
+	}
+
+	//join running call
+	f.callLock.Lock()
+	if call, ok := f.calls[key]; ok {
+		f.callLock.Unlock()
+		select {
+		case <- call.done:
+			return call.value, call.err
+		case <- ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+	if v, ok := f.get(key, false); ok {
+		//loaded by call just finished
+		f.callLock.Unlock()
+		return v, nil
+	}
+	call := &cacheCall[V]{
+		done: make(chan struct{}),
+	}
+	f.calls[key] = call
+	f.callLock.Unlock()
+
+	//run loader
+	f.loads.Add(1)
+	call.value, call.err = f.runLoader(ctx, loader, key)
+	if call.err == nil {
+		f.Set(key, call.value)
+	}else{
+		f.loadErrors.Add(1)
+	}
+	f.callLock.Lock()
+	delete(f.calls, key)
+	f.callLock.Unlock()
+	close(call.done)
+	return call.value, call.err
+}
+
+//delete key
+func (f *Cache[K, V]) Delete(key K) bool {
+	s := f.getShard(key)
+	s.Lock()
+	e, ok := s.items[key]
+	if !ok {
+		s.Unlock()
+		return false
+	}
+	s.removeEntry(e, false)
+	s.Unlock()
+	f.notify([]cacheEvicted[K, V]{{key, e.value, CacheEvictDeleted}})
+	return true
+}
+
+//remove all entries
+func (f *Cache[K, V]) Purge() {
+	for _, s := range f.shards {
+		s.Lock()
+		evicted := make([]cacheEvicted[K, V], 0, len(s.items))
+		for k, e := range s.items {
+			evicted = append(evicted, cacheEvicted[K, V]{k, e.value, CacheEvictDeleted})
+		}
+		s.items = map[K]*cacheEntry[K, V]{}
+		s.bytes = 0
+		s.policy.reset()
+		s.Unlock()
+		f.notify(evicted)
+	}
+}
+
+//remove expired entries, return removed count
+func (f *Cache[K, V]) RemoveExpired() int {
+	var (
+		total int
+	)
+	now := f.now().UnixNano()
+	for _, s := range f.shards {
+		var evicted []cacheEvicted[K, V]
+		s.Lock()
+		for k, e := range s.items {
+			if e.expired(now) {
+				s.removeEntry(e, false)
+				evicted = append(evicted, cacheEvicted[K, V]{k, e.value, CacheEvictExpired})
+			}
+		}
+		s.Unlock()
+		f.expirations.Add(uint64(len(evicted)))
+		f.notify(evicted)
+		total += len(evicted)
+	}
+	return total
+}
+
+//get entries count, expired but not removed included
+func (f *Cache[K, V]) Len() int {
+	total := 0
+	for _, s := range f.shards {
+		s.Lock()
+		total += len(s.items)
+		s.Unlock()
+	}
+	return total
+}
+
+//get total bytes
+func (f *Cache[K, V]) Bytes() int64 {
+	total := int64(0)
+	for _, s := range f.shards {
+		s.Lock()
+		total += s.bytes
+		s.Unlock()
+	}
+	return total
+}
+
+//get stats
+func (f *Cache[K, V]) Stats() CacheStats {
+	stats := CacheStats{
+		Hits: f.hits.Load(),
+		Misses: f.misses.Load(),
+		Loads: f.loads.Load(),
+		LoadErrors: f.loadErrors.Load(),
+		Evictions: f.evictions.Load(),
+		Expirations: f.expirations.Load(),
+	}
+	for _, s := range f.shards {
+		s.Lock()
+		stats.Entries += len(s.items)
+		stats.Bytes += s.bytes
+		s.Unlock()
+	}
+	return stats
+}
+
+//get hit rate
+func (s CacheStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total <= 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+//////////////
+//private func
+//////////////
+
+//get value, stat hit and miss if needed
+func (f *Cache[K, V]) get(key K, stat bool) (V, bool) {
+	var (
+		zero V
+	)
+	s := f.getShard(key)
+	s.Lock()
+	e, ok := s.items[key]
+	if !ok {
+		s.Unlock()
+		if stat {
+			f.misses.Add(1)
+		}
+		return zero, false
+	}
+	if e.expired(f.now().UnixNano()) {
+		s.removeEntry(e, false)
+		s.Unlock()
+		f.expirations.Add(1)
+		if stat {
+			f.misses.Add(1)
+		}
+		f.notify([]cacheEvicted[K, V]{{key, e.value, CacheEvictExpired}})
+		return zero, false
+	}
+	s.policy.access(e)
+	value := e.value
+	s.Unlock()
+	if stat {
+		f.hits.Add(1)
+	}
+	return value, true
+}
+
+//check shard overflow with incoming entry
+func (f *Cache[K, V]) overflow(s *cacheShard[K, V], old *cacheEntry[K, V], size int64) bool {
+	entries, bytes := len(s.items), s.bytes + size
+	if old != nil {
+		bytes -= old.size
+	}else{
+		entries++
+	}
+	return (f.maxEntries > 0 && entries > f.maxEntries) ||
+		(f.maxBytes > 0 && bytes > f.maxBytes)
+}
+
+//run loader, panic converted into error
+func (f *Cache[K, V]) runLoader(
+	ctx context.Context,
+	loader CacheLoader[K, V],
+	key K) (value V, err error) {
+	defer func() {
+		if subErr := recover(); subErr != nil {
+			err = fmt.Errorf("cache loader panic, err:%v", subErr)
+		}
+	}()
+	return loader(ctx, key)
+}
+
+//run eviction callback
+func (f *Cache[K, V]) notify(evicted []cacheEvicted[K, V]) {
+	if f.conf.OnEvict == nil {
+		return
+	}
+	for _, v := range evicted {
+		f.conf.OnEvict(v.key, v.value, v.reason)
+	}
+}
+
+//get entry size
+func (f *Cache[K, V]) sizeOf(key K, value V) int64 {
+	if f.conf.Sizer != nil {
+		return f.conf.Sizer(key, value)
+	}
+	size := int64(0)
+	switch v := any(key).(type) {
+	case string:
+		size += int64(len(v))
+	case []byte:
+		size += int64(len(v))
+	default:
+		size += int64(unsafe.Sizeof(key))
+	}
+	switch v := any(value).(type) {
+	case string:
+		size += int64(len(v))
+	case []byte:
+		size += int64(len(v))
+	default:
+		size += int64(unsafe.Sizeof(value))
+	}
+	return size
+}
+
+//get current time
+func (f *Cache[K, V]) now() time.Time {
+	if timer := f.timer.Load(); timer != nil {
+		return timer.Now()
+	}
+	return time.Now()
+}
+
+//get target shard
+func (f *Cache[K, V]) getShard(key K) *cacheShard[K, V] {
+	var (
+		h uint64
+	)
+	if f.conf.Hasher != nil {
+		h = f.conf.Hasher(key)
+	}else{
+		switch v := any(key).(type) {
+		case string:
+			h = xxhash.Sum64String(v)
+		case int:
+			h = mixCacheHash(uint64(v))
+		case int64:
+			h = mixCacheHash(uint64(v))
+		case uint64:
+			h = mixCacheHash(v)
+		case int32:
+			h = mixCacheHash(uint64(v))
+		case uint32:
+			h = mixCacheHash(uint64(v))
+		default:
+			h = xxhash.Sum64String(fmt.Sprintf("%v", key))
+		}
+	}
+	return f.shards[h % uint64(len(f.shards))]
+}
+
+//cleanup expired entries
+func (f *Cache[K, V]) runCleanProcess() {
+	ticker := time.NewTicker(f.conf.CleanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <- f.closeChan:
+			return
+		case <- ticker.C:
+			f.RemoveExpired()
+		}
+	}
+}
+
+//inter init
+func (f *Cache[K, V]) interInit() {
+	//init shards
+	capacity := f.maxEntries
+	for i := range f.shards {
+		f.shards[i] = &cacheShard[K, V]{
+			items: map[K]*cacheEntry[K, V]{},
+			policy: newCachePolicy[K, V](f.conf.Policy, capacity),
+		}
+	}
+
+	//start cleanup process
+	go f.runCleanProcess()
+}
+
+//remove entry from shard
+func (s *cacheShard[K, V]) removeEntry(e *cacheEntry[K, V], evicted bool) {
+	delete(s.items, e.key)
+	s.bytes -= e.size
+	s.policy.remove(e, evicted)
+}
+
+//check entry expired or not
+func (e *cacheEntry[K, V]) expired(now int64) bool {
+	return e.expireAt > 0 && now >= e.expireAt
+}
+
+//mix integer key, splitmix64 finalizer
+func mixCacheHash(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
@@ -0,0 +1,317 @@
+package util
+
+import "container/list"
+
+/*
+ * eviction policies of cache shard
+ * - lru, least recently used, one list
+ * - lfu, least frequently used, o(1) frequency buckets, lru inside bucket
+ * - arc, adaptive replacement cache, recent and frequent lists with ghost keys
+ * - all policies called within shard lock
+ */
+
+//cache policy define
+type CachePolicy int
+
+const (
+	CachePolicyLRU CachePolicy = iota
+	CachePolicyLFU
+	CachePolicyARC
+)
+
+//policy of cache shard
+type cachePolicy[K comparable, V any] interface {
+	//add new entry, after room made
+	add(e *cacheEntry[K, V])
+	//entry accessed
+	access(e *cacheEntry[K, V])
+	//entry removed, evicted means removed for capacity
+	remove(e *cacheEntry[K, V], evicted bool)
+	//pick entry to evict for incoming key
+	victim(incoming K) *cacheEntry[K, V]
+	//drop all entries and history
+	reset()
+}
+
+//create policy
+func newCachePolicy[K comparable, V any](policy CachePolicy, capacity int) cachePolicy[K, V] {
+	switch policy {
+	case CachePolicyLFU:
+		return newLFUPolicy[K, V]()
+	case CachePolicyARC:
+		return newARCPolicy[K, V](capacity)
+	default:
+		return newLRUPolicy[K, V]()
+	}
+}
+
+////////////
+//lru policy
+////////////
+
+type lruPolicy[K comparable, V any] struct {
+	entries *list.List //front is the most recent
+}
+
+//construct
+func newLRUPolicy[K comparable, V any]() *lruPolicy[K, V] {
+	return &lruPolicy[K, V]{
+		entries: list.New(),
+	}
+}
+
+func (f *lruPolicy[K, V]) add(e *cacheEntry[K, V]) {
+	e.elem = f.entries.PushFront(e)
+}
+
+func (f *lruPolicy[K, V]) access(e *cacheEntry[K, V]) {
+	f.entries.MoveToFront(e.elem)
+}
+
+func (f *lruPolicy[K, V]) remove(e *cacheEntry[K, V], evicted bool) {
+	f.entries.Remove(e.elem)
+	e.elem = nil
+}
+
+func (f *lruPolicy[K, V]) victim(incoming K) *cacheEntry[K, V] {
+	if back := f.entries.Back(); back != nil {
+		return back.Value.(*cacheEntry[K, V])
+	}
+	return nil
+}
+
+func (f *lruPolicy[K, V]) reset() {
+	f.entries.Init()
+}
+
+////////////
+//lfu policy
+////////////
+
+//entries with the same frequency
+type lfuBucket struct {
+	freq    uint64
+	entries *list.List //front is the most recent
+}
+
+type lfuPolicy[K comparable, V any] struct {
+	buckets *list.List //lfu buckets, front is the lowest frequency
+}
+
+//construct
+func newLFUPolicy[K comparable, V any]() *lfuPolicy[K, V] {
+	return &lfuPolicy[K, V]{
+		buckets: list.New(),
+	}
+}
+
+func (f *lfuPolicy[K, V]) add(e *cacheEntry[K, V]) {
+	front := f.buckets.Front()
+	if front == nil || front.Value.(*lfuBucket).freq != 1 {
+		front = f.buckets.PushFront(&lfuBucket{freq: 1, entries: list.New()})
+	}
+	e.bucket = front
+	e.elem = front.Value.(*lfuBucket).entries.PushFront(e)
+}
+
+func (f *lfuPolicy[K, V]) access(e *cacheEntry[K, V]) {
+	cur := e.bucket
+	bucket := cur.Value.(*lfuBucket)
+	next := cur.Next()
+	if next == nil || next.Value.(*lfuBucket).freq != bucket.freq + 1 {
+		next = f.buckets.InsertAfter(&lfuBucket{freq: bucket.freq + 1, entries: list.New()}, cur)
+	}
+	bucket.entries.Remove(e.elem)
+	if bucket.entries.Len() <= 0 {
+		f.buckets.Remove(cur)
+	}
+	e.bucket = next
+	e.elem = next.Value.(*lfuBucket).entries.PushFront(e)
+}
+
+func (f *lfuPolicy[K, V]) remove(e *cacheEntry[K, V], evicted bool) {
+	bucket := e.bucket.Value.(*lfuBucket)
+	bucket.entries.Remove(e.elem)
+	if bucket.entries.Len() <= 0 {
+		f.buckets.Remove(e.bucket)
+	}
+	e.elem, e.bucket = nil, nil
+}
+
+func (f *lfuPolicy[K, V]) victim(incoming K) *cacheEntry[K, V] {
+	front := f.buckets.Front()
+	if front == nil {
+		return nil
+	}
+	return front.Value.(*lfuBucket).entries.Back().Value.(*cacheEntry[K, V])
+}
+
+func (f *lfuPolicy[K, V]) reset() {
+	f.buckets.Init()
+}
+
+////////////
+//arc policy
+////////////
+
+//ghost keys of evicted entries
+type arcGhost[K comparable] struct {
+	keys  *list.List //front is the most recent
+	index map[K]*list.Element
+}
+
+type arcPolicy[K comparable, V any] struct {
+	capacity int //target entries, 0 means adapt to the max resident count
+	target   int //target size of recent list, `p` in the paper
+	recent   *list.List
+	frequent *list.List
+	recentGhost   *arcGhost[K]
+	frequentGhost *arcGhost[K]
+}
+
+//construct
+func newARCPolicy[K comparable, V any](capacity int) *arcPolicy[K, V] {
+	return &arcPolicy[K, V]{
+		capacity: capacity,
+		recent: list.New(),
+		frequent: list.New(),
+		recentGhost: newARCGhost[K](),
+		frequentGhost: newARCGhost[K](),
+	}
+}
+
+func (f *arcPolicy[K, V]) add(e *cacheEntry[K, V]) {
+	//adapt recent target by ghost hit
+	capacity := f.getCapacity()
+	if f.recentGhost.has(e.key) {
+		delta := 1
+		if f.recentGhost.len() < f.frequentGhost.len() {
+			delta = f.frequentGhost.len() / f.recentGhost.len()
+		}
+		f.target = minInt(f.target + delta, capacity)
+		f.recentGhost.remove(e.key)
+		e.frequent = true
+	}else if f.frequentGhost.has(e.key) {
+		delta := 1
+		if f.frequentGhost.len() < f.recentGhost.len() {
+			delta = f.recentGhost.len() / f.frequentGhost.len()
+		}
+		f.target = maxInt(f.target - delta, 0)
+		f.frequentGhost.remove(e.key)
+		e.frequent = true
+	}else{
+		e.frequent = false
+	}
+	if e.frequent {
+		e.elem = f.frequent.PushFront(e)
+	}else{
+		e.elem = f.recent.PushFront(e)
+	}
+}
+
+func (f *arcPolicy[K, V]) access(e *cacheEntry[K, V]) {
+	if e.frequent {
+		f.frequent.MoveToFront(e.elem)
+		return
+	}
+	//second hit, promote into frequent list
+	f.recent.Remove(e.elem)
+	e.frequent = true
+	e.elem = f.frequent.PushFront(e)
+}
+
+func (f *arcPolicy[K, V]) remove(e *cacheEntry[K, V], evicted bool) {
+	capacity := f.getCapacity()
+	if e.frequent {
+		f.frequent.Remove(e.elem)
+		if evicted {
+			f.frequentGhost.add(e.key, capacity)
+		}
+	}else{
+		f.recent.Remove(e.elem)
+		if evicted {
+			f.recentGhost.add(e.key, capacity)
+		}
+	}
+	e.elem = nil
+}
+
+func (f *arcPolicy[K, V]) victim(incoming K) *cacheEntry[K, V] {
+	recentLen := f.recent.Len()
+	if recentLen > 0 && (f.frequent.Len() <= 0 || recentLen > f.target ||
+		(recentLen == f.target && f.frequentGhost.has(incoming))) {
+		return f.recent.Back().Value.(*cacheEntry[K, V])
+	}
+	if back := f.frequent.Back(); back != nil {
+		return back.Value.(*cacheEntry[K, V])
+	}
+	return nil
+}
+
+func (f *arcPolicy[K, V]) reset() {
+	f.target = 0
+	f.recent.Init()
+	f.frequent.Init()
+	f.recentGhost = newARCGhost[K]()
+	f.frequentGhost = newARCGhost[K]()
+}
+
+//get capacity, ghost lists bounded by it
+func (f *arcPolicy[K, V]) getCapacity() int {
+	if f.capacity > 0 {
+		return f.capacity
+	}
+	return maxInt(f.recent.Len() + f.frequent.Len(), 1)
+}
+
+//construct
+func newARCGhost[K comparable]() *arcGhost[K] {
+	return &arcGhost[K]{
+		keys: list.New(),
+		index: map[K]*list.Element{},
+	}
+}
+
+func (g *arcGhost[K]) has(key K) bool {
+	_, ok := g.index[key]
+	return ok
+}
+
+func (g *arcGhost[K]) len() int {
+	return g.keys.Len()
+}
+
+func (g *arcGhost[K]) add(key K, capacity int) {
+	if elem, ok := g.index[key]; ok {
+		g.keys.MoveToFront(elem)
+		return
+	}
+	g.index[key] = g.keys.PushFront(key)
+	for g.keys.Len() > capacity {
+		back := g.keys.Back()
+		delete(g.index, back.Value.(K))
+		g.keys.Remove(back)
+	}
+}
+
+func (g *arcGhost[K]) remove(key K) {
+	if elem, ok := g.index[key]; ok {
+		delete(g.index, key)
+		g.keys.Remove(elem)
+	}
+}
+
+//get min, max of two int
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -4,12 +4,15 @@ import (
 	"errors"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"unicode/utf8"
 )
 
 /*
  * DFA algorithm for words filter
  * - used for filter bad words
  * - replace matched word as `*`
+ * - base on aho-corasick automaton, rebuilt and swapped atomically on change
  */
 
 const (
@@ -18,20 +21,20 @@ const (
 
 //face info
 type DFA struct {
-	sensitiveWord map[string]interface{}
-	set           map[string]interface{}
-	invalidWords  []string
-	invalidWord   map[string]interface{}
-	sync.RWMutex
+	words       []AcWord       //filter words in added order
+	wordIdx     map[string]int //word -> index of words
+	nextId      int
+	invalidWord map[rune]struct{}
+	snapshot    atomic.Pointer[AhoCorasick]
+	sync.Mutex
 }
 
 //construct
 func NewDFA() *DFA {
 	this := &DFA{
-		sensitiveWord: map[string]interface{}{},
-		set: map[string]interface{}{},
-		invalidWords: []string{},
-		invalidWord: map[string]interface{}{},
+		words: []AcWord{},
+		wordIdx: map[string]int{},
+		invalidWord: map[rune]struct{}{},
 	}
 	this.interInit()
 	return this
@@ -39,63 +42,65 @@ func NewDFA() *DFA {
 
 //change words
 func (f *DFA) ChangeSensitiveWords(txt string) (bool, string){
-	str := []rune(txt)
-	nowMap := f.sensitiveWord
-	start := -1
-	tag := -1
-	hasFound := false
-	for i := 0; i < len(str); i++ {
-		if _, ok:= f.invalidWord[(string(str[i]))]; ok {
-			//if it is invalid word, skip it.
-			continue
-		}
-		if thisMap, ok :=nowMap[string(str[i])].(map[string]interface{}); ok {
-			//record first char of word
-			tag++
-			if  tag == 0 {
-				start = i
-
-			}
-			//check it is last char of word
-			if isEnd, _ := thisMap["isEnd"].(bool);isEnd {
-				//replace first to last char as `*`
-				for y := start; y < i+1; y++ {
-					str[y] = 42
-					hasFound = true
-				}
-				//reset
-				nowMap = f.sensitiveWord
-				start = -1
-				tag = -1
-
-			}else{
-				//not last, replace map to nowMap
-				nowMap = nowMap[string(str[i])].(map[string]interface{})
-			}
-		}else{
-			//if not full match, end found.
-			//check from second char
-			if start != -1 {
-				i = start + 1
-			}
-			//reset
-			nowMap = f.sensitiveWord
-			start = -1
-			tag = -1
-		}
-	}
-	return hasFound, string(str)
+	return f.Snapshot().Replace(txt, '*')
+}
+
+//find all matched words
+func (f *DFA) FindAll(txt string) []AcMatch {
+	return f.Snapshot().FindAll(txt)
+}
+
+//check text contains filter words or not
+func (f *DFA) Contains(txt string) bool {
+	return f.Snapshot().Contains(txt)
+}
+
+//get current automaton snapshot, immutable
+func (f *DFA) Snapshot() *AhoCorasick {
+	return f.snapshot.Load()
 }
 
 //reset filter words
 func (f *DFA) ResetFilterWords() {
 	f.Lock()
 	defer f.Unlock()
-	f.sensitiveWord = map[string]interface{}{}
+	f.words = []AcWord{}
+	f.wordIdx = map[string]int{}
+	f.rebuild()
+}
+
+//replace all filter words with id and category
+func (f *DFA) SetFilterWords(words ...AcWord) error {
+	//check
+	if words == nil || len(words) <= 0 {
+		return errors.New("invalid parameter")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.words = []AcWord{}
+	f.wordIdx = map[string]int{}
+	f.nextId = 0
+	for _, v := range words {
+		v.Word = strings.TrimSpace(v.Word)
+		if v.Word == "" {
+			continue
+		}
+		if v.Id >= f.nextId {
+			f.nextId = v.Id + 1
+		}
+		f.addWord(v)
+	}
+	f.rebuild()
+	return nil
 }
 
 //add filter words
 func (f *DFA) AddFilterWords(words ...string) error {
+	return f.AddCategoryWords("", words...)
+}
+
+//add filter words of category
+func (f *DFA) AddCategoryWords(category string, words ...string) error {
 	//check
 	if words == nil || len(words) <= 0 {
 		return errors.New("invalid parameter")
@@ -108,10 +113,19 @@ func (f *DFA) AddFilterWords(words ...string) error {
 		if v == "" {
 			continue
 		}
-		f.set[v] = nil
+		if idx, ok := f.wordIdx[v]; ok {
+			f.words[idx].Category = category
+			continue
+		}
+		f.addWord(AcWord{
+			Word: v,
+			Id: f.nextId,
+			Category: category,
+		})
+		f.nextId++
 	}
-	//sync sensitive map
-	f.addSensitiveToMap(f.set)
+	//sync automaton
+	f.rebuild()
 	return nil
 }
 
@@ -126,11 +140,9 @@ func (f *DFA) AddInvalidWords(words ...string) error {
 	defer f.Unlock()
 	for _, v := range words {
 		v = strings.TrimSpace(v)
-		if v == "" {
-			continue
-		}
-		f.invalidWord[v] = nil
+		f.addInvalidWord(v)
 	}
+	f.rebuild()
 	return nil
 }
 
@@ -138,26 +150,32 @@ func (f *DFA) AddInvalidWords(words ...string) error {
 //private func
 /////////////////////
 
-//add sensitive words
-func (f *DFA) addSensitiveToMap(set map[string]interface{}){
-	for key := range set {
-		str := []rune(key)
-		nowMap := f.sensitiveWord
-		for i := 0; i < len(str); i++ {
-			if _,ok := nowMap[string(str[i])]; !ok {
-				//if key not exists，
-				thisMap := make(map[string]interface{})
-				thisMap["isEnd"] = false
-				nowMap[string(str[i])] = thisMap
-				nowMap = thisMap
-			}else {
-				nowMap = nowMap[string(str[i])].(map[string]interface{})
-			}
-			if i == len(str)-1 {
-				nowMap["isEnd"] = true
-			}
-		}
+//add or replace word
+func (f *DFA) addWord(word AcWord) {
+	if idx, ok := f.wordIdx[word.Word]; ok {
+		f.words[idx] = word
+		return
+	}
+	f.wordIdx[word.Word] = len(f.words)
+	f.words = append(f.words, word)
+}
+
+//add single rune invalid word
+func (f *DFA) addInvalidWord(word string) {
+	if utf8.RuneCountInString(word) != 1 {
+		return
 	}
+	r, _ := utf8.DecodeRuneInString(word)
+	f.invalidWord[r] = struct{}{}
+}
+
+//rebuild automaton and swap
+func (f *DFA) rebuild() {
+	skipRunes := make([]rune, 0, len(f.invalidWord))
+	for r := range f.invalidWord {
+		skipRunes = append(skipRunes, r)
+	}
+	f.snapshot.Store(NewAhoCorasick(f.words, skipRunes...))
 }
 
 //load default invalid word
@@ -169,11 +187,12 @@ func (f *DFA) loadDefaultInvalidWord() {
 	}
 	//load into invalid word
 	for _, v := range words {
-		f.invalidWord[v] = nil
+		f.addInvalidWord(v)
 	}
 }
 
 //inter init
 func (f *DFA) interInit() {
 	f.loadDefaultInvalidWord()
-}
\ No newline at end of file
+	f.rebuild()
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"os"
+	"strings"
 )
 
 /*
@@ -72,4 +73,37 @@ func (f *File) CheckOrCreateDir(dir string) error {
 	}
 	err = os.Mkdir(dir, 0777)
 	return err
-}
\ No newline at end of file
+}
+
+//get sorted file names of dir with assigned suffix
+func (f *File) GetDirFiles(
+	dir string,
+	suffixes ...string) ([]string, error) {
+	var (
+		suffix string
+	)
+	//check
+	if dir == "" {
+		return nil, errors.New("invalid dir path")
+	}
+	if suffixes != nil && len(suffixes) > 0 {
+		suffix = suffixes[0]
+	}
+
+	//read dir entries
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if suffix != "" && !strings.HasSuffix(entry.Name(), suffix) {
+			continue
+		}
+		result = append(result, entry.Name())
+	}
+	return result, nil
+}
@@ -2,17 +2,21 @@ package util
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"mime/multipart"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -22,23 +26,75 @@ import (
  * http client face
  * @author <AndyZhou>
  * @mail <diudiu8848@163.com>
+ * - SendReq queued into one process, Do sent in caller goroutine
+ * - per request context and timeout
+ * - retry idempotent requests with exponential backoff and jitter
+ * - json helpers, like HttpGetJSON[T]
+ * - per host circuit breaker and bulkhead, queued requests run by worker pool
  */
 
 //http request method
 const (
 	HttpReqGet = iota
 	HttpReqPost
+	HttpReqPut
+	HttpReqPatch
+	HttpReqDelete
+	HttpReqHead
 )
 
 //inter macro define
 const (
-	HttpClientTimeOut  = 5 //xx seconds
-	HttpReqChanSize    = 1024
-	HttpReqRecChanSize = 1
-	HttpReqPrefix      = "http://"
-	HttpsReqPrefix     = "https://"
+	HttpClientTimeOut   = 5 //xx seconds
+	HttpReqChanSize     = 1024
+	HttpReqRecChanSize  = 1
+	HttpReqWorkers      = 64 //max running queued requests
+	HttpReqPrefix       = "http://"
+	HttpsReqPrefix      = "https://"
+	HttpJsonContentType = "application/json"
+	HttpRetryBaseDelay  = 100 * time.Millisecond
+	HttpRetryMaxDelay   = 2 * time.Second
 )
 
+//method names of request kind
+var httpMethods = map[int]string{
+	HttpReqGet: http.MethodGet,
+	HttpReqPost: http.MethodPost,
+	HttpReqPut: http.MethodPut,
+	HttpReqPatch: http.MethodPatch,
+	HttpReqDelete: http.MethodDelete,
+	HttpReqHead: http.MethodHead,
+}
+
+//retry policy
+//only idempotent requests retried, GET, HEAD, PUT and DELETE
+type HttpRetry struct {
+	MaxAttempts int           //attempts including the first one
+	BaseDelay   time.Duration //default HttpRetryBaseDelay
+	MaxDelay    time.Duration //default HttpRetryMaxDelay
+	RetryOn     func(resp *HttpResp) bool //default retry on net error, 429 and 5xx
+}
+
+//per host guard config
+//queued requests of full host fail fast, MaxWait works for Do only
+type HttpGuardConf struct {
+	Breaker       *BreakerConf  //breaker of each host, nil means disabled, name is host
+	MaxConcurrent int           //max concurrent requests of each host, 0 means unlimited
+	MaxWait       time.Duration //max wait for bulkhead slot, 0 means fail fast
+}
+
+//guard of single host
+type httpHostGuard struct {
+	breaker  *Breaker
+	bulkhead *Bulkhead
+}
+
+//error of unexpected status
+type HttpStatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
 //http file para
 type HttpFilePara struct {
 	FilePath string
@@ -46,8 +102,10 @@ type HttpFilePara struct {
 }
 
 type HttpResp struct {
-	Data []byte
-	Err  error
+	Data       []byte
+	Err        error
+	StatusCode int
+	Header     http.Header
 }
 
 //http request face info
@@ -60,13 +118,23 @@ type HttpReq struct {
 	Body         []byte
 	ReceiverChan chan HttpResp //http request receiver chan
 	IsAsync      bool
+	Ctx          context.Context //request context, optional
+	Timeout      time.Duration   //timeout of each attempt, default client timeout
+	Retry        *HttpRetry      //retry policy, default client retry policy
+	Idempotent   bool            //retry POST or PATCH as idempotent
+	Silent       bool            //not write failure into log, used by log sinks
 }
 
 //http face
 type HttpClient struct {
-	client    *http.Client `http client instance`
-	reqChan   chan HttpReq `request lazy chan`
-	closeChan chan bool
+	client     *http.Client `http client instance`
+	reqChan    chan HttpReq `request lazy chan`
+	closeChan  chan bool
+	workerChan chan struct{} //running queued requests
+	timeout    time.Duration
+	retry      *HttpRetry
+	guard      *HttpGuardConf
+	guards     map[string]*httpHostGuard
 	sync.RWMutex
 }
 
@@ -86,6 +154,9 @@ func NewHttpClientWithChanSize(reqChanSize int) *HttpClient {
 	this := &HttpClient{
 		reqChan:make(chan HttpReq, realReqChanSize),
 		closeChan:make(chan bool, 1),
+		workerChan:make(chan struct{}, HttpReqWorkers),
+		timeout:HttpClientTimeOut * time.Second,
+		guards:map[string]*httpHostGuard{},
 	}
 
 	//inter init
@@ -118,6 +189,101 @@ func (q *HttpClient) Quit() {
 	}
 }
 
+//set default timeout of each attempt
+func (q *HttpClient) SetTimeout(timeout time.Duration) error {
+	//check
+	if timeout <= 0 {
+		return errors.New("invalid parameter")
+	}
+	q.Lock()
+	defer q.Unlock()
+	q.timeout = timeout
+	return nil
+}
+
+//set default retry policy, nil means no retry
+func (q *HttpClient) SetRetry(retry *HttpRetry) {
+	q.Lock()
+	defer q.Unlock()
+	q.retry = retry
+}
+
+//set per host circuit breaker and bulkhead, nil means disabled
+//guards of hosts reset
+func (q *HttpClient) SetGuard(conf *HttpGuardConf) {
+	q.Lock()
+	defer q.Unlock()
+	q.guard = conf
+	q.guards = map[string]*httpHostGuard{}
+}
+
+//get breaker of host, nil if not enabled or not used yet
+func (q *HttpClient) GetBreaker(host string) *Breaker {
+	q.RLock()
+	defer q.RUnlock()
+	if guard, ok := q.guards[host]; ok {
+		return guard.breaker
+	}
+	return nil
+}
+
+//send request in caller goroutine, with context, timeout and retry
+//response with status and headers returned, non 2xx status is not error
+func (q *HttpClient) Do(req *HttpReq) (*HttpResp, error) {
+	return q.doReq(req, false)
+}
+
+//send json request and decode json response into out
+//in -> request object, nil means no body
+//out -> pointer of response object, nil means ignore body
+//non 2xx status returned as *HttpStatusError
+func (q *HttpClient) DoJSON(
+	ctx context.Context,
+	kind int,
+	url string,
+	in, out interface{},
+	headers ...map[string]string) (*HttpResp, error) {
+	//check
+	if url == "" {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//init request
+	req := q.GenRequest()
+	req.Kind = kind
+	req.Url = url
+	req.Ctx = ctx
+	req.Headers["Accept"] = HttpJsonContentType
+	if in != nil {
+		body, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = body
+		req.Headers["Content-Type"] = HttpJsonContentType
+	}
+	if len(headers) > 0 {
+		for k, v := range headers[0] {
+			req.Headers[k] = v
+		}
+	}
+
+	//send and decode
+	resp, err := q.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	if !resp.IsSuccess() {
+		return resp, &HttpStatusError{StatusCode: resp.StatusCode, Body: resp.Data}
+	}
+	if out != nil && len(resp.Data) > 0 {
+		if err = json.Unmarshal(resp.Data, out); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
 //send request
 func (q *HttpClient) SendReq(
 	req *HttpReq) (*HttpResp, error) {
@@ -191,14 +357,9 @@ func (q *HttpClient) runMainProcess() {
 	for {
 		select {
 		case req, isOk = <- q.reqChan:
-			//process single request
+			//process single request by worker
 			if isOk && &req != nil {
-				data, err := q.sendHttpReq(&req)
-				resp := &HttpResp{
-					Data: data,
-					Err: err,
-				}
-				req.ReceiverChan <- *resp
+				q.dispatchReq(req)
 			}
 		case <- q.closeChan:
 			return
@@ -206,8 +367,171 @@ func (q *HttpClient) runMainProcess() {
 	}
 }
 
+//send request with retry
+//bulkheadHeld -> bulkhead of host acquired by queue process
+func (q *HttpClient) doReq(req *HttpReq, bulkheadHeld bool) (*HttpResp, error) {
+	//check
+	if req == nil {
+		return nil, errors.New("invalid parameter")
+	}
+	ctx := req.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q.RLock()
+	timeout, retry := q.timeout, q.retry
+	q.RUnlock()
+	if req.Timeout > 0 {
+		timeout = req.Timeout
+	}
+	if req.Retry != nil {
+		retry = req.Retry
+	}
+	attempts := 1
+	if retry != nil && retry.MaxAttempts > 1 && q.isIdempotent(req) {
+		attempts = retry.MaxAttempts
+	}
+
+	//send with retry
+	for attempt := 1; ; attempt++ {
+		resp := q.guardHttpReq(ctx, req, timeout, bulkheadHeld)
+		if attempt >= attempts || ctx.Err() != nil ||
+			resp.Err == ErrBreakerOpen || resp.Err == ErrBulkheadFull || !retry.shouldRetry(resp) {
+			return resp, resp.Err
+		}
+		select {
+		case <- time.After(retry.backoff(attempt, resp)):
+		case <- ctx.Done():
+			return resp, resp.Err
+		}
+	}
+}
+
+//dispatch queued request into worker
+//bulkhead of host acquired before worker, full host fails fast without worker
+func (q *HttpClient) dispatchReq(req HttpReq) {
+	var (
+		bulkhead *Bulkhead
+	)
+	if guard := q.getHostGuard(req.Url); guard != nil && guard.bulkhead != nil {
+		if !guard.bulkhead.TryAcquire() {
+			req.ReceiverChan <- HttpResp{Err: ErrBulkheadFull}
+			return
+		}
+		bulkhead = guard.bulkhead
+	}
+	q.workerChan <- struct{}{}
+	go q.processReq(req, bulkhead)
+}
+
+//process single queued request
+func (q *HttpClient) processReq(req HttpReq, bulkhead *Bulkhead) {
+	var (
+		m any = nil
+	)
+	defer func() {
+		if err := recover(); err != m {
+			log.Println("HttpClient::processReq, panic happened, err:", err)
+		}
+		if bulkhead != nil {
+			bulkhead.Release()
+		}
+		<- q.workerChan
+	}()
+	resp, _ := q.doReq(&req, bulkhead != nil)
+	if resp == nil {
+		resp = &HttpResp{Err: errors.New("invalid parameter")}
+	}
+	req.ReceiverChan <- *resp
+}
+
+//send request once within guard of host
+func (q *HttpClient) guardHttpReq(
+	ctx context.Context,
+	reqObj *HttpReq,
+	timeout time.Duration,
+	bulkheadHeld bool) *HttpResp {
+	guard := q.getHostGuard(reqObj.Url)
+	if guard == nil {
+		return q.sendHttpReq(ctx, reqObj, timeout)
+	}
+
+	//bulkhead first, then breaker
+	if guard.bulkhead != nil && !bulkheadHeld {
+		if err := guard.bulkhead.Acquire(ctx); err != nil {
+			return &HttpResp{Err: err}
+		}
+		defer guard.bulkhead.Release()
+	}
+	if guard.breaker == nil {
+		return q.sendHttpReq(ctx, reqObj, timeout)
+	}
+	done, err := guard.breaker.Allow()
+	if err != nil {
+		return &HttpResp{Err: err}
+	}
+	resp := q.sendHttpReq(ctx, reqObj, timeout)
+
+	//net error and 5xx are failures, canceled by caller ignored
+	switch {
+	case resp.Err != nil && ctx.Err() != nil:
+		done(ErrBreakerIgnored)
+	case resp.Err != nil:
+		done(resp.Err)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		done(&HttpStatusError{StatusCode: resp.StatusCode})
+	default:
+		done(nil)
+	}
+	return resp
+}
+
+//get or create guard of request host
+func (q *HttpClient) getHostGuard(reqUrl string) *httpHostGuard {
+	q.RLock()
+	conf := q.guard
+	q.RUnlock()
+	if conf == nil || (conf.Breaker == nil && conf.MaxConcurrent <= 0) {
+		return nil
+	}
+	if !strings.HasPrefix(reqUrl, HttpReqPrefix) &&
+		!strings.HasPrefix(reqUrl, HttpsReqPrefix) {
+		reqUrl = HttpReqPrefix + reqUrl
+	}
+	u, err := url.Parse(reqUrl)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+
+	//get exists
+	q.RLock()
+	guard, ok := q.guards[u.Host]
+	q.RUnlock()
+	if ok {
+		return guard
+	}
+
+	//create new
+	q.Lock()
+	defer q.Unlock()
+	if guard, ok = q.guards[u.Host]; ok || q.guard != conf {
+		return guard
+	}
+	guard = &httpHostGuard{}
+	if conf.Breaker != nil {
+		breakerConf := *conf.Breaker
+		breakerConf.Name = u.Host
+		guard.breaker = NewBreaker(&breakerConf)
+	}
+	if conf.MaxConcurrent > 0 {
+		guard.bulkhead = NewBulkhead(conf.MaxConcurrent, conf.MaxWait)
+	}
+	q.guards[u.Host] = guard
+	return guard
+}
+
 //upload file request
-func (q *HttpClient) fileUploadReq(reqObj *HttpReq) (*http.Request, error) {
+func (q *HttpClient) fileUploadReq(ctx context.Context, reqObj *HttpReq) (*http.Request, error) {
 	//try open file
 	file, err := os.Open(reqObj.FilePara.FilePath)
 	if err != nil {
@@ -240,16 +564,20 @@ func (q *HttpClient) fileUploadReq(reqObj *HttpReq) (*http.Request, error) {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", reqObj.Url, body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqObj.Url, body)
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
-	return req, err
+	return req, nil
 }
 
 //general request
-func (q *HttpClient) generalReq(reqObj *HttpReq) (*http.Request, error) {
+func (q *HttpClient) generalReq(ctx context.Context, reqObj *HttpReq) (*http.Request, error) {
 	var (
 		tempStr string
 		buffer = bytes.NewBuffer(nil)
+		reqUrl = reqObj.Url
 		req *http.Request
 		err error
 	)
@@ -268,24 +596,37 @@ func (q *HttpClient) generalReq(reqObj *HttpReq) (*http.Request, error) {
 	}
 
 	//get request method
+	method := httpMethods[reqObj.Kind]
 	switch reqObj.Kind {
-	case HttpReqPost:
+	case HttpReqPost, HttpReqPut, HttpReqPatch:
 		{
 			if reqObj.Body != nil {
 				buffer.Write(reqObj.Body)
 			}
 			//int post req
-			req, err = http.NewRequest("POST", reqObj.Url, strings.NewReader(buffer.String()))
-			if req.Header == nil || len(req.Header) <= 0 {
-				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req, err = http.NewRequestWithContext(ctx, method, reqUrl, strings.NewReader(buffer.String()))
+			if err != nil {
+				return nil, err
 			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		}
 	default:
-		//init get req
+		//init get, head or delete req, parameters in query
+		var body io.Reader
 		if buffer != nil && buffer.Len() > 0 {
-			reqObj.Url = fmt.Sprintf("%s?%s", reqObj.Url, buffer.String())
+			sep := "?"
+			if strings.Contains(reqUrl, "?") {
+				sep = "&"
+			}
+			reqUrl = fmt.Sprintf("%s%s%s", reqUrl, sep, buffer.String())
+		}
+		if reqObj.Kind == HttpReqDelete && len(reqObj.Body) > 0 {
+			body = bytes.NewReader(reqObj.Body)
+		}
+		req, err = http.NewRequestWithContext(ctx, method, reqUrl, body)
+		if err != nil {
+			return nil, err
 		}
-		req, err = http.NewRequest("GET", reqObj.Url, nil)
 	}
 
 	//format post form
@@ -300,8 +641,11 @@ func (q *HttpClient) generalReq(reqObj *HttpReq) (*http.Request, error) {
 	return req, err
 }
 
-//send original http request and get response
-func (q *HttpClient) sendHttpReq(reqObj *HttpReq) ([]byte, error) {
+//send original http request once and get response
+func (q *HttpClient) sendHttpReq(
+	ctx context.Context,
+	reqObj *HttpReq,
+	timeout time.Duration) *HttpResp {
 	var (
 		req *http.Request
 		err error
@@ -309,12 +653,16 @@ func (q *HttpClient) sendHttpReq(reqObj *HttpReq) ([]byte, error) {
 
 	//basic check
 	if q.client == nil || reqObj == nil {
-		return nil, errors.New("invalid parameter")
+		return &HttpResp{Err: errors.New("invalid parameter")}
 	}
-	if reqObj.Kind < HttpReqGet || reqObj.Url == "" {
-		return nil, errors.New("invalid parameter")
+	if _, ok := httpMethods[reqObj.Kind]; !ok || reqObj.Url == "" {
+		return &HttpResp{Err: errors.New("invalid parameter")}
 	}
 
+	//timeout of this attempt
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
 	//check and fix http prefix
 	if !strings.HasPrefix(reqObj.Url, HttpReqPrefix) &&
 		!strings.HasPrefix(reqObj.Url, HttpsReqPrefix) {
@@ -325,14 +673,16 @@ func (q *HttpClient) sendHttpReq(reqObj *HttpReq) ([]byte, error) {
 	if reqObj.FilePara.FilePath != "" &&
 		reqObj.FilePara.FilePara != "" {
 		//file upload request
-		req, err = q.fileUploadReq(reqObj)
+		req, err = q.fileUploadReq(ctx, reqObj)
 	}else{
 		//general request
-		req, err = q.generalReq(reqObj)
+		req, err = q.generalReq(ctx, reqObj)
 	}
 	if err != nil {
-		log.Println("HttpClient::sendHttpReq, create request failed, err:", err.Error())
-		return nil, err
+		if !reqObj.Silent {
+			log.Println("HttpClient::sendHttpReq, create request failed, err:", err.Error())
+		}
+		return &HttpResp{Err: err}
 	}
 
 	//set headers
@@ -350,22 +700,39 @@ func (q *HttpClient) sendHttpReq(reqObj *HttpReq) ([]byte, error) {
 	//c.client.Timeout = time.Second
 	resp, err := q.client.Do(req)
 	if err != nil {
-		log.Println("HttpClient::sendHttpReq, send http request failed, err:", err.Error())
-		return nil, err
+		if !reqObj.Silent {
+			log.Println("HttpClient::sendHttpReq, send http request failed, err:", err.Error())
+		}
+		return &HttpResp{Err: err}
 	}
 
 	//close resp before return
 	defer resp.Body.Close()
 
 	//read response
-	respBody, err := ioutil.ReadAll(resp.Body)
+	result := &HttpResp{
+		StatusCode: resp.StatusCode,
+		Header: resp.Header,
+	}
+	result.Data, err = ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Println("HttpClient::sendHttpReq, read response body failed, err:", err.Error())
-		return nil, err
+		if !reqObj.Silent {
+			log.Println("HttpClient::sendHttpReq, read response body failed, err:", err.Error())
+		}
+		result.Err = err
 	}
 
 	//return response
-	return respBody, nil
+	return result
+}
+
+//check request could be retried or not
+func (q *HttpClient) isIdempotent(req *HttpReq) bool {
+	switch req.Kind {
+	case HttpReqGet, HttpReqHead, HttpReqPut, HttpReqDelete:
+		return true
+	}
+	return req.Idempotent
 }
 
 //inter init
@@ -377,13 +744,127 @@ func (q *HttpClient) interInit() {
 			KeepAlive: 30 * time.Second,
 		}).Dial,
 		TLSHandshakeTimeout: HttpClientTimeOut * time.Second,
-		ResponseHeaderTimeout: HttpClientTimeOut * time.Second,
 		ExpectContinueTimeout: HttpClientTimeOut* time.Second,
 	}
 
-	//init native http client
+	//init native http client, timeout by request context
 	q.client = &http.Client{
-		Timeout:time.Second * HttpClientTimeOut,
 		Transport:netTransport,
 	}
+}
+
+////////////////////
+//api of response
+////////////////////
+
+//check status is 2xx or not
+func (r *HttpResp) IsSuccess() bool {
+	return r.Err == nil && r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+//decode json body
+func (r *HttpResp) DecodeJSON(out interface{}) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	return json.Unmarshal(r.Data, out)
+}
+
+//get error message
+func (e *HttpStatusError) Error() string {
+	body := e.Body
+	if len(body) > 256 {
+		body = body[:256]
+	}
+	return fmt.Sprintf("unexpected http status %d, body:%s", e.StatusCode, body)
+}
+
+////////////////////
+//api of retry policy
+////////////////////
+
+//check response should be retried or not
+func (r *HttpRetry) shouldRetry(resp *HttpResp) bool {
+	if r.RetryOn != nil {
+		return r.RetryOn(resp)
+	}
+	if resp.Err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+//get delay before next attempt
+//exponential backoff with equal jitter, Retry-After honoured up to max delay
+func (r *HttpRetry) backoff(attempt int, resp *HttpResp) time.Duration {
+	base, maxDelay := r.BaseDelay, r.MaxDelay
+	if base <= 0 {
+		base = HttpRetryBaseDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = HttpRetryMaxDelay
+	}
+	delay := maxDelay
+	if attempt < 32 && base << (attempt - 1) < maxDelay {
+		delay = base << (attempt - 1)
+	}
+	delay = delay / 2 + time.Duration(rand.Int63n(int64(delay / 2) + 1))
+	if resp != nil && resp.Header != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			retryAfter := time.Duration(seconds) * time.Second
+			if retryAfter > maxDelay {
+				retryAfter = maxDelay
+			}
+			if retryAfter > delay {
+				delay = retryAfter
+			}
+		}
+	}
+	return delay
+}
+
+////////////////////
+//json helpers
+////////////////////
+
+//send json request and decode response into T
+func HttpJSON[T any](
+	ctx context.Context,
+	q *HttpClient,
+	kind int,
+	url string,
+	in interface{},
+	headers ...map[string]string) (T, *HttpResp, error) {
+	var (
+		out T
+	)
+	if q == nil {
+		return out, nil, errors.New("invalid parameter")
+	}
+	resp, err := q.DoJSON(ctx, kind, url, in, &out, headers...)
+	return out, resp, err
+}
+
+//get json response as T
+func HttpGetJSON[T any](
+	ctx context.Context,
+	q *HttpClient,
+	url string,
+	headers ...map[string]string) (T, *HttpResp, error) {
+	return HttpJSON[T](ctx, q, HttpReqGet, url, nil, headers...)
+}
+
+//post json request, get json response as T
+func HttpPostJSON[T any](
+	ctx context.Context,
+	q *HttpClient,
+	url string,
+	in interface{},
+	headers ...map[string]string) (T, *HttpResp, error) {
+	return HttpJSON[T](ctx, q, HttpReqPost, url, in, headers...)
 }
\ No newline at end of file
@@ -0,0 +1,286 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+/*
+ * structured levelled logger
+ * - debug, info, warn and error levels, same values as slog
+ * - key-value fields, child logger with bound fields and groups
+ * - handler interface in slog style, text and json handlers
+ * - level changeable at runtime by LogLevelVar
+ */
+
+//log level define
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = -4
+	LogLevelInfo  LogLevel = 0
+	LogLevelWarn  LogLevel = 4
+	LogLevelError LogLevel = 8
+)
+
+//inter macro define
+const (
+	logBadKey = "!BADKEY"
+)
+
+//level source
+type LogLeveler interface {
+	Level() LogLevel
+}
+
+//log field
+type LogField struct {
+	Key   string
+	Value interface{}
+}
+
+//single log record
+type LogRecord struct {
+	Time    time.Time
+	Level   LogLevel
+	Message string
+	PC      uintptr //caller, 0 if unknown
+	Fields  []LogField
+}
+
+//log handler, same as slog.Handler
+type LogHandler interface {
+	//check level enabled or not
+	Enabled(ctx context.Context, level LogLevel) bool
+	//handle one record
+	Handle(ctx context.Context, record LogRecord) error
+	//new handler with bound fields
+	WithAttrs(fields []LogField) LogHandler
+	//new handler with group, later fields inside it
+	WithGroup(name string) LogHandler
+}
+
+//level could be changed at runtime
+type LogLevelVar struct {
+	level atomic.Int64
+}
+
+//face info
+type Logger struct {
+	handler LogHandler
+}
+
+//create log field
+func Field(key string, value interface{}) LogField {
+	return LogField{Key: key, Value: value}
+}
+
+//parse level from string, like `debug` or `WARN`
+func ParseLogLevel(s string) (LogLevel, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "DEBUG":
+		return LogLevelDebug, nil
+	case "INFO", "":
+		return LogLevelInfo, nil
+	case "WARN", "WARNING":
+		return LogLevelWarn, nil
+	case "ERROR":
+		return LogLevelError, nil
+	}
+	return LogLevelInfo, fmt.Errorf("invalid log level %q", s)
+}
+
+//get level, implement LogLeveler
+func (l LogLevel) Level() LogLevel {
+	return l
+}
+
+//get level name
+func (l LogLevel) String() string {
+	name := func(base string, offset LogLevel) string {
+		if offset == 0 {
+			return base
+		}
+		return fmt.Sprintf("%s%+d", base, offset)
+	}
+	switch {
+	case l < LogLevelInfo:
+		return name("DEBUG", l - LogLevelDebug)
+	case l < LogLevelWarn:
+		return name("INFO", l - LogLevelInfo)
+	case l < LogLevelError:
+		return name("WARN", l - LogLevelWarn)
+	default:
+		return name("ERROR", l - LogLevelError)
+	}
+}
+
+//construct level var
+func NewLogLevelVar(levels ...LogLevel) *LogLevelVar {
+	this := &LogLevelVar{}
+	if len(levels) > 0 {
+		this.Set(levels[0])
+	}
+	return this
+}
+
+//get level
+func (v *LogLevelVar) Level() LogLevel {
+	return LogLevel(v.level.Load())
+}
+
+//set level
+func (v *LogLevelVar) Set(level LogLevel) {
+	v.level.Store(int64(level))
+}
+
+//set level by name
+func (v *LogLevelVar) SetString(s string) error {
+	level, err := ParseLogLevel(s)
+	if err != nil {
+		return err
+	}
+	v.Set(level)
+	return nil
+}
+
+//construct
+func NewLogger(handler LogHandler) *Logger {
+	if handler == nil {
+		handler = NewLogTextHandler(nil)
+	}
+	return &Logger{
+		handler: handler,
+	}
+}
+
+//get handler
+func (l *Logger) Handler() LogHandler {
+	return l.handler
+}
+
+//child logger with bound fields
+//args -> key-value pairs or LogField
+func (l *Logger) With(args ...interface{}) *Logger {
+	fields := parseLogArgs(args)
+	if len(fields) <= 0 {
+		return l
+	}
+	return &Logger{
+		handler: l.handler.WithAttrs(fields),
+	}
+}
+
+//child logger with group
+func (l *Logger) WithGroup(name string) *Logger {
+	if name == "" {
+		return l
+	}
+	return &Logger{
+		handler: l.handler.WithGroup(name),
+	}
+}
+
+//check level enabled or not
+func (l *Logger) Enabled(level LogLevel) bool {
+	return l.handler.Enabled(context.Background(), level)
+}
+
+//log by levels
+func (l *Logger) Debug(msg string, args ...interface{}) {
+	l.log(context.Background(), LogLevelDebug, 1, msg, args)
+}
+
+func (l *Logger) Info(msg string, args ...interface{}) {
+	l.log(context.Background(), LogLevelInfo, 1, msg, args)
+}
+
+func (l *Logger) Warn(msg string, args ...interface{}) {
+	l.log(context.Background(), LogLevelWarn, 1, msg, args)
+}
+
+func (l *Logger) Error(msg string, args ...interface{}) {
+	l.log(context.Background(), LogLevelError, 1, msg, args)
+}
+
+//log with context and level
+func (l *Logger) Log(ctx context.Context, level LogLevel, msg string, args ...interface{}) {
+	l.log(ctx, level, 1, msg, args)
+}
+
+//////////////
+//private func
+//////////////
+
+//build record and handle
+//skip -> frames of public log func between caller and this func
+func (l *Logger) log(
+	ctx context.Context,
+	level LogLevel,
+	skip int,
+	msg string,
+	args []interface{}) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !l.handler.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(skip + 2, pcs[:])
+	record := LogRecord{
+		Time: time.Now(),
+		Level: level,
+		Message: msg,
+		PC: pcs[0],
+		Fields: parseLogArgs(args),
+	}
+	if err := l.handler.Handle(ctx, record); err != nil {
+		fmt.Println("handle log failed, err:", err.Error())
+	}
+}
+
+//parse key-value pairs into fields
+func parseLogArgs(args []interface{}) []LogField {
+	var (
+		fields []LogField
+	)
+	for i := 0; i < len(args); i++ {
+		switch v := args[i].(type) {
+		case LogField:
+			fields = append(fields, v)
+		case string:
+			if i + 1 >= len(args) {
+				fields = append(fields, LogField{logBadKey, v})
+				continue
+			}
+			fields = append(fields, LogField{v, args[i + 1]})
+			i++
+		default:
+			fields = append(fields, LogField{logBadKey, v})
+		}
+	}
+	return fields
+}
+
+//get level from config map
+func logLevelFromConf(conf map[string]interface{}, key string) (LogLevel, error) {
+	v, ok := conf[key]
+	if !ok {
+		return LogLevelInfo, errors.New("no log level config")
+	}
+	switch level := v.(type) {
+	case string:
+		return ParseLogLevel(level)
+	case float64:
+		return LogLevel(level), nil
+	case int:
+		return LogLevel(level), nil
+	}
+	return LogLevelInfo, fmt.Errorf("invalid log level %v", v)
+}
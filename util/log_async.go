@@ -0,0 +1,265 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+ * async buffered log writer
+ * - entries queued into bounded ring buffer, written into sinks by background process
+ * - overflow policy: drop newest, drop oldest or block writer
+ * - flushed periodically, when batch is full and on close
+ * - sink errors reported by callback, never written back into log
+ */
+
+//overflow policy
+const (
+	LogOverflowDropNewest = iota //drop incoming entry
+	LogOverflowDropOldest        //drop the oldest queued entry
+	LogOverflowBlock             //block writer until room made
+)
+
+//default setup
+const (
+	defaultAsyncLogBufferSize    = 8192
+	defaultAsyncLogBatchSize     = 256
+	defaultAsyncLogFlushInterval = time.Second
+)
+
+//inter error define
+var (
+	ErrLogWriterClosed = errors.New("log writer closed")
+)
+
+//async writer config
+type AsyncLogConf struct {
+	BufferSize    int           //max queued entries, default 8192
+	BatchSize     int           //max entries of one sink write, default 256
+	Overflow      int           //overflow policy, default LogOverflowDropNewest
+	FlushInterval time.Duration //default one second
+	OnError       func(err error) //sink error callback, default print into stderr
+}
+
+//face info
+type AsyncLogWriter struct {
+	conf      AsyncLogConf
+	sinks     []LogSink
+	ring      [][]byte
+	head      int //index of the oldest entry
+	count     int
+	closed    bool
+	dropped   atomic.Uint64
+	notFull   *sync.Cond
+	wakeChan  chan struct{}
+	flushChan chan chan error
+	closeChan chan struct{}
+	doneChan  chan struct{}
+	closeOnce sync.Once
+	sync.Mutex
+}
+
+//construct
+func NewAsyncLogWriter(conf *AsyncLogConf, sinks ...LogSink) (*AsyncLogWriter, error) {
+	//check
+	if len(sinks) <= 0 {
+		return nil, errors.New("invalid parameter")
+	}
+	for _, sink := range sinks {
+		if sink == nil {
+			return nil, errors.New("invalid parameter")
+		}
+	}
+
+	//fill default config
+	c := AsyncLogConf{}
+	if conf != nil {
+		c = *conf
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = defaultAsyncLogBufferSize
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultAsyncLogBatchSize
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = defaultAsyncLogFlushInterval
+	}
+	if c.OnError == nil {
+		c.OnError = func(err error) {
+			fmt.Fprintln(os.Stderr, "async log writer, sink failed, err:", err.Error())
+		}
+	}
+
+	//self init
+	this := &AsyncLogWriter{
+		conf: c,
+		sinks: sinks,
+		ring: make([][]byte, c.BufferSize),
+		wakeChan: make(chan struct{}, 1),
+		flushChan: make(chan chan error),
+		closeChan: make(chan struct{}),
+		doneChan: make(chan struct{}),
+	}
+	this.notFull = sync.NewCond(&this.Mutex)
+
+	//spawn main process
+	go this.runMainProcess()
+	return this, nil
+}
+
+//queue one entry, implement io.Writer
+func (f *AsyncLogWriter) Write(p []byte) (int, error) {
+	entry := append([]byte{}, p...)
+	f.Lock()
+	for !f.closed && f.count >= len(f.ring) && f.conf.Overflow == LogOverflowBlock {
+		f.notFull.Wait()
+	}
+	if f.closed {
+		f.Unlock()
+		return 0, ErrLogWriterClosed
+	}
+	if f.count >= len(f.ring) {
+		f.dropped.Add(1)
+		if f.conf.Overflow != LogOverflowDropOldest {
+			f.Unlock()
+			return len(p), nil
+		}
+		f.ring[f.head] = nil
+		f.head = (f.head + 1) % len(f.ring)
+		f.count--
+	}
+	f.ring[(f.head + f.count) % len(f.ring)] = entry
+	f.count++
+	full := f.count >= f.conf.BatchSize
+	f.Unlock()
+
+	//wake up process when batch is full
+	if full {
+		select {
+		case f.wakeChan <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+//write all queued entries into sinks
+func (f *AsyncLogWriter) Flush() error {
+	errChan := make(chan error, 1)
+	select {
+	case f.flushChan <- errChan:
+		return <- errChan
+	case <- f.doneChan:
+		return ErrLogWriterClosed
+	}
+}
+
+//flush queued entries and close sinks
+func (f *AsyncLogWriter) Close() error {
+	f.closeOnce.Do(func() {
+		f.Lock()
+		f.closed = true
+		f.notFull.Broadcast()
+		f.Unlock()
+		close(f.closeChan)
+	})
+	<- f.doneChan
+	return nil
+}
+
+//get dropped entries count
+func (f *AsyncLogWriter) Dropped() uint64 {
+	return f.dropped.Load()
+}
+
+//get queued entries count
+func (f *AsyncLogWriter) Len() int {
+	f.Lock()
+	defer f.Unlock()
+	return f.count
+}
+
+//////////////
+//private func
+//////////////
+
+//pop batch entries
+func (f *AsyncLogWriter) popBatch() [][]byte {
+	f.Lock()
+	defer f.Unlock()
+	size := f.count
+	if size > f.conf.BatchSize {
+		size = f.conf.BatchSize
+	}
+	if size <= 0 {
+		return nil
+	}
+	batch := make([][]byte, size)
+	for i := 0; i < size; i++ {
+		batch[i] = f.ring[f.head]
+		f.ring[f.head] = nil
+		f.head = (f.head + 1) % len(f.ring)
+	}
+	f.count -= size
+	f.notFull.Broadcast()
+	return batch
+}
+
+//write all queued entries into sinks
+func (f *AsyncLogWriter) drain() error {
+	var (
+		lastErr error
+	)
+	for {
+		batch := f.popBatch()
+		if batch == nil {
+			return lastErr
+		}
+		for _, sink := range f.sinks {
+			if err := sink.WriteBatch(batch); err != nil {
+				lastErr = err
+				f.conf.OnError(err)
+			}
+		}
+	}
+}
+
+//main process
+func (f *AsyncLogWriter) runMainProcess() {
+	var (
+		m any = nil
+	)
+	ticker := time.NewTicker(f.conf.FlushInterval)
+	defer func() {
+		if err := recover(); err != m {
+			f.conf.OnError(fmt.Errorf("async log writer panic, err:%v", err))
+		}
+		ticker.Stop()
+		f.drain()
+		for _, sink := range f.sinks {
+			if err := sink.Close(); err != nil {
+				f.conf.OnError(err)
+			}
+		}
+		close(f.doneChan)
+	}()
+
+	//loop
+	for {
+		select {
+		case <- f.closeChan:
+			return
+		case errChan := <- f.flushChan:
+			errChan <- f.drain()
+		case <- f.wakeChan:
+			f.drain()
+		case <- ticker.C:
+			f.drain()
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+ * text and json log handler
+ * - text: `time=... level=INFO source=file.go:10 msg="..." k=v`, group keys joined by `.`
+ * - json: one object per line, groups as nested objects
+ * - writes of one handler and its children are serialized
+ */
+
+//inter macro define
+const (
+	DefaultLogTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+)
+
+//handler options
+type LogHandlerOptions struct {
+	Level      LogLeveler //min level, default info, use LogLevelVar to change at runtime
+	AddSource  bool       //add caller file and line
+	TimeLayout string     //default DefaultLogTimeLayout
+}
+
+//field with bound groups
+type logGroupField struct {
+	groups []string
+	field  LogField
+}
+
+//face info
+type logStreamHandler struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	opts   LogHandlerOptions
+	json   bool
+	fields []logGroupField
+	groups []string
+}
+
+//construct text handler, write into stderr if w is nil
+func NewLogTextHandler(w io.Writer, opts ...*LogHandlerOptions) LogHandler {
+	return newLogStreamHandler(w, false, opts...)
+}
+
+//construct json handler, write into stderr if w is nil
+func NewLogJSONHandler(w io.Writer, opts ...*LogHandlerOptions) LogHandler {
+	return newLogStreamHandler(w, true, opts...)
+}
+
+//check level enabled or not
+func (h *logStreamHandler) Enabled(ctx context.Context, level LogLevel) bool {
+	return level >= h.opts.Level.Level()
+}
+
+//handle one record
+func (h *logStreamHandler) Handle(ctx context.Context, record LogRecord) error {
+	fields := make([]logGroupField, 0, len(h.fields) + len(record.Fields))
+	fields = append(fields, h.fields...)
+	for _, v := range record.Fields {
+		fields = append(fields, logGroupField{h.groups, v})
+	}
+	source := ""
+	if h.opts.AddSource && record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		source = fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+	}
+
+	//encode
+	buf := &bytes.Buffer{}
+	if h.json {
+		h.encodeJSON(buf, &record, source, fields)
+	}else{
+		h.encodeText(buf, &record, source, fields)
+	}
+	buf.WriteByte('\n')
+
+	//write
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf.Bytes())
+	return err
+}
+
+//new handler with bound fields
+func (h *logStreamHandler) WithAttrs(fields []LogField) LogHandler {
+	child := h.clone()
+	for _, v := range fields {
+		child.fields = append(child.fields, logGroupField{h.groups, v})
+	}
+	return child
+}
+
+//new handler with group
+func (h *logStreamHandler) WithGroup(name string) LogHandler {
+	if name == "" {
+		return h
+	}
+	child := h.clone()
+	child.groups = append(append([]string{}, h.groups...), name)
+	return child
+}
+
+//////////////
+//private func
+//////////////
+
+//construct stream handler
+func newLogStreamHandler(w io.Writer, isJSON bool, opts ...*LogHandlerOptions) *logStreamHandler {
+	this := &logStreamHandler{
+		w: w,
+		mu: &sync.Mutex{},
+		json: isJSON,
+	}
+	if this.w == nil {
+		this.w = os.Stderr
+	}
+	if len(opts) > 0 && opts[0] != nil {
+		this.opts = *opts[0]
+	}
+	if this.opts.Level == nil {
+		this.opts.Level = LogLevelInfo
+	}
+	if this.opts.TimeLayout == "" {
+		this.opts.TimeLayout = DefaultLogTimeLayout
+	}
+	return this
+}
+
+//clone handler, share writer and lock
+func (h *logStreamHandler) clone() *logStreamHandler {
+	child := *h
+	child.fields = append([]logGroupField{}, h.fields...)
+	return &child
+}
+
+//encode record as text
+func (h *logStreamHandler) encodeText(
+	buf *bytes.Buffer,
+	record *LogRecord,
+	source string,
+	fields []logGroupField) {
+	writeField := func(key, value string) {
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(quoteLogText(key))
+		buf.WriteByte('=')
+		buf.WriteString(quoteLogText(value))
+	}
+	if !record.Time.IsZero() {
+		writeField("time", record.Time.Format(h.opts.TimeLayout))
+	}
+	writeField("level", record.Level.String())
+	if source != "" {
+		writeField("source", source)
+	}
+	writeField("msg", record.Message)
+	for _, v := range fields {
+		key := v.field.Key
+		if len(v.groups) > 0 {
+			key = strings.Join(v.groups, ".") + "." + key
+		}
+		writeField(key, formatLogText(v.field.Value))
+	}
+}
+
+//encode record as json
+func (h *logStreamHandler) encodeJSON(
+	buf *bytes.Buffer,
+	record *LogRecord,
+	source string,
+	fields []logGroupField) {
+	var (
+		opened []string
+	)
+	first := true
+	writeKey := func(key string) {
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		writeLogJSON(buf, key)
+		buf.WriteByte(':')
+	}
+	buf.WriteByte('{')
+	if !record.Time.IsZero() {
+		writeKey("time")
+		writeLogJSON(buf, record.Time.Format(h.opts.TimeLayout))
+	}
+	writeKey("level")
+	writeLogJSON(buf, record.Level.String())
+	if source != "" {
+		writeKey("source")
+		writeLogJSON(buf, source)
+	}
+	writeKey("msg")
+	writeLogJSON(buf, record.Message)
+
+	//fields, groups opened and closed by path
+	for _, v := range fields {
+		common := 0
+		for common < len(opened) && common < len(v.groups) && opened[common] == v.groups[common] {
+			common++
+		}
+		for len(opened) > common {
+			buf.WriteByte('}')
+			opened = opened[:len(opened) - 1]
+		}
+		for _, group := range v.groups[common:] {
+			writeKey(group)
+			buf.WriteByte('{')
+			first = true
+			opened = append(opened, group)
+		}
+		writeKey(v.field.Key)
+		writeLogJSON(buf, v.field.Value)
+	}
+	for range opened {
+		buf.WriteByte('}')
+	}
+	buf.WriteByte('}')
+}
+
+//format value for text
+func formatLogText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return string(v)
+	case nil:
+		return "<nil>"
+	}
+	return fmt.Sprint(value)
+}
+
+//quote text if needed
+func quoteLogText(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if r == '=' || r == '"' || r == utf8.RuneError || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+//write value as json
+func writeLogJSON(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case error:
+		value = v.Error()
+	case time.Duration:
+		value = v.String()
+	case fmt.Stringer:
+		if _, ok := v.(json.Marshaler); !ok {
+			value = v.String()
+		}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(data)
+}
@@ -0,0 +1,262 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ * log sinks of async writer
+ * - writer sink, file and stderr
+ * - syslog over local unix socket
+ * - http batch endpoint through HttpClient, failures not logged by client
+ */
+
+//inter macro define
+const (
+	LogSyslogPriority   = 14 //facility user, severity info
+	LogHttpContentType  = "application/x-ndjson"
+	logSyslogTimeLayout = time.Stamp
+)
+
+//default local syslog sockets
+var logSyslogAddresses = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+//log sink
+type LogSink interface {
+	//write batch entries, each entry is one log line
+	WriteBatch(entries [][]byte) error
+	//close sink
+	Close() error
+}
+
+//writer sink
+type logWriterSink struct {
+	w      io.Writer
+	closer io.Closer //closed with sink, optional
+}
+
+//syslog sink config
+type LogSyslogConf struct {
+	Network  string //unixgram or unix, default unixgram
+	Address  string //socket path, default search local sockets
+	Tag      string //default program name
+	Priority int    //facility | severity, default LogSyslogPriority
+}
+
+//syslog sink
+type logSyslogSink struct {
+	conf LogSyslogConf
+	conn net.Conn
+	pid  int
+	sync.Mutex
+}
+
+//http batch sink
+type logHttpSink struct {
+	client  *HttpClient
+	url     string
+	headers map[string]string
+}
+
+//construct sink of writer, writer not closed with sink
+func NewLogWriterSink(w io.Writer) LogSink {
+	return &logWriterSink{
+		w: w,
+	}
+}
+
+//construct sink of stderr
+func NewLogStderrSink() LogSink {
+	return NewLogWriterSink(os.Stderr)
+}
+
+//construct sink of file, opened in append mode
+func NewLogFileSink(fileName string) (LogSink, error) {
+	//check
+	if fileName == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &logWriterSink{
+		w: file,
+		closer: file,
+	}, nil
+}
+
+//construct sink of local syslog
+func NewLogSyslogSink(confs ...*LogSyslogConf) (LogSink, error) {
+	this := &logSyslogSink{
+		pid: os.Getpid(),
+	}
+	if len(confs) > 0 && confs[0] != nil {
+		this.conf = *confs[0]
+	}
+	if this.conf.Network == "" {
+		this.conf.Network = "unixgram"
+	}
+	if this.conf.Tag == "" {
+		this.conf.Tag = filepath.Base(os.Args[0])
+	}
+	if this.conf.Priority <= 0 {
+		this.conf.Priority = LogSyslogPriority
+	}
+	if err := this.connect(); err != nil {
+		return nil, err
+	}
+	return this, nil
+}
+
+//construct sink of http batch endpoint
+//post entries as one body, one entry per line
+func NewLogHttpSink(
+	client *HttpClient,
+	url string,
+	headers ...map[string]string) (LogSink, error) {
+	//check
+	if client == nil || url == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	this := &logHttpSink{
+		client: client,
+		url: url,
+		headers: map[string]string{
+			"Content-Type": LogHttpContentType,
+		},
+	}
+	if len(headers) > 0 {
+		for k, v := range headers[0] {
+			this.headers[k] = v
+		}
+	}
+	return this, nil
+}
+
+/////////////////
+//writer sink api
+/////////////////
+
+//write batch entries by one write
+func (s *logWriterSink) WriteBatch(entries [][]byte) error {
+	_, err := s.w.Write(bytes.Join(entries, nil))
+	return err
+}
+
+//close sink
+func (s *logWriterSink) Close() error {
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
+
+/////////////////
+//syslog sink api
+/////////////////
+
+//write batch entries, one message per entry
+func (s *logSyslogSink) WriteBatch(entries [][]byte) error {
+	s.Lock()
+	defer s.Unlock()
+	for _, entry := range entries {
+		msg := fmt.Sprintf("<%d>%s %s[%d]: %s\n",
+			s.conf.Priority, time.Now().Format(logSyslogTimeLayout), s.conf.Tag,
+			s.pid, strings.TrimRight(string(entry), "\n"))
+		if s.conn == nil || s.write(msg) != nil {
+			//reconnect and retry once
+			if err := s.connect(); err != nil {
+				return err
+			}
+			if err := s.write(msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//close sink
+func (s *logSyslogSink) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+//write one message
+func (s *logSyslogSink) write(msg string) error {
+	_, err := s.conn.Write([]byte(msg))
+	return err
+}
+
+//connect local syslog
+func (s *logSyslogSink) connect() error {
+	var (
+		lastErr error
+	)
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	addresses := logSyslogAddresses
+	if s.conf.Address != "" {
+		addresses = []string{s.conf.Address}
+	}
+	for _, address := range addresses {
+		conn, err := net.Dial(s.conf.Network, address)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		s.conn = conn
+		return nil
+	}
+	return fmt.Errorf("connect syslog failed, err:%v", lastErr)
+}
+
+///////////////
+//http sink api
+///////////////
+
+//post batch entries, non 2xx status returned as *HttpStatusError
+func (s *logHttpSink) WriteBatch(entries [][]byte) error {
+	req := s.client.GenRequest()
+	req.Kind = HttpReqPost
+	req.Url = s.url
+	req.Body = bytes.Join(entries, nil)
+	req.Silent = true
+	for k, v := range s.headers {
+		req.Headers[k] = v
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		return errors.New("no http response")
+	}
+	if !resp.IsSuccess() {
+		return &HttpStatusError{StatusCode: resp.StatusCode, Body: resp.Data}
+	}
+	return nil
+}
+
+//close sink, client not closed
+func (s *logHttpSink) Close() error {
+	return nil
+}
@@ -1,9 +1,13 @@
 package util
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -13,33 +17,100 @@ import (
  * running log service
  * @author <AndyZhou>
  * @mail <diudiu8848@163.com>
+ * - global `log` output redirected into log file
+ * - structured levelled logger writes into the same file
+ * - rotate by day, hour or max size, rotated files gzipped if needed
+ * - rotated files removed by count or age
+ * - file reopened on SIGHUP if watched by Signal
+ * - written by async buffered writer if assigned, fan out to extra sinks
  */
 
 //internal macro variables
 const (
 	LogFileCheckRate = 60 //xxx seconds
+	LogLevelConfKey  = "log_level"
+	LogFormatText    = "text"
+	LogFormatJSON    = "json"
+	LogRotateDay     = "day"
+	LogRotateHour    = "hour"
+	LogRotateSize    = "size" //rotate by max size only
 )
 
+//log service config
+type LogConf struct {
+	Path       string        //log root path
+	Prefix     string        //log file prefix
+	Level      LogLevel      //min level of structured logger
+	Format     string        //LogFormatText or LogFormatJSON, default text
+	AddSource  bool          //add caller file and line
+	Rotate     string        //LogRotateDay, LogRotateHour or LogRotateSize, default by day
+	MaxSize    int64         //max bytes of one file, 0 means no limit
+	Compress   bool          //gzip rotated files
+	MaxBackups int           //max rotated files kept, 0 means no limit
+	MaxAge     time.Duration //max age of rotated files, 0 means no limit
+	Async      *AsyncLogConf //write by async writer if assigned
+	Sinks      []LogSink     //extra sinks of async writer, like syslog or http
+}
+
 //face info
 type LogService struct {
-	path      string    //log root path
-	prefix    string    //log file prefix
-	outFile   *os.File  //file handler
-	lastDay   string    //last day for change file
-	lastHour  string    //last hour for change file
-	closeChan chan bool //chan for close process
+	conf        LogConf
+	path        string       //log root path
+	prefix      string       //log file prefix
+	outFile     *os.File     //file handler
+	outName     string       //current file name
+	outSize     int64        //current file size
+	period      string       //current period, like `2006-01-02`
+	closeChan   chan bool    //chan for close process
+	level       *LogLevelVar //runtime level
+	logger      *Logger      //structured logger
+	zip         *Zip
+	async       *AsyncLogWriter
+	archiveLock sync.Mutex
+	archiveWg   sync.WaitGroup
 	sync.RWMutex
 }
 
+//file writer of log service, used as sink of async writer
+type logServiceFile struct {
+	service *LogService
+}
+
 //construct
 func NewLogService(path, prefix string) *LogService {
+	return NewLogServiceWithConf(&LogConf{
+		Path: path,
+		Prefix: prefix,
+	})
+}
+
+//construct with config
+func NewLogServiceWithConf(conf *LogConf) *LogService {
+	if conf == nil {
+		conf = &LogConf{Path: ".", Prefix: "app"}
+	}
 	this := &LogService{
-		path:path,
-		prefix:prefix,
+		conf:*conf,
+		path:conf.Path,
+		prefix:conf.Prefix,
 		closeChan:make(chan bool),
+		level:NewLogLevelVar(conf.Level),
+		zip:NewZip(),
+	}
+	opts := &LogHandlerOptions{
+		Level: this.level,
+		AddSource: conf.AddSource,
+	}
+	if conf.Format == LogFormatJSON {
+		this.logger = NewLogger(NewLogJSONHandler(this, opts))
+	}else{
+		this.logger = NewLogger(NewLogTextHandler(this, opts))
+	}
+	this.period = this.getPeriod()
+	if conf.Async != nil {
+		sinks := append([]LogSink{NewLogWriterSink(logServiceFile{this})}, conf.Sinks...)
+		this.async, _ = NewAsyncLogWriter(conf.Async, sinks...)
 	}
-	this.lastHour = this.getCurHour()
-	this.lastDay = this.getCurDay()
 	//register first log file
 	this.registerLogFile()
 	//spawn son process for sync file handler
@@ -49,73 +120,377 @@ func NewLogService(path, prefix string) *LogService {
 
 //close
 func (l *LogService) Close() bool {
-	if l.outFile == nil {
+	l.RLock()
+	opened := l.outFile != nil
+	l.RUnlock()
+	if !opened {
 		return false
 	}
+	if l.async != nil {
+		//flush queued entries into file first
+		l.async.Close()
+	}
 	if l.closeChan != nil {
 		l.closeChan <- true
 	}
 	//need sleep awhile for internal clean up
 	time.Sleep(time.Second/10)
+	l.archiveWg.Wait()
 	return true
 }
 
+//write log, implement io.Writer
+//queued if async writer assigned, or write into file directly
+func (l *LogService) Write(p []byte) (int, error) {
+	if l.async != nil {
+		return l.async.Write(p)
+	}
+	return l.writeFile(p)
+}
+
+//flush queued logs into file and sinks
+func (l *LogService) Flush() error {
+	if l.async != nil {
+		return l.async.Flush()
+	}
+	l.RLock()
+	defer l.RUnlock()
+	if l.outFile == nil {
+		return errors.New("log file not opened")
+	}
+	return l.outFile.Sync()
+}
+
+//get async writer, nil if not assigned
+func (l *LogService) GetAsyncWriter() *AsyncLogWriter {
+	return l.async
+}
+
+//reopen current log file, used after moved by outside tools
+func (l *LogService) Reopen() error {
+	l.Lock()
+	defer l.Unlock()
+	l.closeFile()
+	return l.openFile()
+}
+
+//reopen log file on SIGHUP
+func (l *LogService) WatchSignal(s *Signal) error {
+	//check
+	if s == nil {
+		return errors.New("invalid parameter")
+	}
+	return s.RegisterHupFunc(func() {
+		if err := l.Reopen(); err != nil {
+			fmt.Println("reopen log file failed, error:", err.Error())
+		}
+	})
+}
+
+//remove rotated files out of retention
+func (l *LogService) CleanBackups() error {
+	l.archiveLock.Lock()
+	defer l.archiveLock.Unlock()
+	return l.cleanBackups()
+}
+
+//get structured logger
+func (l *LogService) Logger() *Logger {
+	return l.logger
+}
+
+//child logger with bound fields
+func (l *LogService) With(args ...interface{}) *Logger {
+	return l.logger.With(args...)
+}
+
+//get current level
+func (l *LogService) GetLevel() LogLevel {
+	return l.level.Level()
+}
+
+//set level at runtime
+func (l *LogService) SetLevel(level LogLevel) {
+	l.level.Set(level)
+}
+
+//set level by name at runtime, like `debug`
+func (l *LogService) SetLevelString(level string) error {
+	return l.level.SetString(level)
+}
+
+//reload level from config map
+//keys -> level key of config, default LogLevelConfKey
+//return false only if level invalid, level kept if key not found
+func (l *LogService) ReloadLevel(conf map[string]interface{}, keys ...string) bool {
+	key := LogLevelConfKey
+	if len(keys) > 0 && keys[0] != "" {
+		key = keys[0]
+	}
+	if _, ok := conf[key]; !ok {
+		return true
+	}
+	level, err := logLevelFromConf(conf, key)
+	if err != nil {
+		return false
+	}
+	l.level.Set(level)
+	return true
+}
+
+//reload level by LogLevelConfKey, used as config.SubConfig callback
+//like config.NewSubConfig(file, logService.OnConfigReload)
+func (l *LogService) OnConfigReload(conf map[string]interface{}) bool {
+	return l.ReloadLevel(conf)
+}
+
+//log by levels
+func (l *LogService) Debug(msg string, args ...interface{}) {
+	l.logger.log(context.Background(), LogLevelDebug, 1, msg, args)
+}
+
+func (l *LogService) Info(msg string, args ...interface{}) {
+	l.logger.log(context.Background(), LogLevelInfo, 1, msg, args)
+}
+
+func (l *LogService) Warn(msg string, args ...interface{}) {
+	l.logger.log(context.Background(), LogLevelWarn, 1, msg, args)
+}
+
+func (l *LogService) Error(msg string, args ...interface{}) {
+	l.logger.log(context.Background(), LogLevelError, 1, msg, args)
+}
+
 ////////////////////
 //private function
 ///////////////////
 
-//get current hour info
-func (l *LogService) getCurHour() string {
-	now := time.Now()
-	allSlice := strings.Split(now.String(), " ")
-	day := allSlice[0]
-	time := allSlice[1]
-	timeSlice := strings.Split(time, ":")
-	hour := timeSlice[0]
-	return fmt.Sprintf("%s-%s", day, hour)
+//write into current log file
+//rotate before write if period changed or max size reached
+func (l *LogService) writeFile(p []byte) (int, error) {
+	l.Lock()
+	defer l.Unlock()
+	if l.outFile == nil {
+		return 0, errors.New("log file not opened")
+	}
+	period := l.getPeriod()
+	if period != l.period ||
+		(l.conf.MaxSize > 0 && l.outSize > 0 && l.outSize + int64(len(p)) > l.conf.MaxSize) {
+		if err := l.rotate(period); err != nil {
+			return 0, err
+		}
+	}
+	n, err := l.outFile.Write(p)
+	l.outSize += int64(n)
+	return n, err
 }
 
-//get current day info
-func (l *LogService) getCurDay() string {
-	now := time.Now()
-	allSlice := strings.Split(now.String(), " ")
-	day := allSlice[0]
-	return day
+//get current period
+func (l *LogService) getPeriod() string {
+	layout := l.getPeriodLayout()
+	if layout == "" {
+		return ""
+	}
+	return time.Now().Format(layout)
+}
+
+//get time layout of period, empty for size rotate
+func (l *LogService) getPeriodLayout() string {
+	switch l.conf.Rotate {
+	case LogRotateSize:
+		return ""
+	case LogRotateHour:
+		return "2006-01-02-15"
+	default:
+		return "2006-01-02"
+	}
+}
+
+//get log file name of period
+func (l *LogService) getFileName(period string) string {
+	if period == "" {
+		return fmt.Sprintf("%s/%s.log", l.path, l.prefix)
+	}
+	return fmt.Sprintf("%s/%s-%s.log", l.path, l.prefix, period)
 }
 
 //register local log file into log.xxx command
 func (l *LogService) registerLogFile() bool {
-	var err error
 	l.Lock()
 	defer l.Unlock()
+	l.closeFile()
+	if err := l.openFile(); err != nil {
+		fmt.Println("Open log file failed, error:", err.Error())
+		return false
+	}
+	//bind on log function
+	log.SetOutput(l)
+	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
+	return true
+}
+
+//open log file of current period, called within lock
+func (l *LogService) openFile() error {
+	fileName := l.getFileName(l.period)
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.outFile = file
+	l.outName = fileName
+	l.outSize = info.Size()
+	return nil
+}
+
+//close log file, called within lock
+func (l *LogService) closeFile() {
 	if l.outFile != nil {
-		//close old file
 		l.outFile.Close()
 		l.outFile = nil
 	}
-	file := fmt.Sprintf("%s/%s-%s.log", l.path, l.prefix, l.lastDay)
-	l.outFile, err = os.OpenFile(file, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666)
+}
+
+//rotate into new file, called within lock
+func (l *LogService) rotate(period string) error {
+	oldName := l.outName
+	l.closeFile()
+	if period == l.period {
+		//max size reached in same period, move into backup name
+		backupName := l.getBackupName(oldName)
+		if err := os.Rename(oldName, backupName); err != nil {
+			fmt.Println("rename log file failed, error:", err.Error())
+		}else{
+			oldName = backupName
+		}
+	}
+	l.period = period
+	if err := l.openFile(); err != nil {
+		return err
+	}
+
+	//archive rotated file in background
+	l.archiveWg.Add(1)
+	go l.archive(oldName)
+	return nil
+}
+
+//get next free backup name, like `prefix-2006-01-02.1.log`
+func (l *LogService) getBackupName(fileName string) string {
+	base := strings.TrimSuffix(fileName, ".log")
+	for i := 1; ; i++ {
+		backupName := fmt.Sprintf("%s.%d.log", base, i)
+		if _, err := os.Stat(backupName); err == nil {
+			continue
+		}
+		if _, err := os.Stat(backupName + ".gz"); err == nil {
+			continue
+		}
+		return backupName
+	}
+}
+
+//compress rotated file and clean backups
+func (l *LogService) archive(fileName string) {
+	defer l.archiveWg.Done()
+	l.archiveLock.Lock()
+	defer l.archiveLock.Unlock()
+	if l.conf.Compress {
+		if _, err := l.zip.GzipFile(fileName); err != nil {
+			fmt.Println("gzip log file failed, error:", err.Error())
+		}
+	}
+	if err := l.cleanBackups(); err != nil {
+		fmt.Println("clean log files failed, error:", err.Error())
+	}
+}
+
+//remove rotated files by count and age
+func (l *LogService) cleanBackups() error {
+	type backupFile struct {
+		name    string
+		modTime time.Time
+	}
+	var (
+		backups []backupFile
+	)
+	if l.conf.MaxBackups <= 0 && l.conf.MaxAge <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(l.path)
 	if err != nil {
-		fmt.Println("Open log file failed, error:", err.Error())
+		return err
+	}
+	l.RLock()
+	curName := filepath.Base(l.outName)
+	l.RUnlock()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == curName || !l.isLogFile(name) {
+			continue
+		}
+		info, subErr := entry.Info()
+		if subErr != nil {
+			continue
+		}
+		backups = append(backups, backupFile{name, info.ModTime()})
+	}
+
+	//newest first
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].modTime.After(backups[j].modTime)
+	})
+	now := time.Now()
+	for i, v := range backups {
+		if (l.conf.MaxBackups > 0 && i >= l.conf.MaxBackups) ||
+			(l.conf.MaxAge > 0 && now.Sub(v.modTime) > l.conf.MaxAge) {
+			os.Remove(filepath.Join(l.path, v.name))
+		}
+	}
+	return nil
+}
+
+//check file created by this service or not
+//like `prefix-2006-01-02[.N].log[.gz]` or `prefix.N.log[.gz]`
+func (l *LogService) isLogFile(name string) bool {
+	name = strings.TrimSuffix(name, ".gz")
+	if !strings.HasPrefix(name, l.prefix) || !strings.HasSuffix(name, ".log") ||
+		len(name) <= len(l.prefix) + len(".log") {
 		return false
 	}
-	//bind on log function
-	log.SetOutput(l.outFile)
-	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
-	return true
+	body := strings.TrimSuffix(name[len(l.prefix):], ".log")
+	if body[0] == '.' {
+		//backup of size rotate
+		return isBackupSeq(body[1:])
+	}
+	layout := l.getPeriodLayout()
+	if body[0] != '-' || layout == "" || len(body) < len(layout) + 1 {
+		return false
+	}
+	if _, err := time.Parse(layout, body[1:len(layout) + 1]); err != nil {
+		return false
+	}
+	body = body[len(layout) + 1:]
+	return body == "" || (body[0] == '.' && isBackupSeq(body[1:]))
 }
 
 //check and sync log handler
 func (l *LogService) syncFileHandler() bool {
-	curDay := l.getCurDay()
-	if curDay == l.lastDay {
-		//same day, do nothing
+	l.Lock()
+	defer l.Unlock()
+	period := l.getPeriod()
+	if l.outFile == nil || period == l.period {
+		//same period, do nothing
 		return false
 	}
-	//sync last day
-	l.lastDay = curDay
-	//force register new log file
-	l.registerLogFile()
+	//force rotate new log file
+	if err := l.rotate(period); err != nil {
+		fmt.Println("rotate log file failed, error:", err.Error())
+	}
 	return true
 }
 
@@ -130,20 +505,42 @@ func (l *LogService) runCheckProcess() {
 		if err := recover(); err != m {
 			log.Printf("logService:checkProcess panic, err:%v\n", err)
 		}
-		if l.outFile != nil {
-			l.outFile.Close()
-			l.outFile = nil
-		}
+		l.Lock()
+		l.closeFile()
+		l.Unlock()
 	}()
 
+	//clean old files at first
+	l.CleanBackups()
+
 	//loop
 	for {
 		select {
 		case <- tick:
 			//check and sync file handler
-			l.syncFileHandler()
+			if !l.syncFileHandler() {
+				l.CleanBackups()
+			}
 		case <- l.closeChan:
 			return
 		}
 	}
-}
\ No newline at end of file
+}
+
+//write into log file of service
+func (w logServiceFile) Write(p []byte) (int, error) {
+	return w.service.writeFile(p)
+}
+
+//check backup sequence, like `1` of `prefix.1.log`
+func isBackupSeq(seq string) bool {
+	if seq == "" {
+		return false
+	}
+	for _, c := range seq {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
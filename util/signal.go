@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
@@ -26,6 +27,8 @@ import (
  * //how kill and trigger
  * ps -ef | grep <your_binary>
  * kill -15 <go-program-pid>
+ * - SIGHUP runs registered hup callbacks instead of quit, like reopen log file
+ * - multi shutdown callbacks by RegisterShutDownFunc, run with chan callback
  */
 
 //inter macro define
@@ -42,7 +45,11 @@ type Signal struct {
 	shutDownChan chan bool //refer chan slice
 	ch           chan os.Signal
 	stopSig      chan bool
+	resetChan    chan bool //notify receiver shut down chan changed
 	cbForQuit    func()
+	cbForQuits   []func()
+	cbForHups    []func()
+	cbLock       sync.RWMutex
 	initDone     bool
 }
 
@@ -59,6 +66,7 @@ func NewSignal(waitSeconds ...int) *Signal {
 		shutDownChan:make(chan bool, 1),
 		ch:make(chan os.Signal, 1),
 		stopSig:make(chan bool, 1),
+		resetChan:make(chan bool, 1),
 	}
 	return this
 }
@@ -71,14 +79,43 @@ func (f *Signal) RegisterShutDownChan(ch chan bool, cb func()) error {
 	}
 
 	//sync
+	f.cbLock.Lock()
 	f.shutDownChan = ch
 	f.cbForQuit = cb
+	f.cbLock.Unlock()
 
-	if !f.initDone {
-		//spawn son process to receive message
-		go f.receiveMsg()
-		f.initDone = true
+	//notify running receiver the new chan
+	select {
+	case f.resetChan <- true:
+	default:
+	}
+	f.startReceiver()
+	return nil
+}
+
+//register shut down callback, all callbacks run when shutdown
+//could be used with RegisterShutDownChan together
+func (f *Signal) RegisterShutDownFunc(cb func()) error {
+	//check
+	if cb == nil {
+		return errors.New("invalid parameter")
 	}
+	f.cbLock.Lock()
+	f.cbForQuits = append(f.cbForQuits, cb)
+	f.cbLock.Unlock()
+	f.startReceiver()
+	return nil
+}
+
+//register SIGHUP callback, SIGHUP will not quit once registered
+func (f *Signal) RegisterHupFunc(cb func()) error {
+	//check
+	if cb == nil {
+		return errors.New("invalid parameter")
+	}
+	f.cbLock.Lock()
+	defer f.cbLock.Unlock()
+	f.cbForHups = append(f.cbForHups, cb)
 	return nil
 }
 
@@ -103,7 +140,11 @@ func (f *Signal) MonSignal() {
 			msg := <- f.ch
 			switch msg {
 			case syscall.SIGHUP:
-				fallthrough
+				if f.runHupFuncs() {
+					continue
+				}
+				atomic.StoreInt32(&f.SIGTERM, 1)
+				f.onExit(msg)
 			case syscall.SIGTERM:
 				atomic.StoreInt32(&f.SIGTERM, 1)
 				f.onExit(msg)
@@ -121,29 +162,79 @@ func (f *Signal) MonSignal() {
 	}(f)
 }
 
+//get wait seconds before exit
+func (f *Signal) GetWaitSeconds() int {
+	return f.waitSeconds
+}
+
 //force quit
 func (f *Signal) ForceNotify() {
+	ch := f.getShutDownChan()
 	//check
-	if f.shutDownChan == nil {
+	if ch == nil {
 		return
 	}
 	//send notify to chan
-	f.shutDownChan <- true
+	ch <- true
 }
 
 ///////////////
 //private func
 ///////////////
 
+//run SIGHUP callbacks, return false if none
+func (f *Signal) runHupFuncs() bool {
+	f.cbLock.RLock()
+	cbs := f.cbForHups
+	f.cbLock.RUnlock()
+	if len(cbs) <= 0 {
+		return false
+	}
+	for _, cb := range cbs {
+		cb()
+	}
+	return true
+}
+
+//spawn receiver once
+func (f *Signal) startReceiver() {
+	f.cbLock.Lock()
+	defer f.cbLock.Unlock()
+	if f.initDone {
+		return
+	}
+	//spawn son process to receive message
+	go f.receiveMsg()
+	f.initDone = true
+}
+
+//get current shut down chan
+func (f *Signal) getShutDownChan() chan bool {
+	f.cbLock.RLock()
+	defer f.cbLock.RUnlock()
+	return f.shutDownChan
+}
+
 //receive shut down message
 func (f *Signal) receiveMsg() {
-	select {
-	case <- f.shutDownChan:
-		{
-			if f.cbForQuit != nil {
-				f.cbForQuit()
+	for {
+		select {
+		case <- f.getShutDownChan():
+			{
+				//run chan callback and shut down callbacks
+				f.cbLock.RLock()
+				cb, cbs := f.cbForQuit, f.cbForQuits
+				f.cbLock.RUnlock()
+				if cb != nil {
+					cb()
+				}
+				for _, v := range cbs {
+					v()
+				}
+				return
 			}
-			return
+		case <- f.resetChan:
+			//shut down chan changed, receive again
 		}
 	}
 }
@@ -163,13 +254,14 @@ func (f *Signal) onExit(msg os.Signal) {
 
 //notify shutdown chan
 func (f *Signal) notifyShutDownChan() {
+	ch := f.getShutDownChan()
 	//check
-	if f.shutDownChan == nil {
+	if ch == nil {
 		return
 	}
 
 	//send notify to relate chan
-	f.shutDownChan <- true
+	ch <- true
 
 	//sleep for a while
 	duration := time.Duration(f.waitSeconds) * time.Second
@@ -4,6 +4,7 @@ package util
 import (
 	"archive/zip"
 	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
@@ -146,6 +147,52 @@ func (f *Zip) AddFileIntoZip(
 }
 
 
+//gzip single file into `fileName.gz`, return gzip file name
+//keepSources -> keep source file or not, default removed
+func (f *Zip) GzipFile(fileName string, keepSources ...bool) (string, error) {
+	//check
+	if fileName == "" {
+		return "", errors.New("invalid parameter")
+	}
+
+	//open source file
+	srcFile, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer srcFile.Close()
+
+	//write into temp file, then rename
+	gzFileName := fileName + ".gz"
+	tmpFileName := fmt.Sprintf("%s.%d.tmp", gzFileName, time.Now().UnixNano())
+	tmpFile, err := os.Create(tmpFileName)
+	if err != nil {
+		return "", err
+	}
+	gzWriter := gzip.NewWriter(tmpFile)
+	_, err = io.Copy(gzWriter, srcFile)
+	if err == nil {
+		err = gzWriter.Close()
+	}
+	if subErr := tmpFile.Close(); err == nil {
+		err = subErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFileName, gzFileName)
+	}
+	if err != nil {
+		os.Remove(tmpFileName)
+		return "", err
+	}
+
+	//remove source
+	if len(keepSources) <= 0 || !keepSources[0] {
+		srcFile.Close()
+		os.Remove(fileName)
+	}
+	return gzFileName, nil
+}
+
 //create zip file
 func (f *Zip) Create(zipFileName string) error {
 	//create zip file